@@ -1,6 +1,17 @@
 import React, { useState, useEffect } from "react";
 import "./App.css";
 
+// The order service requires a customer id; until there is a login, each
+// browser keeps a random one so its orders can be told apart.
+function getCustomerId() {
+  let id = localStorage.getItem("customerId");
+  if (!id) {
+    id = crypto.randomUUID();
+    localStorage.setItem("customerId", id);
+  }
+  return id;
+}
+
 function App() {
   const [items, setItems] = useState([]);
   const [cart, setCart] = useState([]);
@@ -25,6 +36,7 @@ function App() {
     }
 
     const order = {
+      customer_id: getCustomerId(),
       item_ids: cart.map((item) => item.id),
     };
 
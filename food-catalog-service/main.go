@@ -1,29 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type FoodItem struct {
-	ID    string  `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price"`
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Available bool    `json:"available"`
+	Price     float64 `json:"price"`
 }
 
 var foodItems = []FoodItem{
-	{ID: "1", Name: "Coffee", Price: 2.50},
-	{ID: "2", Name: "Sandwich", Price: 5.00},
-	{ID: "3", Name: "Muffin", Price: 3.25},
+	{ID: "1", Name: "Coffee", Available: true, Price: 2.50},
+	{ID: "2", Name: "Sandwich", Available: true, Price: 5.00},
+	{ID: "3", Name: "Muffin", Available: true, Price: 3.25},
 }
 
 func main() {
+	// The catalog has no shutdown hook, so spans are flushed by the batcher
+	// on its regular interval.
+	if _, err := setupTracing(context.Background()); err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
 	r := chi.NewRouter()
+	r.Use(otelhttp.NewMiddleware("food-catalog-service"))
 	r.Use(middleware.Logger)
+	r.Use(middleware.GetHead)
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -34,6 +45,18 @@ func main() {
 		json.NewEncoder(w).Encode(foodItems)
 	})
 
+	r.Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		for _, item := range foodItems {
+			if item.ID == id {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(item)
+				return
+			}
+		}
+		http.Error(w, "item not found", http.StatusNotFound)
+	})
+
 	log.Println("Food Catalog Service starting on port 8080...")
 	http.ListenAndServe(":8080", r)
-}
\ No newline at end of file
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// resolveAdminEnabled reads ADMIN_ENABLED; the admin routes are off unless
+// it is explicitly true.
+func resolveAdminEnabled() (bool, error) {
+	v := setting("ADMIN_ENABLED")
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid ADMIN_ENABLED %q: must be true or false", v)
+	}
+	if enabled {
+		log.Println("Warning: ADMIN_ENABLED is set, DELETE /admin/orders can wipe every order and PUT /admin/maintenance and /admin/item-policy can turn order traffic away")
+	}
+	return enabled, nil
+}
+
+// flushOrders removes every order so test and demo environments can be reset
+// without restarting the pod.
+func (h *handler) flushOrders(w http.ResponseWriter, r *http.Request) {
+	n, err := h.store.Clear()
+	if err != nil {
+		requestLogger(r.Context()).Error("flushing orders", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, CodeInternal, "failed to flush orders")
+		return
+	}
+	h.events.Clear()
+	requestLogger(r.Context()).Warn("flushed all orders", "removed", n)
+	writeJSON(w, http.StatusOK, map[string]int{"removed": n})
+}
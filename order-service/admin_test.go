@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFlushOrders(t *testing.T) {
+	h := newTestHandler(t, WithAdmin(true))
+	routes := h.routes()
+	first := createTestOrder(t, routes, simpleOrder)
+	createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodDelete, "/admin/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[map[string]int](t, rec); got["removed"] != 2 {
+		t.Errorf("body = %v, want removed 2", got)
+	}
+	if orders := h.store.All(); len(orders) != 0 {
+		t.Errorf("store still holds %d orders", len(orders))
+	}
+	if events := h.events.Events(first.ID); len(events) != 0 {
+		t.Errorf("history survived the flush: %+v", events)
+	}
+}
+
+func TestFlushOrdersNeedsAdmin(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	createTestOrder(t, routes, simpleOrder)
+
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/admin/orders", ""), http.StatusNotFound)
+	if len(h.store.All()) != 1 {
+		t.Error("orders were flushed with the admin routes off")
+	}
+}
+
+func TestFlushOrdersNeedsAPIKey(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true), WithAPIKeys([]string{"k1"})).routes()
+	rec := serve(t, routes, http.MethodDelete, "/admin/orders", "")
+	wantError(t, rec, http.StatusUnauthorized, CodeUnauthorized)
+	rec = serve(t, routes, http.MethodDelete, "/admin/orders", "", "Authorization", "Bearer k1")
+	wantStatus(t, rec, http.StatusOK)
+}
+
+func TestResolveAdminEnabled(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "false": false, "true": true} {
+		t.Setenv("ADMIN_ENABLED", v)
+		if got, err := resolveAdminEnabled(); got != want || err != nil {
+			t.Errorf("ADMIN_ENABLED=%q = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	t.Setenv("ADMIN_ENABLED", "on")
+	if _, err := resolveAdminEnabled(); err == nil {
+		t.Error("ADMIN_ENABLED=on accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// supportedAPIVersions lists the versions clients may request; the first is
+// the default for requests that do not name one.
+var supportedAPIVersions = []string{"v1"}
+
+// apiVersionFrom returns the version negotiated by apiVersionMiddleware.
+func apiVersionFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey).(string); ok {
+		return v
+	}
+	return supportedAPIVersions[0]
+}
+
+// apiVersionMiddleware negotiates the API version from X-API-Version ("1"
+// or "v1") or an Accept media type such as application/vnd.orders.v1+json,
+// defaulting to v1. Unknown or conflicting versions are rejected with 400.
+func apiVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, err := requestedAPIVersion(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, CodeUnsupportedAPIVersion, err.Error())
+			return
+		}
+		w.Header().Set("X-API-Version", version)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiVersionKey, version)))
+	})
+}
+
+func requestedAPIVersion(r *http.Request) (string, error) {
+	fromHeader := ""
+	if v := strings.TrimSpace(r.Header.Get("X-API-Version")); v != "" {
+		fromHeader = strings.ToLower(v)
+		if !strings.HasPrefix(fromHeader, "v") {
+			fromHeader = "v" + fromHeader
+		}
+	}
+	fromAccept := acceptAPIVersion(r.Header.Get("Accept"))
+
+	version := fromHeader
+	switch {
+	case fromHeader != "" && fromAccept != "" && fromHeader != fromAccept:
+		return "", fmt.Errorf("X-API-Version %s conflicts with Accept version %s", fromHeader, fromAccept)
+	case version == "":
+		version = fromAccept
+	}
+	if version == "" {
+		return supportedAPIVersions[0], nil
+	}
+	if !slices.Contains(supportedAPIVersions, version) {
+		return "", fmt.Errorf("unsupported API version %q: supported versions are %s", version, strings.Join(supportedAPIVersions, ", "))
+	}
+	return version, nil
+}
+
+// acceptAPIVersion extracts "vN" from an application/vnd.orders.vN+json
+// entry in an Accept header, or returns "" if there is none.
+func acceptAPIVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		rest, ok := strings.CutPrefix(mediaType, "application/vnd.orders.")
+		if !ok {
+			continue
+		}
+		if version, ok := strings.CutSuffix(rest, "+json"); ok {
+			return version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestedAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		name, header, accept string
+		want                 string
+		wantErr              bool
+	}{
+		{"default", "", "", "v1", false},
+		{"bare number", "1", "", "v1", false},
+		{"prefixed", "V1", "", "v1", false},
+		{"accept type", "", "text/html, application/vnd.orders.v1+json", "v1", false},
+		{"unknown version", "2", "", "", true},
+		{"unknown accept version", "", "application/vnd.orders.v3+json", "", true},
+		{"conflict", "1", "application/vnd.orders.v2+json", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			r.Header.Set("X-API-Version", tc.header)
+			r.Header.Set("Accept", tc.accept)
+			got, err := requestedAPIVersion(r)
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("requestedAPIVersion = %q, %v; want %q, error %t", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIVersionHeader(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+	if v := rec.Header().Get("X-API-Version"); v != "v1" {
+		t.Errorf("X-API-Version = %q, want v1", v)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders", "", "X-API-Version", "2")
+	wantError(t, rec, http.StatusBadRequest, CodeUnsupportedAPIVersion)
+}
+
+func TestCORSExposesAPIVersion(t *testing.T) {
+	routes := newTestHandler(t, WithAllowedOrigins([]string{"https://shop.example.com"})).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders", "", "Origin", "https://shop.example.com")
+	if exposed := rec.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, "X-Api-Version") {
+		t.Errorf("Access-Control-Expose-Headers = %q, want X-API-Version", exposed)
+	}
+}
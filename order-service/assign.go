@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxAssigneeLength caps the station or courier name an order is assigned to.
+const maxAssigneeLength = 64
+
+// assignOrder routes an order to a fulfilment station or courier. An empty
+// assigned_to unassigns it. Delivered and cancelled orders cannot be
+// reassigned.
+func (h *handler) assignOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AssignedTo string `json:"assigned_to"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	req.AssignedTo = strings.TrimSpace(req.AssignedTo)
+	if len(req.AssignedTo) > maxAssigneeLength {
+		writeJSONError(w, http.StatusBadRequest, CodeValidationFailed, fmt.Sprintf("assigned_to must be at most %d characters", maxAssigneeLength))
+		return
+	}
+
+	var previous string
+	unchanged := false
+	order, err := h.store.Update(chi.URLParam(r, "id"), func(o *Order) error {
+		if o.Deleted {
+			return ErrNotFound
+		}
+		if isTerminal(o.Status) {
+			return &requestError{status: http.StatusConflict, code: CodeOrderLocked, msg: fmt.Sprintf("a %s order cannot be assigned", o.Status)}
+		}
+		previous = o.AssignedTo
+		if unchanged = o.AssignedTo == req.AssignedTo; unchanged {
+			return nil
+		}
+		o.AssignedTo = req.AssignedTo
+		o.UpdatedAt = h.now().UTC()
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "assign order"))
+		return
+	}
+	if !unchanged {
+		h.recordEvent(r.Context(), EventOrderAssigned, order, previous, order.AssignedTo)
+	}
+	writeOrder(w, r, order)
+}
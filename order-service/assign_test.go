@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAssignOrder(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/assign", `{"assigned_to":" grill "}`)
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); got.AssignedTo != "grill" {
+		t.Errorf("assigned_to = %q, want grill", got.AssignedTo)
+	}
+	if stored, _ := h.store.Get(order.ID); stored.AssignedTo != "grill" {
+		t.Errorf("stored assigned_to = %q, want grill", stored.AssignedTo)
+	}
+}
+
+func TestAssignTerminalOrderConflicts(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	if _, err := h.store.UpdateStatus(order.ID, StatusCancelled, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/assign", `{"assigned_to":"grill"}`)
+	wantError(t, rec, http.StatusConflict, CodeOrderLocked)
+	if stored, _ := h.store.Get(order.ID); stored.AssignedTo != "" || stored.Status != StatusCancelled {
+		t.Errorf("stored order = %+v, want it unchanged", stored)
+	}
+}
+
+func TestAssignDeletedOrderNotFound(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/"+order.ID, ""), http.StatusNoContent)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/assign", `{"assigned_to":"grill"}`)
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestAssignOrderRecordsEvents(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	for _, assignee := range []string{"grill", "grill", ""} {
+		rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/assign", `{"assigned_to":"`+assignee+`"}`)
+		wantStatus(t, rec, http.StatusOK)
+	}
+	var assigned []OrderEvent
+	for _, e := range h.events.Events(order.ID) {
+		if e.Type == EventOrderAssigned {
+			assigned = append(assigned, e)
+		}
+	}
+	if len(assigned) != 2 {
+		t.Fatalf("got %d assignment events, want 2 (no event for an unchanged assignee): %+v", len(assigned), assigned)
+	}
+	if assigned[0].To != "grill" || assigned[1].From != "grill" || assigned[1].To != "" {
+		t.Errorf("events = %+v, want assign then unassign", assigned)
+	}
+	if stored, _ := h.store.Get(order.ID); stored.AssignedTo != "" {
+		t.Errorf("stored assigned_to = %q, want it cleared", stored.AssignedTo)
+	}
+}
+
+func TestAssignOrderRejectsLongAssignee(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/assign", `{"assigned_to":"`+strings.Repeat("x", maxAssigneeLength+1)+`"}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestListOrdersFiltersByAssignee(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	grill := createTestOrder(t, routes, simpleOrder)
+	createTestOrder(t, routes, simpleOrder)
+	wantStatus(t, serve(t, routes, http.MethodPost, "/orders/"+grill.ID+"/assign", `{"assigned_to":"grill"}`), http.StatusOK)
+
+	rec := serve(t, routes, http.MethodGet, "/orders?assigned_to=grill", "")
+	wantStatus(t, rec, http.StatusOK)
+	if orders := decodeBody[[]Order](t, rec); len(orders) != 1 || orders[0].ID != grill.ID {
+		t.Errorf("assigned_to=grill = %+v, want only %s", orders, grill.ID)
+	}
+}
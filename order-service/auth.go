@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// resolveAPIKeys reads the comma-separated API_KEYS variable.
+func resolveAPIKeys() []string {
+	return parseList(setting("API_KEYS"))
+}
+
+// apiKeyMiddleware rejects requests without an "Authorization: Bearer <key>"
+// header matching one of keys.
+func apiKeyMiddleware(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := bearerToken(r)
+			if !ok {
+				writeJSONError(w, http.StatusUnauthorized, CodeUnauthorized, "missing bearer token")
+				return
+			}
+			if !validAPIKey(keys, key) {
+				writeJSONError(w, http.StatusUnauthorized, CodeUnauthorized, "invalid API key")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(h[len(prefix):]), true
+}
+
+// validAPIKey compares against every key in constant time so response
+// timing does not reveal how close a guess was.
+func validAPIKey(keys []string, key string) bool {
+	valid := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	routes := newTestHandler(t, WithAPIKeys([]string{"k1", "k2"})).routes()
+	for _, tc := range []struct {
+		name, auth string
+		status     int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"not bearer", "Basic azE6", http.StatusUnauthorized},
+		{"wrong key", "Bearer nope", http.StatusUnauthorized},
+		{"first key", "Bearer k1", http.StatusOK},
+		{"second key, lower-case scheme", "bearer k2", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var headers []string
+			if tc.auth != "" {
+				headers = []string{"Authorization", tc.auth}
+			}
+			rec := serve(t, routes, http.MethodGet, "/orders", "", headers...)
+			if tc.status == http.StatusUnauthorized {
+				wantError(t, rec, tc.status, CodeUnauthorized)
+			} else {
+				wantStatus(t, rec, tc.status)
+			}
+		})
+	}
+}
+
+// Probes and metrics stay open so the platform can reach them.
+func TestAPIKeysLeaveProbesOpen(t *testing.T) {
+	routes := newTestHandler(t, WithAPIKeys([]string{"k1"})).routes()
+	for _, path := range []string{"/health", "/healthz", "/metrics"} {
+		wantStatus(t, serve(t, routes, http.MethodGet, path, ""), http.StatusOK)
+	}
+}
+
+func TestResolveAPIKeys(t *testing.T) {
+	t.Setenv("API_KEYS", "k1, k2,")
+	if keys := resolveAPIKeys(); !slices.Equal(keys, []string{"k1", "k2"}) {
+		t.Errorf("resolveAPIKeys = %q", keys)
+	}
+}
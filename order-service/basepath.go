@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// probePaths keep answering without the base path, so probes and Consul
+// checks need no knowledge of the ingress prefix.
+var probePaths = []string{"/health", "/healthz", "/ready", "/readyz"}
+
+// resolveBasePath reads BASE_PATH, such as "/order-service", under which
+// every route is mounted. Unset means the root.
+func resolveBasePath() (string, error) {
+	v := strings.TrimSuffix(setting("BASE_PATH"), "/")
+	if v == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(v, "/") || strings.ContainsAny(v, "?#{}*") {
+		return "", fmt.Errorf("invalid BASE_PATH %q: must be a path such as /order-service", v)
+	}
+	return v, nil
+}
+
+// withBasePath mounts router under basePath, keeping probePaths reachable
+// at the root too.
+func withBasePath(basePath string, router http.Handler) http.Handler {
+	if basePath == "" {
+		return router
+	}
+	r := chi.NewRouter()
+	r.Use(middleware.StripSlashes)
+	r.NotFound(notFound)
+	r.MethodNotAllowed(methodNotAllowed(r))
+	r.Mount(basePath, router)
+	for _, p := range probePaths {
+		r.Handle(p, router)
+	}
+	return r
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	routes := newTestHandler(t, WithBasePath("/order-service")).routes()
+
+	rec := serve(t, routes, http.MethodPost, "/order-service/orders", simpleOrder)
+	wantStatus(t, rec, http.StatusCreated)
+	id := decodeBody[Order](t, rec).ID
+	wantStatus(t, serve(t, routes, http.MethodGet, "/order-service/orders/"+id, ""), http.StatusOK)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/order-service/version", ""), http.StatusOK)
+
+	wantError(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusNotFound, CodeRouteNotFound)
+	for _, path := range probePaths {
+		wantStatus(t, serve(t, routes, http.MethodGet, path, ""), http.StatusOK)
+	}
+}
+
+func TestResolveBasePath(t *testing.T) {
+	for v, want := range map[string]string{"": "", "/": "", "/order-service": "/order-service", "/api/orders/": "/api/orders"} {
+		t.Setenv("BASE_PATH", v)
+		if got, err := resolveBasePath(); got != want || err != nil {
+			t.Errorf("BASE_PATH=%q = %q, %v; want %q", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"order-service", "/orders/{id}", "/a?b"} {
+		t.Setenv("BASE_PATH", v)
+		if _, err := resolveBasePath(); err == nil {
+			t.Errorf("BASE_PATH=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const defaultMaxBatchSize = 50
+
+// batchResult reports the outcome of one element of a batch request.
+type batchResult struct {
+	Index  int       `json:"index"`
+	Status int       `json:"status"`
+	Order  *Order    `json:"order,omitempty"`
+	Code   ErrorCode `json:"code,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	// Details lists field-level problems when Code is VALIDATION_FAILED.
+	Details []fieldError `json:"details,omitempty"`
+}
+
+// createOrderBatch places every order in a JSON array independently and
+// reports a result per element with 207 Multi-Status.
+func (h *handler) createOrderBatch(w http.ResponseWriter, r *http.Request) {
+	var raw []json.RawMessage
+	if !decodeJSONBody(w, r, &raw) {
+		return
+	}
+	if len(raw) == 0 {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "batch must contain at least one order")
+		return
+	}
+	if len(raw) > h.maxBatchSize {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("batch may contain at most %d orders", h.maxBatchSize))
+		return
+	}
+
+	results := make([]batchResult, len(raw))
+	for i, msg := range raw {
+		results[i].Index = i
+		var o Order
+		if err := json.Unmarshal(msg, &o); err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Code = CodeInvalidRequest
+			results[i].Error = err.Error()
+			continue
+		}
+		created, reqErr := h.placeOrder(r.Context(), o)
+		if reqErr != nil {
+			results[i].Status = reqErr.status
+			results[i].Code = reqErr.code
+			results[i].Error = reqErr.msg
+			results[i].Details = reqErr.details
+			continue
+		}
+		results[i].Status = http.StatusCreated
+		results[i].Order = &created
+	}
+	writeJSON(w, http.StatusMultiStatus, results)
+}
+
+// bulkStatusResult reports the outcome of one id in a bulk status update.
+type bulkStatusResult struct {
+	ID      string    `json:"id"`
+	Status  int       `json:"status"`
+	Order   *Order    `json:"order,omitempty"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Allowed []string  `json:"allowed,omitempty"`
+}
+
+// updateStatusBulk applies one status transition to many orders, so the
+// kitchen can mark a whole shelf delivered at once. Each id succeeds or
+// fails on its own and results are returned with 207 Multi-Status.
+func (h *handler) updateStatusBulk(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs    []string `json:"ids"`
+		Status string   `json:"status"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "ids must contain at least one order id")
+		return
+	}
+	if len(req.IDs) > h.maxBatchSize {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("ids may contain at most %d orders", h.maxBatchSize))
+		return
+	}
+
+	results := make([]bulkStatusResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i].ID = id
+		order, reqErr := h.transition(r.Context(), id, req.Status)
+		if reqErr != nil {
+			results[i].Status = reqErr.status
+			results[i].Code = reqErr.code
+			results[i].Error = reqErr.msg
+			if reqErr.code == CodeInvalidTransition {
+				results[i].Allowed = allowedTransitions(order.Status)
+			}
+			continue
+		}
+		results[i].Status = http.StatusOK
+		results[i].Order = &order
+	}
+	writeJSON(w, http.StatusMultiStatus, results)
+}
+
+// getOrderBatch looks up many orders at once, so a dashboard need not make
+// one request per order. Orders come back in the order their ids were
+// given; unknown and soft-deleted ids are listed under "missing" instead.
+func (h *handler) getOrderBatch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "ids must contain at least one order id")
+		return
+	}
+	if len(req.IDs) > h.maxBatchSize {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("ids may contain at most %d orders", h.maxBatchSize))
+		return
+	}
+
+	resp := struct {
+		Orders  []Order  `json:"orders"`
+		Missing []string `json:"missing"`
+	}{Orders: []Order{}, Missing: []string{}}
+	seen := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		switch order, err := h.activeOrder(id); {
+		case err == nil:
+			resp.Orders = append(resp.Orders, order)
+		case errors.Is(err, ErrNotFound):
+			resp.Missing = append(resp.Missing, id)
+		default:
+			writeRequestError(w, storeError(r.Context(), err, "load orders"))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// resolveMaxBatchSize reads MAX_BATCH_SIZE, defaulting to 50.
+func resolveMaxBatchSize() (int, error) {
+	v := setting("MAX_BATCH_SIZE")
+	if v == "" {
+		return defaultMaxBatchSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_BATCH_SIZE %q: must be a positive integer", v)
+	}
+	return n, nil
+}
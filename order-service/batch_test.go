@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreateOrderBatch(t *testing.T) {
+	h := newTestHandler(t)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders/batch",
+		`[`+simpleOrder+`, {"customer_id":"c1","items":[{"item_id":"99","quantity":1}]}, {"customer_id":7}, {"items":[{"item_id":"1","quantity":1}]}]`)
+	wantStatus(t, rec, http.StatusMultiStatus)
+	results := decodeBody[[]batchResult](t, rec)
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+
+	if r := results[0]; r.Index != 0 || r.Status != http.StatusCreated || r.Order == nil || r.Order.ID == "" {
+		t.Errorf("result 0 = %+v, want a created order", r)
+	} else if _, err := h.store.Get(r.Order.ID); err != nil {
+		t.Errorf("created order not stored: %v", err)
+	}
+	if r := results[1]; r.Index != 1 || r.Status != http.StatusBadRequest || r.Code != CodeInvalidItem || r.Order != nil {
+		t.Errorf("result 1 = %+v, want INVALID_ITEM", r)
+	}
+	if r := results[2]; r.Status != http.StatusBadRequest || r.Code != CodeInvalidRequest {
+		t.Errorf("result 2 = %+v, want INVALID_REQUEST", r)
+	}
+	if r := results[3]; r.Status != http.StatusBadRequest || r.Code != CodeValidationFailed || len(r.Details) == 0 {
+		t.Errorf("result 3 = %+v, want VALIDATION_FAILED with details", r)
+	}
+	if orders := h.store.All(); len(orders) != 1 {
+		t.Errorf("store holds %d orders, want 1", len(orders))
+	}
+}
+
+func TestCreateOrderBatchRejectsBadSize(t *testing.T) {
+	routes := newTestHandler(t, WithMaxBatchSize(2)).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders/batch", `[]`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+
+	rec = serve(t, routes, http.MethodPost, "/orders/batch", `[`+simpleOrder+`,`+simpleOrder+`,`+simpleOrder+`]`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+
+	rec = serve(t, routes, http.MethodPost, "/orders/batch", simpleOrder)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestResolveMaxBatchSize(t *testing.T) {
+	t.Setenv("MAX_BATCH_SIZE", "")
+	if n, err := resolveMaxBatchSize(); n != defaultMaxBatchSize || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxBatchSize)
+	}
+	t.Setenv("MAX_BATCH_SIZE", "10")
+	if n, err := resolveMaxBatchSize(); n != 10 || err != nil {
+		t.Errorf("MAX_BATCH_SIZE=10 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "-1", "many"} {
+		t.Setenv("MAX_BATCH_SIZE", v)
+		if _, err := resolveMaxBatchSize(); err == nil {
+			t.Errorf("MAX_BATCH_SIZE=%q accepted", v)
+		}
+	}
+}
+
+func TestUpdateStatusBulk(t *testing.T) {
+	h := newTestHandler(t)
+	for _, id := range []string{"a", "b"} {
+		if err := h.store.Save(storedOrder(id)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if _, err := h.store.UpdateStatus("b", StatusPreparing, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	rec := serve(t, h.routes(), http.MethodPost, "/orders/status/bulk", `{"ids":["a","b","missing"],"status":"preparing"}`)
+	wantStatus(t, rec, http.StatusMultiStatus)
+	results := decodeBody[[]bulkStatusResult](t, rec)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if r := results[0]; r.ID != "a" || r.Status != http.StatusOK || r.Order == nil || r.Order.Status != StatusPreparing {
+		t.Errorf("result a = %+v, want moved to preparing", r)
+	}
+	if r := results[1]; r.Status != http.StatusConflict || r.Code != CodeInvalidTransition || !slices.Equal(r.Allowed, allowedTransitions(StatusPreparing)) {
+		t.Errorf("result b = %+v, want INVALID_TRANSITION with the allowed list", r)
+	}
+	if r := results[2]; r.Status != http.StatusNotFound || r.Code != CodeOrderNotFound {
+		t.Errorf("result missing = %+v, want ORDER_NOT_FOUND", r)
+	}
+	if a, _ := h.store.Get("a"); a.Status != StatusPreparing {
+		t.Errorf("a is %s in the store", a.Status)
+	}
+}
+
+func TestUpdateStatusBulkRejectsBadSize(t *testing.T) {
+	routes := newTestHandler(t, WithMaxBatchSize(1)).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders/status/bulk", `{"ids":[],"status":"preparing"}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	rec = serve(t, routes, http.MethodPost, "/orders/status/bulk", `{"ids":["a","b"],"status":"preparing"}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestGetOrderBatch(t *testing.T) {
+	h := newTestHandler(t)
+	for _, id := range []string{"a", "b", "gone"} {
+		if err := h.store.Save(storedOrder(id)); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	routes := h.routes()
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/gone", ""), http.StatusNoContent)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/batch-get", `{"ids":["b","missing","a","b","gone"]}`)
+	wantStatus(t, rec, http.StatusOK)
+	resp := decodeBody[struct {
+		Orders  []Order  `json:"orders"`
+		Missing []string `json:"missing"`
+	}](t, rec)
+	var ids []string
+	for _, o := range resp.Orders {
+		ids = append(ids, o.ID)
+	}
+	if !slices.Equal(ids, []string{"b", "a"}) {
+		t.Errorf("orders = %v, want b, a in request order without duplicates", ids)
+	}
+	if !slices.Equal(resp.Missing, []string{"missing", "gone"}) {
+		t.Errorf("missing = %v, want missing, gone", resp.Missing)
+	}
+
+	rec = serve(t, routes, http.MethodPost, "/orders/batch-get", `{"ids":["nope"]}`)
+	if body := rec.Body.String(); !strings.Contains(body, `"orders":[]`) {
+		t.Errorf("body = %s, want an empty orders array", body)
+	}
+}
+
+func TestGetOrderBatchRejectsBadSize(t *testing.T) {
+	routes := newTestHandler(t, WithMaxBatchSize(2)).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders/batch-get", `{"ids":[]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	rec = serve(t, routes, http.MethodPost, "/orders/batch-get", `{"ids":["a","b","c"]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"golang.org/x/sync/errgroup"
+)
+
+// errCatalogUnavailable is returned when the food catalog cannot be reached.
+var errCatalogUnavailable = errors.New("food catalog service unavailable")
+
+// invalidItemError reports an item id the catalog does not know about.
+type invalidItemError struct {
+	ID string
+}
+
+func (e *invalidItemError) Error() string {
+	return fmt.Sprintf("invalid item id: %s", e.ID)
+}
+
+// errCatalogBadResponse is returned when the catalog answers with a body
+// that does not match the item contract.
+var errCatalogBadResponse = errors.New("food catalog returned an invalid response")
+
+// unavailableItemError reports an item the catalog lists but is not
+// currently selling.
+type unavailableItemError struct {
+	ID string
+}
+
+func (e *unavailableItemError) Error() string {
+	return fmt.Sprintf("item %s is not available", e.ID)
+}
+
+// insufficientStockError reports an item ordered in a larger quantity than
+// the catalog has in stock.
+type insufficientStockError struct {
+	ID        string
+	Requested int
+	Stock     int
+}
+
+func (e *insufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for item %s: requested %d, %d available", e.ID, e.Requested, e.Stock)
+}
+
+// defaultCatalogConcurrency bounds how many item lookups one order runs at
+// once, so large orders are fast without flooding the catalog.
+const defaultCatalogConcurrency = 8
+
+// resolveCatalogConcurrency reads CATALOG_CONCURRENCY, defaulting to 8.
+func resolveCatalogConcurrency() (int, error) {
+	v := setting("CATALOG_CONCURRENCY")
+	if v == "" {
+		return defaultCatalogConcurrency, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid CATALOG_CONCURRENCY %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// Circuit breaker settings for the food catalog and customer service
+// dependencies.
+const (
+	catalogBreakerFailures = 5
+	catalogBreakerCooldown = 30 * time.Second
+)
+
+// catalogBreaker stops calling the catalog after repeated outages so orders
+// fail fast instead of piling up behind timeouts.
+var catalogBreaker = newServiceBreaker("food-catalog-service", errCatalogUnavailable, catalogBreakerFailures, catalogBreakerCooldown)
+
+// newServiceBreaker returns a breaker for the named dependency that trips
+// after failures consecutive errors wrapping unavailable.
+func newServiceBreaker(name string, unavailable error, failures uint32, cooldown time.Duration) *gobreaker.CircuitBreaker {
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    name,
+		Timeout: cooldown,
+		ReadyToTrip: func(c gobreaker.Counts) bool {
+			return c.ConsecutiveFailures >= failures
+		},
+		// Only outages count against the breaker; unknown items or
+		// customers are a normal answer from a healthy service.
+		IsSuccessful: func(err error) bool {
+			return err == nil || !errors.Is(err, unavailable)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Printf("Circuit breaker %s: %s -> %s", name, from, to)
+		},
+	})
+}
+
+// catalogItem is the subset of a food catalog item the order service uses.
+type catalogItem struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Available bool    `json:"available"`
+	Price     float64 `json:"price"`
+	// Stock is how many can be ordered; nil means the catalog does not
+	// track stock for the item and any quantity is accepted.
+	Stock *int `json:"stock,omitempty"`
+	// PrepSeconds is how long one unit takes to prepare; nil means the
+	// service's PREP_TIME_PER_ITEM.
+	PrepSeconds *int `json:"prep_seconds,omitempty"`
+	// Window limits the time of day the item can be ordered; nil means
+	// any time.
+	Window *availabilityWindow `json:"available_hours,omitempty"`
+}
+
+// decodeCatalogItem parses a catalog item response, insisting that id and
+// price are present and that the id is the one requested. A missing
+// available field means the item is on sale, missing stock means
+// unlimited, missing prep_seconds means the default prep time and missing
+// available_hours means any time. Unknown fields are ignored so the catalog
+// can grow its schema.
+func decodeCatalogItem(r io.Reader, wantID string) (catalogItem, error) {
+	var raw struct {
+		ID          *string         `json:"id"`
+		Name        string          `json:"name"`
+		Available   *bool           `json:"available"`
+		Price       *float64        `json:"price"`
+		Stock       *int            `json:"stock"`
+		PrepSeconds *int            `json:"prep_seconds"`
+		Window      json.RawMessage `json:"available_hours"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return catalogItem{}, fmt.Errorf("%w: decoding item %s: %v", errCatalogBadResponse, wantID, err)
+	}
+	switch {
+	case raw.ID == nil:
+		return catalogItem{}, fmt.Errorf("%w: item %s is missing id", errCatalogBadResponse, wantID)
+	case *raw.ID != wantID:
+		return catalogItem{}, fmt.Errorf("%w: asked for item %s, got %s", errCatalogBadResponse, wantID, *raw.ID)
+	case raw.Price == nil:
+		return catalogItem{}, fmt.Errorf("%w: item %s is missing price", errCatalogBadResponse, wantID)
+	case *raw.Price < 0:
+		return catalogItem{}, fmt.Errorf("%w: item %s has negative price", errCatalogBadResponse, wantID)
+	case raw.Stock != nil && *raw.Stock < 0:
+		return catalogItem{}, fmt.Errorf("%w: item %s has negative stock", errCatalogBadResponse, wantID)
+	case raw.PrepSeconds != nil && *raw.PrepSeconds < 0:
+		return catalogItem{}, fmt.Errorf("%w: item %s has negative prep_seconds", errCatalogBadResponse, wantID)
+	}
+	var window *availabilityWindow
+	if len(raw.Window) > 0 && string(raw.Window) != "null" {
+		var err error
+		if window, err = parseWindow(raw.Window); err != nil {
+			return catalogItem{}, fmt.Errorf("%w: item %s has invalid available_hours: %v", errCatalogBadResponse, wantID, err)
+		}
+	}
+	return catalogItem{
+		ID:          *raw.ID,
+		Name:        raw.Name,
+		Available:   raw.Available == nil || *raw.Available,
+		Price:       *raw.Price,
+		Stock:       raw.Stock,
+		PrepSeconds: raw.PrepSeconds,
+		Window:      window,
+	}, nil
+}
+
+// checkStock returns an *insufficientStockError for the first item whose
+// total ordered quantity exceeds the stock the catalog reported.
+func checkStock(lines []LineItem, items map[string]catalogItem) error {
+	requested := make(map[string]int, len(lines))
+	for _, li := range lines {
+		requested[li.ItemID] += li.Quantity
+	}
+	for _, id := range (Order{Items: lines}).ItemIDs() {
+		if stock := items[id].Stock; stock != nil && requested[id] > *stock {
+			return &insufficientStockError{ID: id, Requested: requested[id], Stock: *stock}
+		}
+	}
+	return nil
+}
+
+// PriceCents converts the catalog's decimal price into integer cents.
+func (i catalogItem) PriceCents() int {
+	return int(math.Round(i.Price * 100))
+}
+
+// ValidateItems checks each item id against the catalog, in one bulk call
+// when enabled, and returns the catalog entries keyed by id.
+func (c *httpCatalog) ValidateItems(ctx context.Context, itemIDs []string) (map[string]catalogItem, error) {
+	addrs, err := c.addrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return breakerCall(func() (map[string]catalogItem, error) {
+		if c.useBulk(itemIDs) {
+			return c.validateBulk(ctx, addrs, itemIDs)
+		}
+		return c.lookupItems(ctx, addrs, itemIDs)
+	})
+}
+
+// breakerCall runs fn through catalogBreaker, reporting a tripped breaker
+// as errCatalogUnavailable.
+func breakerCall[T any](fn func() (T, error)) (T, error) {
+	var zero T
+	v, err := catalogBreaker.Execute(func() (any, error) { return fn() })
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return zero, fmt.Errorf("%w: %v", errCatalogUnavailable, err)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// lookupItems fetches every item in parallel, at most c.concurrency at a
+// time. The first failure cancels the lookups still outstanding.
+func (c *httpCatalog) lookupItems(ctx context.Context, addrs []string, itemIDs []string) (map[string]catalogItem, error) {
+	var mu sync.Mutex
+	items := make(map[string]catalogItem, len(itemIDs))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.concurrency)
+	for _, id := range itemIDs {
+		g.Go(func() error {
+			item, err := c.fetchItem(ctx, addrs, id)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			items[id] = item
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// fetchItem fetches one item. Each attempt goes to the next address in
+// addrs, so every fallback gets a try before any address is retried.
+func (c *httpCatalog) fetchItem(ctx context.Context, addrs []string, id string) (catalogItem, error) {
+	policy := c.retry
+	policy.MaxAttempts = max(policy.MaxAttempts, len(addrs))
+	resp, err := doWithRetry(c.client, policy, func(attempt int) (*http.Request, error) {
+		addr := addrs[(attempt-1)%len(addrs)]
+		return http.NewRequestWithContext(ctx, http.MethodGet, addr+"/items/"+url.PathEscape(id), nil)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			// Our caller gave up, which says nothing about the catalog's
+			// health, so keep it out of the breaker's failure count.
+			return catalogItem{}, ctx.Err()
+		}
+		if errors.Is(err, errRedirectNotAllowed) {
+			return catalogItem{}, fmt.Errorf("%w: %v", errCatalogBadResponse, err)
+		}
+		return catalogItem{}, fmt.Errorf("%w: %v", errCatalogUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return catalogItem{}, fmt.Errorf("%w: catalog returned %s", errCatalogUnavailable, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return catalogItem{}, &invalidItemError{ID: id}
+	}
+	item, err := decodeCatalogItem(resp.Body, id)
+	if err != nil {
+		return catalogItem{}, err
+	}
+	if !item.Available {
+		return catalogItem{}, &unavailableItemError{ID: id}
+	}
+	return item, nil
+}
+
+// totalCents sums the catalog price of every line item times its quantity.
+func totalCents(lines []LineItem, items map[string]catalogItem) int {
+	total := 0
+	for _, li := range lines {
+		total += items[li.ItemID].PriceCents() * li.Quantity
+	}
+	return total
+}
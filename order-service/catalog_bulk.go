@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// errBulkUnsupported is returned when the catalog has no bulk validation
+// endpoint.
+var errBulkUnsupported = errors.New("catalog does not support bulk validation")
+
+// resolveCatalogBulkValidate reads CATALOG_BULK_VALIDATE, which makes
+// multi-item orders use the catalog's POST /items/validate. Off by default.
+func resolveCatalogBulkValidate() (bool, error) {
+	v := setting("CATALOG_BULK_VALIDATE")
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid CATALOG_BULK_VALIDATE %q: must be true or false", v)
+	}
+	return enabled, nil
+}
+
+// useBulk reports whether itemIDs should be validated in one bulk call.
+func (c *httpCatalog) useBulk(itemIDs []string) bool {
+	return c.bulk && len(itemIDs) > 1 && !c.bulkUnsupported.Load()
+}
+
+// validateBulk checks itemIDs with a single POST /items/validate, falling
+// back to per-item lookups when the catalog lacks the endpoint or cannot
+// answer it. A catalog without the endpoint is remembered, so later orders
+// go straight to per-item lookups until the service restarts.
+func (c *httpCatalog) validateBulk(ctx context.Context, addrs []string, itemIDs []string) (map[string]catalogItem, error) {
+	items, err := c.lookupBulk(ctx, addrs, itemIDs)
+	switch {
+	case errors.Is(err, errBulkUnsupported):
+		log.Printf("Food catalog has no bulk validation endpoint, using per-item lookups")
+		c.bulkUnsupported.Store(true)
+	case errors.Is(err, errCatalogUnavailable):
+	default:
+		return items, err
+	}
+	return c.lookupItems(ctx, addrs, itemIDs)
+}
+
+// lookupBulk sends {"ids": [...]} to /items/validate. The catalog answers
+// {"items": [...]} with an entry for every id it knows; ids left out are
+// invalid. Errors are reported in the same order and form as lookupItems.
+func (c *httpCatalog) lookupBulk(ctx context.Context, addrs []string, itemIDs []string) (map[string]catalogItem, error) {
+	body, err := json.Marshal(map[string][]string{"ids": itemIDs})
+	if err != nil {
+		return nil, err
+	}
+	policy := c.retry
+	policy.MaxAttempts = max(policy.MaxAttempts, len(addrs))
+	resp, err := doWithRetry(c.client, policy, func(attempt int) (*http.Request, error) {
+		addr := addrs[(attempt-1)%len(addrs)]
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr+"/items/validate", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, errRedirectNotAllowed) {
+			return nil, fmt.Errorf("%w: %v", errCatalogBadResponse, err)
+		}
+		return nil, fmt.Errorf("%w: %v", errCatalogUnavailable, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented:
+		return nil, errBulkUnsupported
+	case resp.StatusCode >= 500:
+		return nil, fmt.Errorf("%w: catalog returned %s", errCatalogUnavailable, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: bulk validation returned %s", errCatalogBadResponse, resp.Status)
+	}
+
+	var raw struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: decoding bulk validation: %v", errCatalogBadResponse, err)
+	}
+	found := make(map[string]catalogItem, len(raw.Items))
+	for _, msg := range raw.Items {
+		var ref struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(msg, &ref); err != nil {
+			return nil, fmt.Errorf("%w: decoding bulk validation: %v", errCatalogBadResponse, err)
+		}
+		item, err := decodeCatalogItem(bytes.NewReader(msg), ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		found[item.ID] = item
+	}
+
+	items := make(map[string]catalogItem, len(itemIDs))
+	for _, id := range itemIDs {
+		item, ok := found[id]
+		if !ok {
+			return nil, &invalidItemError{ID: id}
+		}
+		if !item.Available {
+			return nil, &unavailableItemError{ID: id}
+		}
+		items[id] = item
+	}
+	return items, nil
+}
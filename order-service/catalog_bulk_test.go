@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bulkCatalog serves items 1 and 2 both one at a time and, when bulk is
+// set, through POST /items/validate, counting requests of each kind.
+type bulkCatalog struct {
+	bulkCalls, itemCalls atomic.Int32
+}
+
+func newBulkCatalog(t *testing.T, bulk bool) (*bulkCatalog, *httpCatalog) {
+	b := &bulkCatalog{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/items/validate":
+			b.bulkCalls.Add(1)
+			if !bulk {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `{"items":[{"id":"1","name":"Coffee","price":2.5},{"id":"2","name":"Tea","price":2}]}`)
+		case strings.HasPrefix(r.URL.Path, "/items/"):
+			b.itemCalls.Add(1)
+			id := strings.TrimPrefix(r.URL.Path, "/items/")
+			if id != "1" && id != "2" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"id":%q,"name":"Item","price":2}`, id)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+	c.bulk = true
+	return b, c
+}
+
+func TestValidateItemsBulk(t *testing.T) {
+	withFreshBreaker(t)
+	b, c := newBulkCatalog(t, true)
+
+	items, err := c.ValidateItems(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("ValidateItems: %v", err)
+	}
+	if items["1"].Name != "Coffee" || items["2"].Name != "Tea" {
+		t.Errorf("items = %+v", items)
+	}
+	var invalid *invalidItemError
+	if _, err := c.ValidateItems(context.Background(), []string{"1", "9"}); !errors.As(err, &invalid) || invalid.ID != "9" {
+		t.Errorf("unknown item = %v, want invalidItemError for 9", err)
+	}
+	if b.bulkCalls.Load() != 2 || b.itemCalls.Load() != 0 {
+		t.Errorf("bulk calls = %d, item calls = %d; want 2 and 0", b.bulkCalls.Load(), b.itemCalls.Load())
+	}
+
+	// A single item is looked up on its own.
+	if _, err := c.ValidateItems(context.Background(), []string{"1"}); err != nil {
+		t.Fatalf("ValidateItems(1): %v", err)
+	}
+	if b.bulkCalls.Load() != 2 || b.itemCalls.Load() != 1 {
+		t.Errorf("single item used the bulk endpoint")
+	}
+}
+
+// A catalog without the endpoint is asked item by item, and not asked for
+// the endpoint again.
+func TestValidateItemsBulkFallsBack(t *testing.T) {
+	withFreshBreaker(t)
+	b, c := newBulkCatalog(t, false)
+
+	for range 2 {
+		if _, err := c.ValidateItems(context.Background(), []string{"1", "2"}); err != nil {
+			t.Fatalf("ValidateItems: %v", err)
+		}
+	}
+	if b.bulkCalls.Load() != 1 || b.itemCalls.Load() != 4 {
+		t.Errorf("bulk calls = %d, item calls = %d; want 1 and 4", b.bulkCalls.Load(), b.itemCalls.Load())
+	}
+}
+
+func TestResolveCatalogBulkValidate(t *testing.T) {
+	t.Setenv("CATALOG_BULK_VALIDATE", "")
+	if on, err := resolveCatalogBulkValidate(); on || err != nil {
+		t.Errorf("default = %v, %v; want off", on, err)
+	}
+	t.Setenv("CATALOG_BULK_VALIDATE", "true")
+	if on, err := resolveCatalogBulkValidate(); !on || err != nil {
+		t.Errorf("true = %v, %v", on, err)
+	}
+	t.Setenv("CATALOG_BULK_VALIDATE", "bulk")
+	if _, err := resolveCatalogBulkValidate(); err == nil {
+		t.Error("CATALOG_BULK_VALIDATE=bulk accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultCatalogCacheTTL = 30 * time.Second
+
+var (
+	catalogCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "catalog_cache_hits_total",
+		Help: "Catalog item lookups answered from the local cache.",
+	})
+	catalogCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "catalog_cache_misses_total",
+		Help: "Catalog item lookups that had to go to the catalog.",
+	})
+)
+
+// resolveCatalogCacheTTL reads CATALOG_CACHE_TTL (a Go duration), defaulting
+// to 30s. Zero keeps nothing, though concurrent lookups are still shared.
+func resolveCatalogCacheTTL() (time.Duration, error) {
+	v := setting("CATALOG_CACHE_TTL")
+	if v == "" {
+		return defaultCatalogCacheTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid CATALOG_CACHE_TTL %q: must be a non-negative duration", v)
+	}
+	return d, nil
+}
+
+// cachedCatalog remembers successfully validated items for ttl so popular
+// items are not fetched on every order. Failures are never cached, and nor
+// are items reporting stock or available hours, since those have to be
+// current for the order to be checked against them.
+//
+// Misses are coalesced: a lookup of an item that is already being fetched
+// waits for that fetch instead of asking the catalog again, so a burst of
+// orders for a popular item that all miss together costs one catalog call.
+type cachedCatalog struct {
+	next CatalogClient
+	ttl  time.Duration
+	now  func() time.Time
+	// fetches is keyed by item id.
+	fetches singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]cachedItem
+}
+
+type cachedItem struct {
+	item    catalogItem
+	expires time.Time
+}
+
+func newCachedCatalog(next CatalogClient, ttl time.Duration) *cachedCatalog {
+	return &cachedCatalog{next: next, ttl: ttl, now: time.Now, entries: make(map[string]cachedItem)}
+}
+
+// withFreshCatalog marks ctx so cachedCatalog fetches every item from the
+// catalog rather than answering from the cache.
+func withFreshCatalog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshCatalogKey, true)
+}
+
+// cacheable reports whether item holds only fields that can be served stale.
+func cacheable(item catalogItem) bool {
+	return item.Stock == nil && item.Window == nil
+}
+
+func (c *cachedCatalog) ValidateItems(ctx context.Context, itemIDs []string) (map[string]catalogItem, error) {
+	items := make(map[string]catalogItem, len(itemIDs))
+	missing := itemIDs
+	if fresh, _ := ctx.Value(freshCatalogKey).(bool); !fresh {
+		missing = nil
+		c.mu.Lock()
+		now := c.now()
+		for _, id := range itemIDs {
+			if e, ok := c.entries[id]; ok && now.Before(e.expires) {
+				items[id] = e.item
+				catalogCacheHits.Inc()
+				continue
+			}
+			delete(c.entries, id)
+			missing = append(missing, id)
+			catalogCacheMisses.Inc()
+		}
+		c.mu.Unlock()
+	}
+	if len(missing) == 0 {
+		return items, nil
+	}
+
+	fetched, err := c.fetch(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	expires := c.now().Add(c.ttl)
+	for id, item := range fetched {
+		if c.ttl > 0 && cacheable(item) {
+			c.entries[id] = cachedItem{item: item, expires: expires}
+		} else {
+			delete(c.entries, id)
+		}
+		items[id] = item
+	}
+	c.mu.Unlock()
+	return items, nil
+}
+
+// fetch looks the missing items up through next, joining any fetch of the
+// same item already in flight. The items this call is first to ask for are
+// fetched together in one ValidateItems, so bulk validation still applies.
+// Shared fetches run detached from ctx: a caller giving up returns at once
+// without failing the others, and the fetch is still bounded by
+// CATALOG_TIMEOUT.
+func (c *cachedCatalog) fetch(ctx context.Context, missing []string) (map[string]catalogItem, error) {
+	fetchCtx := context.WithoutCancel(ctx)
+	together := sync.OnceValues(func() (map[string]catalogItem, error) {
+		return c.next.ValidateItems(fetchCtx, missing)
+	})
+	results := make([]<-chan singleflight.Result, len(missing))
+	for i, id := range missing {
+		results[i] = c.fetches.DoChan(id, func() (any, error) {
+			items, err := together()
+			if errID, ok := itemErrorID(err); ok && errID != id {
+				// Another item sank the combined lookup, which says
+				// nothing about this one, and other orders may be
+				// waiting on it.
+				items, err = c.next.ValidateItems(fetchCtx, []string{id})
+			}
+			if err != nil {
+				return nil, err
+			}
+			return items[id], nil
+		})
+	}
+
+	items := make(map[string]catalogItem, len(missing))
+	for i, ch := range results {
+		select {
+		case res := <-ch:
+			if res.Err != nil {
+				return nil, res.Err
+			}
+			items[missing[i]] = res.Val.(catalogItem)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return items, nil
+}
+
+// itemErrorID returns the item an item-level catalog error is about.
+func itemErrorID(err error) (string, bool) {
+	var invalid *invalidItemError
+	if errors.As(err, &invalid) {
+		return invalid.ID, true
+	}
+	var unavailable *unavailableItemError
+	if errors.As(err, &unavailable) {
+		return unavailable.ID, true
+	}
+	return "", false
+}
+
+func (c *cachedCatalog) Ping(ctx context.Context) error {
+	return c.next.Ping(ctx)
+}
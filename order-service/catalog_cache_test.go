@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingCatalog answers from items, counting the ids it is asked for.
+func countingCatalog(items map[string]catalogItem) *stubCatalog {
+	return &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		found := map[string]catalogItem{}
+		for _, id := range ids {
+			item, ok := items[id]
+			if !ok {
+				return nil, &invalidItemError{ID: id}
+			}
+			found[id] = item
+		}
+		return found, nil
+	}}
+}
+
+func newTestCachedCatalog(next CatalogClient, now *time.Time) *cachedCatalog {
+	c := newCachedCatalog(next, 30*time.Second)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestCachedCatalogServesRepeatLookups(t *testing.T) {
+	now := testTime
+	next := countingCatalog(map[string]catalogItem{"1": {ID: "1", Price: 2.5, Available: true}})
+	c := newTestCachedCatalog(next, &now)
+
+	for range 3 {
+		items, err := c.ValidateItems(context.Background(), []string{"1"})
+		if err != nil || items["1"].Price != 2.5 {
+			t.Fatalf("ValidateItems = %+v, %v", items, err)
+		}
+	}
+	if n := next.callCount(); n != 1 {
+		t.Errorf("catalog called %d times, want 1", n)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, err := c.ValidateItems(context.Background(), []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := next.callCount(); n != 2 {
+		t.Errorf("catalog called %d times after the entry expired, want 2", n)
+	}
+}
+
+func TestCachedCatalogDoesNotCacheFailures(t *testing.T) {
+	now := testTime
+	next := countingCatalog(map[string]catalogItem{})
+	c := newTestCachedCatalog(next, &now)
+
+	for range 2 {
+		var invalid *invalidItemError
+		if _, err := c.ValidateItems(context.Background(), []string{"9"}); !errors.As(err, &invalid) {
+			t.Fatalf("ValidateItems error = %v, want an invalid item", err)
+		}
+	}
+	if n := next.callCount(); n != 2 {
+		t.Errorf("catalog called %d times, want every failure retried", n)
+	}
+}
+
+// Stock and available hours must be current, so items reporting them are
+// fetched every time.
+func TestCachedCatalogSkipsItemsWithStockOrHours(t *testing.T) {
+	now := testTime
+	stock := 4
+	next := countingCatalog(map[string]catalogItem{
+		"1": {ID: "1", Available: true, Stock: &stock},
+		"2": {ID: "2", Available: true, Window: &availabilityWindow{}},
+	})
+	c := newTestCachedCatalog(next, &now)
+
+	for range 2 {
+		if _, err := c.ValidateItems(context.Background(), []string{"1", "2"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := next.callCount(); n != 2 {
+		t.Errorf("catalog called %d times, want 2", n)
+	}
+}
+
+func TestCachedCatalogFreshContext(t *testing.T) {
+	now := testTime
+	next := countingCatalog(map[string]catalogItem{"1": {ID: "1", Available: true}})
+	c := newTestCachedCatalog(next, &now)
+
+	c.ValidateItems(context.Background(), []string{"1"})
+	c.ValidateItems(withFreshCatalog(context.Background()), []string{"1"})
+	if n := next.callCount(); n != 2 {
+		t.Errorf("catalog called %d times, want the fresh lookup to skip the cache", n)
+	}
+}
+
+func TestResolveCatalogCacheTTL(t *testing.T) {
+	t.Setenv("CATALOG_CACHE_TTL", "")
+	if d, err := resolveCatalogCacheTTL(); d != defaultCatalogCacheTTL || err != nil {
+		t.Errorf("default = %v, %v", d, err)
+	}
+	t.Setenv("CATALOG_CACHE_TTL", "0")
+	if d, err := resolveCatalogCacheTTL(); d != 0 || err != nil {
+		t.Errorf("0 = %v, %v; want the cache disabled", d, err)
+	}
+	t.Setenv("CATALOG_CACHE_TTL", "-1s")
+	if _, err := resolveCatalogCacheTTL(); err == nil {
+		t.Error("negative CATALOG_CACHE_TTL accepted")
+	}
+}
+
+// An item pulled from sale after it was cached is reported by revalidation
+// straight away.
+func TestRevalidateBypassesCatalogCache(t *testing.T) {
+	available := true
+	next := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		if !available {
+			return nil, &unavailableItemError{ID: ids[0]}
+		}
+		return map[string]catalogItem{ids[0]: {ID: ids[0], Price: 1, Available: true}}, nil
+	}}
+	routes := newTestHandler(t, WithCatalog(newCachedCatalog(next, time.Hour))).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	available = false
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/revalidate", "")
+	wantStatus(t, rec, http.StatusOK)
+	report := decodeBody[revalidationReport](t, rec)
+	if report.Valid || report.Items[0].Outcome != itemUnavailable {
+		t.Errorf("report = %+v, want the item reported unavailable", report)
+	}
+}
+
+func TestCachedCatalogSharesConcurrentFetches(t *testing.T) {
+	b, srv := newBlockingCatalog(t)
+	// With no TTL nothing is kept, but lookups in flight are still shared.
+	c := newCachedCatalog(newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8), 0)
+
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			items, err := c.ValidateItems(context.Background(), []string{"7"})
+			if err == nil && items["7"].ID != "7" {
+				err = fmt.Errorf("items = %+v, want 7", items)
+			}
+			results <- err
+		}()
+	}
+	<-b.started
+	// Give the other lookups time to join before the fetch finishes.
+	time.Sleep(50 * time.Millisecond)
+	close(b.release)
+	wg.Wait()
+	close(results)
+	for err := range results {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if n := b.requests.Load(); n != 1 {
+		t.Errorf("catalog got %d requests, want 1", n)
+	}
+
+	if _, err := c.ValidateItems(context.Background(), []string{"7"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.requests.Load(); n != 2 {
+		t.Errorf("catalog got %d requests after the shared fetch, want 2 with no TTL", n)
+	}
+}
+
+// A caller that gives up returns at once, but the fetch carries on for the
+// callers still waiting.
+func TestCachedCatalogCallerLeavingEarly(t *testing.T) {
+	b, srv := newBlockingCatalog(t)
+	c := newCachedCatalog(newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8), time.Minute)
+
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := c.ValidateItems(context.Background(), []string{"7"})
+		waiting <- err
+	}()
+	<-b.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ValidateItems(ctx, []string{"7"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled lookup error = %v, want context.Canceled", err)
+	}
+	close(b.release)
+	if err := <-waiting; err != nil {
+		t.Errorf("remaining lookup failed: %v", err)
+	}
+	if n := b.requests.Load(); n != 1 {
+		t.Errorf("catalog got %d requests, want 1", n)
+	}
+}
+
+// Joining an order whose lookup fails on one of its other items does not
+// fail the joiner: that item is looked up again on its own.
+func TestCachedCatalogJoinedLookupFailsElsewhere(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	next := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		if len(ids) > 1 {
+			started <- struct{}{}
+			<-release
+			return nil, &invalidItemError{ID: "bad"}
+		}
+		return map[string]catalogItem{ids[0]: {ID: ids[0], Price: 1, Available: true}}, nil
+	}}
+	c := newCachedCatalog(next, time.Minute)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.ValidateItems(context.Background(), []string{"1", "bad"})
+		first <- err
+	}()
+	<-started
+	joined := make(chan error, 1)
+	go func() {
+		items, err := c.ValidateItems(context.Background(), []string{"1"})
+		if err == nil && items["1"].ID != "1" {
+			err = fmt.Errorf("items = %+v, want 1", items)
+		}
+		joined <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	var invalid *invalidItemError
+	if err := <-first; !errors.As(err, &invalid) || invalid.ID != "bad" {
+		t.Errorf("order with the bad item = %v, want it reported invalid", err)
+	}
+	if err := <-joined; err != nil {
+		t.Errorf("joined lookup = %v, want item 1", err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CatalogClient validates order items against the food catalog.
+type CatalogClient interface {
+	// ValidateItems returns the catalog entry for every id, an
+	// *invalidItemError for the first unknown id, or an error wrapping
+	// errCatalogUnavailable when the catalog cannot answer.
+	ValidateItems(ctx context.Context, itemIDs []string) (map[string]catalogItem, error)
+	// Ping reports whether the catalog is reachable.
+	Ping(ctx context.Context) error
+}
+
+// httpCatalog talks to the real food-catalog-service over HTTP.
+type httpCatalog struct {
+	// baseURL is the catalog's address; when empty it is discovered
+	// through Consul on every call.
+	baseURL string
+	// fallbacks are tried in order when the primary address fails.
+	fallbacks []string
+	client    *http.Client
+	retry     retryPolicy
+	// concurrency bounds how many items one call looks up at once.
+	concurrency int
+	// bulk validates multi-item orders with one POST /items/validate;
+	// bulkUnsupported is set once the catalog turns out not to have it.
+	bulk            bool
+	bulkUnsupported atomic.Bool
+}
+
+// newHTTPCatalog returns a catalog client for baseURL (or discovery, if
+// empty) that falls back to fallbacks in turn. Each call times out after
+// timeout and is retried per retry; an order's items are looked up at most
+// concurrency at a time.
+func newHTTPCatalog(baseURL string, fallbacks []string, timeout time.Duration, retry retryPolicy, concurrency int) *httpCatalog {
+	c := &httpCatalog{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		client:      newOutboundClient(timeout),
+		retry:       retry,
+		concurrency: concurrency,
+	}
+	for _, f := range fallbacks {
+		c.fallbacks = append(c.fallbacks, strings.TrimSuffix(f, "/"))
+	}
+	return c
+}
+
+// addrs returns the primary catalog address followed by the fallbacks. If
+// discovery fails, the fallbacks alone are used when there are any.
+func (c *httpCatalog) addrs(ctx context.Context) ([]string, error) {
+	primary := c.baseURL
+	if primary == "" {
+		addr, err := findService(ctx, "food-catalog-service")
+		if err != nil && len(c.fallbacks) == 0 {
+			return nil, fmt.Errorf("finding catalog service: %w", err)
+		}
+		primary = addr
+	}
+	if primary == "" {
+		return c.fallbacks, nil
+	}
+	return append([]string{primary}, c.fallbacks...), nil
+}
+
+// Ping sends a quick HEAD to the catalog's /health endpoint, succeeding if
+// any address answers.
+func (c *httpCatalog) Ping(ctx context.Context) error {
+	addrs, err := c.addrs(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	for _, addr := range addrs {
+		if err = c.pingAddr(ctx, addr); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *httpCatalog) pingAddr(ctx context.Context, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, addr+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("catalog health returned %s", resp.Status)
+	}
+	return nil
+}
+
+// mockCatalog is an in-process stand-in that accepts only allowlisted ids,
+// so demos and CI can run without the catalog service.
+type mockCatalog struct {
+	items map[string]catalogItem
+}
+
+// newMockCatalog parses an allowlist such as "1=2.50,2=5,3", where the
+// optional "=price" sets the item's price.
+func newMockCatalog(allowlist string) (*mockCatalog, error) {
+	m := &mockCatalog{items: make(map[string]catalogItem)}
+	for _, entry := range parseList(allowlist) {
+		id, priceStr, hasPrice := strings.Cut(entry, "=")
+		item := catalogItem{ID: id, Name: id, Available: true}
+		if hasPrice {
+			price, err := strconv.ParseFloat(priceStr, 64)
+			if err != nil || price < 0 {
+				return nil, fmt.Errorf("invalid price %q for mock catalog item %s", priceStr, id)
+			}
+			item.Price = price
+		}
+		m.items[id] = item
+	}
+	return m, nil
+}
+
+func (m *mockCatalog) ValidateItems(_ context.Context, itemIDs []string) (map[string]catalogItem, error) {
+	items := make(map[string]catalogItem, len(itemIDs))
+	for _, id := range itemIDs {
+		item, ok := m.items[id]
+		if !ok {
+			return nil, &invalidItemError{ID: id}
+		}
+		items[id] = item
+	}
+	return items, nil
+}
+
+func (m *mockCatalog) Ping(context.Context) error { return nil }
+
+// validationMode controls what happens when catalog validation cannot run.
+type validationMode string
+
+const (
+	// validationStrict rejects orders on invalid items or catalog outages.
+	validationStrict validationMode = "strict"
+	// validationLenient still rejects invalid items, but accepts orders
+	// flagged validation_skipped when the catalog is unreachable.
+	validationLenient validationMode = "lenient"
+	// validationOff accepts every order without consulting the catalog.
+	validationOff validationMode = "off"
+)
+
+// resolveValidationMode reads CATALOG_VALIDATION, defaulting to strict.
+func resolveValidationMode() (validationMode, error) {
+	switch m := validationMode(setting("CATALOG_VALIDATION")); m {
+	case "":
+		return validationStrict, nil
+	case validationStrict, validationLenient, validationOff:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid CATALOG_VALIDATION %q: must be strict, lenient or off", m)
+	}
+}
+
+// resolveCatalogMode reads CATALOG_MODE: "real" by default, or "mock".
+func resolveCatalogMode() (string, error) {
+	switch mode := setting("CATALOG_MODE"); mode {
+	case "", "real":
+		return "real", nil
+	case "mock":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid CATALOG_MODE %q: must be real or mock", mode)
+	}
+}
+
+// newCatalogClient builds the catalog described by cfg. The mock only
+// accepts the ids in CatalogMockItems (see newMockCatalog); the real client
+// uses FOOD_CATALOG_URL when set and Consul discovery otherwise, then
+// FOOD_CATALOG_FALLBACKS, validating in bulk if CatalogBulkValidate is set.
+func newCatalogClient(cfg Config) (CatalogClient, error) {
+	if cfg.CatalogMode == "mock" {
+		return newMockCatalog(cfg.CatalogMockItems)
+	}
+	c := newHTTPCatalog(cfg.CatalogURL, cfg.CatalogFallbacks, cfg.CatalogTimeout, defaultRetryPolicy, cfg.CatalogConcurrency)
+	c.bulk = cfg.CatalogBulkValidate
+	return c, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMockCatalog(t *testing.T) {
+	m, err := newMockCatalog("1=2.50, 2 ,3=0")
+	if err != nil {
+		t.Fatalf("newMockCatalog: %v", err)
+	}
+	items, err := m.ValidateItems(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("ValidateItems: %v", err)
+	}
+	if items["1"].Price != 2.50 || !items["1"].Available || items["2"].Price != 0 {
+		t.Errorf("items = %+v", items)
+	}
+
+	_, err = m.ValidateItems(context.Background(), []string{"1", "9"})
+	var invalid *invalidItemError
+	if !errors.As(err, &invalid) || invalid.ID != "9" {
+		t.Fatalf("ValidateItems with an unlisted id = %v, want invalidItemError for 9", err)
+	}
+	if err := m.Ping(context.Background()); err != nil {
+		t.Errorf("Ping = %v", err)
+	}
+}
+
+func TestNewMockCatalogRejectsBadPrice(t *testing.T) {
+	for _, list := range []string{"1=cheap", "1=-1"} {
+		if _, err := newMockCatalog(list); err == nil {
+			t.Errorf("newMockCatalog(%q) accepted", list)
+		}
+	}
+}
+
+func TestResolveCatalogMode(t *testing.T) {
+	for v, want := range map[string]string{"": "real", "real": "real", "mock": "mock"} {
+		t.Setenv("CATALOG_MODE", v)
+		if mode, err := resolveCatalogMode(); mode != want || err != nil {
+			t.Errorf("CATALOG_MODE=%q = %q, %v; want %q", v, mode, err, want)
+		}
+	}
+	t.Setenv("CATALOG_MODE", "fake")
+	if _, err := resolveCatalogMode(); err == nil {
+		t.Error("CATALOG_MODE=fake accepted")
+	}
+}
+
+func TestNewCatalogClient(t *testing.T) {
+	c, err := newCatalogClient(Config{CatalogMode: "mock", CatalogMockItems: "1"})
+	if err != nil {
+		t.Fatalf("newCatalogClient: %v", err)
+	}
+	if _, ok := c.(*mockCatalog); !ok {
+		t.Errorf("CATALOG_MODE=mock built a %T", c)
+	}
+	c, err = newCatalogClient(Config{CatalogMode: "real", CatalogURL: "http://catalog:8080"})
+	if err != nil {
+		t.Fatalf("newCatalogClient: %v", err)
+	}
+	if _, ok := c.(*httpCatalog); !ok {
+		t.Errorf("CATALOG_MODE=real built a %T", c)
+	}
+}
+
+func TestResolveValidationMode(t *testing.T) {
+	for v, want := range map[string]validationMode{"": validationStrict, "strict": validationStrict, "lenient": validationLenient, "off": validationOff} {
+		t.Setenv("CATALOG_VALIDATION", v)
+		if mode, err := resolveValidationMode(); mode != want || err != nil {
+			t.Errorf("CATALOG_VALIDATION=%q = %q, %v; want %q", v, mode, err, want)
+		}
+	}
+	t.Setenv("CATALOG_VALIDATION", "loose")
+	if _, err := resolveValidationMode(); err == nil {
+		t.Error("CATALOG_VALIDATION=loose accepted")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveCatalogConcurrency(t *testing.T) {
+	t.Setenv("CATALOG_CONCURRENCY", "")
+	if n, err := resolveCatalogConcurrency(); n != defaultCatalogConcurrency || err != nil {
+		t.Errorf("default = %d, %v", n, err)
+	}
+	t.Setenv("CATALOG_CONCURRENCY", "3")
+	if n, err := resolveCatalogConcurrency(); n != 3 || err != nil {
+		t.Errorf("3 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "-2", "many"} {
+		t.Setenv("CATALOG_CONCURRENCY", v)
+		if _, err := resolveCatalogConcurrency(); err == nil {
+			t.Errorf("CATALOG_CONCURRENCY=%q accepted", v)
+		}
+	}
+}
+
+// concurrencyCatalog serves items after a short pause, recording the most
+// requests it was handling at once.
+func concurrencyCatalog(t *testing.T) (*atomic.Int32, *httptest.Server) {
+	var current, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		defer current.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"id":%q,"price":1}`, strings.TrimPrefix(r.URL.Path, "/items/"))
+	}))
+	t.Cleanup(srv.Close)
+	return &peak, srv
+}
+
+func TestLookupItemsHonoursConcurrency(t *testing.T) {
+	peak, srv := concurrencyCatalog(t)
+	c := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 2)
+
+	items, err := c.lookupItems(context.Background(), []string{srv.URL}, []string{"1", "2", "3", "4", "5", "6"})
+	if err != nil || len(items) != 6 {
+		t.Fatalf("lookupItems = %d items, %v; want 6", len(items), err)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("catalog saw %d lookups at once, want at most 2", p)
+	}
+}
+
+func TestLookupEachHonoursCatalogConcurrency(t *testing.T) {
+	peak, srv := concurrencyCatalog(t)
+	catalog := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+	h := newTestHandler(t, WithCatalog(catalog), WithCatalogConcurrency(3))
+
+	var lines []LineItem
+	for _, id := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		lines = append(lines, LineItem{ItemID: id, Quantity: 1})
+	}
+	_, checks := h.lookupEach(context.Background(), lines)
+	for _, c := range checks {
+		if c.Outcome != itemValid {
+			t.Errorf("check = %+v, want valid", c)
+		}
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("catalog saw %d lookups at once, want at most 3", p)
+	}
+}
+
+// The first item to fail cancels the lookups still outstanding.
+func TestLookupItemsFirstFailureCancelsRest(t *testing.T) {
+	var cancelled atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/items/bad" {
+			http.NotFound(w, r)
+			return
+		}
+		<-r.Context().Done()
+		cancelled.Add(1)
+	}))
+	defer srv.Close()
+	c := newHTTPCatalog(srv.URL, nil, 5*time.Second, retryPolicy{MaxAttempts: 1}, 3)
+
+	start := time.Now()
+	_, err := c.lookupItems(context.Background(), []string{srv.URL}, []string{"1", "2", "bad"})
+	var invalid *invalidItemError
+	if !errors.As(err, &invalid) || invalid.ID != "bad" {
+		t.Fatalf("lookupItems = %v, want bad reported invalid", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("lookupItems took %s, want it to stop at the first failure", d)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for cancelled.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := cancelled.Load(); n != 2 {
+		t.Errorf("%d outstanding lookups were cancelled, want 2", n)
+	}
+}
+
+func TestLoadConfigCatalogConcurrency(t *testing.T) {
+	t.Setenv("CATALOG_CONCURRENCY", "4")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.CatalogConcurrency != 4 {
+		t.Errorf("CatalogConcurrency = %d, want 4", cfg.CatalogConcurrency)
+	}
+	t.Setenv("CATALOG_CONCURRENCY", "0")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("LoadConfig accepted CATALOG_CONCURRENCY=0")
+	}
+}
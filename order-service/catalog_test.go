@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+)
+
+// blockingCatalog serves GET /items/{id} once release is closed, counting
+// requests and reporting on cancelled when a request's context ends first.
+type blockingCatalog struct {
+	requests  atomic.Int32
+	started   chan struct{}
+	release   chan struct{}
+	cancelled chan struct{}
+}
+
+func newBlockingCatalog(t *testing.T) (*blockingCatalog, *httptest.Server) {
+	b := &blockingCatalog{
+		started:   make(chan struct{}, 10),
+		release:   make(chan struct{}),
+		cancelled: make(chan struct{}, 10),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.requests.Add(1)
+		b.started <- struct{}{}
+		select {
+		case <-b.release:
+			fmt.Fprintf(w, `{"id":%q,"name":"Item","price":2.5}`, strings.TrimPrefix(r.URL.Path, "/items/"))
+		case <-r.Context().Done():
+			b.cancelled <- struct{}{}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return b, srv
+}
+
+// fakeCatalog serves GET /items/{id} from items, answering 404 for the rest.
+func fakeCatalog(t *testing.T, items map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := items[strings.TrimPrefix(r.URL.Path, "/items/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// withFreshBreaker gives the test its own catalog breaker, so outages it
+// provokes do not trip the shared one for other tests.
+func withFreshBreaker(t *testing.T) {
+	prev := catalogBreaker
+	catalogBreaker = newServiceBreaker("food-catalog-service", errCatalogUnavailable, catalogBreakerFailures, catalogBreakerCooldown)
+	t.Cleanup(func() { catalogBreaker = prev })
+}
+
+func TestValidateItems(t *testing.T) {
+	srv := fakeCatalog(t, map[string]string{
+		"1": `{"id":"1","name":"Burger","price":8.5}`,
+		"2": `{"id":"2","name":"Fries","price":3}`,
+	})
+	c := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+
+	items, err := c.ValidateItems(context.Background(), []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("ValidateItems: %v", err)
+	}
+	if items["1"].Name != "Burger" || items["2"].PriceCents() != 300 {
+		t.Errorf("items = %+v", items)
+	}
+
+	_, err = c.ValidateItems(context.Background(), []string{"1", "9"})
+	var invalid *invalidItemError
+	if !errors.As(err, &invalid) || invalid.ID != "9" {
+		t.Fatalf("unknown item error = %v, want an invalidItemError for 9", err)
+	}
+}
+
+func TestValidateItemsCatalogDown(t *testing.T) {
+	withFreshBreaker(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	c := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+
+	if _, err := c.ValidateItems(context.Background(), []string{"1"}); !errors.Is(err, errCatalogUnavailable) {
+		t.Fatalf("error = %v, want errCatalogUnavailable", err)
+	}
+}
+
+func TestCreateOrderRejectsUnknownItem(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"99","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidItem)
+
+	rec = serve(t, routes, http.MethodGet, "/orders", "")
+	if orders := decodeBody[[]Order](t, rec); len(orders) != 0 {
+		t.Errorf("rejected order was stored: %+v", orders)
+	}
+}
+
+func TestCreateOrderCatalogUnavailable(t *testing.T) {
+	catalog := &stubCatalog{validate: func(context.Context, []string) (map[string]catalogItem, error) {
+		return nil, fmt.Errorf("%w: connection refused", errCatalogUnavailable)
+	}}
+	routes := newTestHandler(t, WithCatalog(catalog)).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCatalogUnavailable)
+}
+
+func TestServiceBreakerTripsOnOutages(t *testing.T) {
+	b := newServiceBreaker("test", errCatalogUnavailable, 3, time.Hour)
+	outage := func() (any, error) { return nil, fmt.Errorf("%w: refused", errCatalogUnavailable) }
+	unknown := func() (any, error) { return nil, &invalidItemError{ID: "9"} }
+
+	// Unknown items are a healthy answer and reset the count.
+	b.Execute(outage)
+	b.Execute(outage)
+	b.Execute(unknown)
+	b.Execute(outage)
+	if b.State() != gobreaker.StateClosed {
+		t.Fatalf("state = %s after broken-up failures, want closed", b.State())
+	}
+	b.Execute(outage)
+	b.Execute(outage)
+	if b.State() != gobreaker.StateOpen {
+		t.Fatalf("state = %s after 3 outages in a row, want open", b.State())
+	}
+}
+
+func TestBreakerCallFailsFastWhenOpen(t *testing.T) {
+	withFreshBreaker(t)
+	catalogBreaker = newServiceBreaker("food-catalog-service", errCatalogUnavailable, 1, time.Hour)
+	breakerCall(func() (int, error) { return 0, errCatalogUnavailable })
+
+	called := false
+	_, err := breakerCall(func() (int, error) {
+		called = true
+		return 1, nil
+	})
+	if !errors.Is(err, errCatalogUnavailable) || called {
+		t.Fatalf("open breaker: err = %v, called = %v; want errCatalogUnavailable without a call", err, called)
+	}
+}
+
+func TestTotalCents(t *testing.T) {
+	items := map[string]catalogItem{"1": {Price: 2.5}, "2": {Price: 0.1}, "3": {Price: 19.99}}
+	lines := []LineItem{{ItemID: "1", Quantity: 2}, {ItemID: "2", Quantity: 3}, {ItemID: "3", Quantity: 1}}
+	if got := totalCents(lines, items); got != 500+30+1999 {
+		t.Fatalf("totalCents = %d, want %d", got, 500+30+1999)
+	}
+	// 0.29 * 100 is 28.999... in floating point.
+	if got := (catalogItem{Price: 0.29}).PriceCents(); got != 29 {
+		t.Errorf("PriceCents(0.29) = %d, want 29", got)
+	}
+}
+
+func TestCreateOrderComputesTotal(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":2},{"item_id":"2","quantity":1},{"item_id":"3","quantity":4}]}`)
+	if order.TotalCents != 1000 {
+		t.Fatalf("total_cents = %d, want 1000", order.TotalCents)
+	}
+}
+
+func TestCreateOrderLenientValidation(t *testing.T) {
+	catalog := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		if ids[0] == "99" {
+			return nil, &invalidItemError{ID: "99"}
+		}
+		return nil, fmt.Errorf("%w: connection refused", errCatalogUnavailable)
+	}}
+	routes := newTestHandler(t, WithCatalog(catalog), WithValidationMode(validationLenient)).routes()
+
+	order := createTestOrder(t, routes, simpleOrder)
+	if !order.ValidationSkipped {
+		t.Error("order accepted during an outage is not flagged validation_skipped")
+	}
+	// Lenient mode only forgives outages, not items the catalog rejects.
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"99","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidItem)
+}
+
+func TestCreateOrderValidationOff(t *testing.T) {
+	catalog := &stubCatalog{}
+	routes := newTestHandler(t, WithCatalog(catalog), WithValidationMode(validationOff)).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"anything","quantity":1}]}`)
+	if !order.ValidationSkipped {
+		t.Error("order is not flagged validation_skipped")
+	}
+	if catalog.callCount() != 0 {
+		t.Errorf("catalog was called %d times", catalog.callCount())
+	}
+}
+
+func TestCreateOrderValidatedIsNotSkipped(t *testing.T) {
+	order := createTestOrder(t, newTestHandler(t).routes(), simpleOrder)
+	if order.ValidationSkipped {
+		t.Error("validated order is flagged validation_skipped")
+	}
+}
+
+func TestDecodeCatalogItem(t *testing.T) {
+	item, err := decodeCatalogItem(strings.NewReader(`{"id":"1","name":"Pho","price":9.5,"stock":3,"colour":"red"}`), "1")
+	if err != nil {
+		t.Fatalf("decodeCatalogItem: %v", err)
+	}
+	if item.Name != "Pho" || item.Price != 9.5 || !item.Available || item.Stock == nil || *item.Stock != 3 || item.Window != nil {
+		t.Errorf("item = %+v", item)
+	}
+
+	for name, body := range map[string]string{
+		"not json":       `<html>`,
+		"missing id":     `{"name":"Pho","price":1}`,
+		"other id":       `{"id":"2","price":1}`,
+		"missing price":  `{"id":"1"}`,
+		"negative price": `{"id":"1","price":-1}`,
+		"negative stock": `{"id":"1","price":1,"stock":-1}`,
+		"negative prep":  `{"id":"1","price":1,"prep_seconds":-5}`,
+		"bad window":     `{"id":"1","price":1,"available_hours":"always"}`,
+	} {
+		if _, err := decodeCatalogItem(strings.NewReader(body), "1"); !errors.Is(err, errCatalogBadResponse) {
+			t.Errorf("%s: error = %v, want errCatalogBadResponse", name, err)
+		}
+	}
+}
+
+func TestCreateOrderCatalogBadResponse(t *testing.T) {
+	withFreshBreaker(t)
+	srv := fakeCatalog(t, map[string]string{"1": `{"id":"1","name":"Pho"}`})
+	catalog := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+	rec := serve(t, newTestHandler(t, WithCatalog(catalog)).routes(), http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusBadGateway, CodeCatalogBadResponse)
+}
+
+func TestCheckStock(t *testing.T) {
+	three := 3
+	items := map[string]catalogItem{"1": {ID: "1", Stock: &three}, "2": {ID: "2"}}
+
+	if err := checkStock([]LineItem{{ItemID: "1", Quantity: 3}, {ItemID: "2", Quantity: 500}}, items); err != nil {
+		t.Errorf("within stock = %v", err)
+	}
+	// Repeated lines count together.
+	err := checkStock([]LineItem{{ItemID: "1", Quantity: 2}, {ItemID: "1", Quantity: 2}}, items)
+	var short *insufficientStockError
+	if !errors.As(err, &short) || short.ID != "1" || short.Requested != 4 || short.Stock != 3 {
+		t.Fatalf("checkStock = %v, want item 1 short by one", err)
+	}
+}
+
+func TestCreateOrderInsufficientStock(t *testing.T) {
+	two := 2
+	catalog := &stubCatalog{validate: func(context.Context, []string) (map[string]catalogItem, error) {
+		return map[string]catalogItem{"1": {ID: "1", Price: 1, Available: true, Stock: &two}}, nil
+	}}
+	h := newTestHandler(t, WithCatalog(catalog))
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":3}]}`)
+	wantError(t, rec, http.StatusConflict, CodeInsufficientStock)
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "requested 3, 2 available") {
+		t.Errorf("error = %q", msg)
+	}
+	if len(h.store.All()) != 0 {
+		t.Error("order was stored despite the shortage")
+	}
+}
+
+func TestValidateItemsFallsBack(t *testing.T) {
+	withFreshBreaker(t)
+	var primaryHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	fallback := fakeCatalog(t, map[string]string{
+		"1":       `{"id":"1","name":"Coffee","price":2.5}`,
+		"/health": `{"status":"ok"}`,
+	})
+
+	c := newHTTPCatalog(primary.URL, []string{fallback.URL + "/"}, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+	items, err := c.ValidateItems(context.Background(), []string{"1"})
+	if err != nil {
+		t.Fatalf("ValidateItems: %v", err)
+	}
+	if items["1"].Name != "Coffee" {
+		t.Errorf("items = %+v", items)
+	}
+	if primaryHits.Load() != 1 {
+		t.Errorf("primary was tried %d times, want once", primaryHits.Load())
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("Ping with a healthy fallback = %v", err)
+	}
+}
+
+func TestLoadConfigRejectsBadCatalogFallback(t *testing.T) {
+	t.Setenv("FOOD_CATALOG_FALLBACKS", "http://catalog-b:8080, catalog-c")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "FOOD_CATALOG_FALLBACKS") {
+		t.Fatalf("LoadConfig = %v, want a FOOD_CATALOG_FALLBACKS error", err)
+	}
+}
+
+// A client hanging up mid-validation cancels the catalog call and leaves
+// nothing behind in the store.
+func TestCreateOrderClientCancels(t *testing.T) {
+	withFreshBreaker(t)
+	b, srv := newBlockingCatalog(t)
+	defer close(b.release)
+	h := newTestHandler(t, WithCatalog(newHTTPCatalog(srv.URL, nil, 5*time.Second, retryPolicy{MaxAttempts: 1}, 8)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(simpleOrder)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.routes().ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	<-b.started
+	cancel()
+	select {
+	case <-b.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("catalog request was not cancelled with the client")
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not return after the client cancelled")
+	}
+	wantError(t, rec, statusClientClosedRequest, CodeRequestCancelled)
+	if orders := h.store.All(); len(orders) != 0 {
+		t.Errorf("store holds %d orders, want none", len(orders))
+	}
+	if n := catalogBreaker.Counts().TotalFailures; n != 0 {
+		t.Errorf("breaker counted %d failures for a client cancellation, want 0", n)
+	}
+}
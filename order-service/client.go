@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const defaultCatalogTimeout = 5 * time.Second
+
+// maxRedirects bounds how many redirects an outbound call follows.
+const maxRedirects = 3
+
+// errRedirectNotAllowed is returned when an outbound call is redirected to
+// another host or too many times.
+var errRedirectNotAllowed = errors.New("redirect not allowed")
+
+// checkRedirect follows at most maxRedirects redirects, and only to the host
+// the request was first sent to, so a misbehaving proxy cannot point the
+// service at arbitrary hosts.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", errRedirectNotAllowed, maxRedirects)
+	}
+	if from := via[0].URL; req.URL.Host != from.Host {
+		return fmt.Errorf("%w: %s redirected to another host, %s", errRedirectNotAllowed, from.Host, req.URL.Host)
+	}
+	return nil
+}
+
+// Connection pool defaults for outbound calls. Go's default of two idle
+// connections per host makes a busy service keep reconnecting to the
+// catalog.
+const (
+	defaultMaxIdleConnsPerHost = 16
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// outboundSettings configures the transport shared by all outbound calls.
+type outboundSettings struct {
+	UserAgent           string
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// defaultUserAgent names the service and its build, e.g.
+// "order-service/1.4.0".
+func defaultUserAgent() string {
+	return "order-service/" + valueOr(version, "dev")
+}
+
+func defaultOutboundSettings() outboundSettings {
+	return outboundSettings{
+		UserAgent:           defaultUserAgent(),
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+	}
+}
+
+// resolveOutbound reads OUTBOUND_USER_AGENT, OUTBOUND_MAX_IDLE_CONNS_PER_HOST
+// (default 16) and OUTBOUND_IDLE_CONN_TIMEOUT (default 90s).
+func resolveOutbound() (outboundSettings, error) {
+	s := defaultOutboundSettings()
+	if v := setting("OUTBOUND_USER_AGENT"); v != "" {
+		s.UserAgent = v
+	}
+	if v := setting("OUTBOUND_MAX_IDLE_CONNS_PER_HOST"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return outboundSettings{}, fmt.Errorf("invalid OUTBOUND_MAX_IDLE_CONNS_PER_HOST %q: must be a positive integer", v)
+		}
+		s.MaxIdleConnsPerHost = n
+	}
+	if v := setting("OUTBOUND_IDLE_CONN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return outboundSettings{}, fmt.Errorf("invalid OUTBOUND_IDLE_CONN_TIMEOUT %q: must be a positive duration", v)
+		}
+		s.IdleConnTimeout = d
+	}
+	return s, nil
+}
+
+// outboundTransport is shared by every outbound client so they pool
+// connections together. main replaces it with the configured one before
+// building any client.
+var outboundTransport = newOutboundTransport(defaultOutboundSettings())
+
+// newOutboundTransport returns a pooled transport that creates client spans
+// and injects the traceparent header, so downstream services join the
+// caller's trace, and adds the headers set by correlatingTransport.
+func newOutboundTransport(s outboundSettings) http.RoundTripper {
+	return otelhttp.NewTransport(correlatingTransport{next: newOutboundPool(s), userAgent: s.UserAgent})
+}
+
+// newOutboundPool returns the connection pool under newOutboundTransport.
+func newOutboundPool(s outboundSettings) *http.Transport {
+	pool := http.DefaultTransport.(*http.Transport).Clone()
+	pool.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	pool.IdleConnTimeout = s.IdleConnTimeout
+	return pool
+}
+
+// newOutboundClient returns the client for calls to other services. The
+// timeout means none of them can hang without a deadline, redirects are
+// limited by checkRedirect, and requests go through outboundTransport.
+func newOutboundClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     outboundTransport,
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// correlatingTransport identifies the service with User-Agent and forwards
+// the request ID of the inbound request that caused an outbound call as
+// X-Request-ID. It logs each call at debug level with that ID and the one
+// the downstream service answered with, so a failing call can be found in
+// both services' logs.
+type correlatingTransport struct {
+	next      http.RoundTripper
+	userAgent string
+}
+
+func (t correlatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	// RoundTrippers must not modify the caller's request.
+	req = req.Clone(ctx)
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	if id := requestIDFrom(ctx); id != "" && req.Header.Get("X-Request-ID") == "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	log := requestLogger(ctx).With(
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	if err != nil {
+		log.Debug("downstream call failed", "error", err)
+		return nil, err
+	}
+	log.Debug("downstream call", "status", resp.StatusCode, "downstream_request_id", resp.Header.Get("X-Request-ID"))
+	return resp, nil
+}
+
+// resolveCatalogTimeout reads CATALOG_TIMEOUT (a Go duration such as "2s"),
+// defaulting to 5s.
+func resolveCatalogTimeout() (time.Duration, error) {
+	v := setting("CATALOG_TIMEOUT")
+	if v == "" {
+		return defaultCatalogTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid CATALOG_TIMEOUT %q: must be a positive duration", v)
+	}
+	return d, nil
+}
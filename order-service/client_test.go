@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutboundClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := newOutboundClient(50 * time.Millisecond)
+	start := time.Now()
+	_, err := client.Get(srv.URL)
+	if err == nil {
+		t.Fatal("request to a hung server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("request took %s, want it cut off by the timeout", elapsed)
+	}
+}
+
+func TestOutboundClientRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect to another host was followed")
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/items/1", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, other.URL+"/items/1", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+	client := newOutboundClient(time.Second)
+
+	resp, err := client.Get(srv.URL + "/moved")
+	if err != nil {
+		t.Fatalf("same-host redirect: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Path != "/items/1" {
+		t.Errorf("ended at %s, want /items/1", resp.Request.URL.Path)
+	}
+	for _, path := range []string{"/away", "/loop"} {
+		if _, err := client.Get(srv.URL + path); !errors.Is(err, errRedirectNotAllowed) {
+			t.Errorf("GET %s = %v, want errRedirectNotAllowed", path, err)
+		}
+	}
+}
+
+// A catalog redirected elsewhere is reported as a bad response, not as an
+// outage or an unknown item.
+func TestValidateItemsRedirectedAway(t *testing.T) {
+	withFreshBreaker(t)
+	other := fakeCatalog(t, map[string]string{"1": `{"id":"1","name":"Coffee","price":2.5}`})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+	c := newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8)
+	if _, err := c.ValidateItems(context.Background(), []string{"1"}); !errors.Is(err, errCatalogBadResponse) {
+		t.Errorf("ValidateItems = %v, want errCatalogBadResponse", err)
+	}
+}
+
+func TestResolveCatalogTimeout(t *testing.T) {
+	t.Setenv("CATALOG_TIMEOUT", "")
+	if d, err := resolveCatalogTimeout(); d != defaultCatalogTimeout || err != nil {
+		t.Errorf("default = %s, %v", d, err)
+	}
+	t.Setenv("CATALOG_TIMEOUT", "750ms")
+	if d, err := resolveCatalogTimeout(); d != 750*time.Millisecond || err != nil {
+		t.Errorf("750ms = %s, %v", d, err)
+	}
+	for _, v := range []string{"0s", "-1s", "5"} {
+		t.Setenv("CATALOG_TIMEOUT", v)
+		if _, err := resolveCatalogTimeout(); err == nil {
+			t.Errorf("CATALOG_TIMEOUT=%q accepted", v)
+		}
+	}
+}
+
+// The request ID of an inbound request reaches the catalog, and the call is
+// logged with the ID the catalog answered with.
+func TestOutboundCallsCarryRequestID(t *testing.T) {
+	withFreshBreaker(t)
+	logs := captureLogs(t)
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Request-ID")
+		w.Header().Set("X-Request-ID", "catalog-"+got)
+		io.WriteString(w, `{"id":"1","name":"Coffee","price":2.5}`)
+	}))
+	defer srv.Close()
+	routes := newTestHandler(t, WithCatalog(newHTTPCatalog(srv.URL, nil, time.Second, retryPolicy{MaxAttempts: 1}, 8))).routes()
+
+	rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder)
+	wantStatus(t, rec, http.StatusCreated)
+	id := rec.Header().Get("X-Request-ID")
+	if id == "" || got != id {
+		t.Fatalf("catalog saw X-Request-ID %q, want the inbound %q", got, id)
+	}
+	for _, want := range []string{"msg=\"downstream call\"", "request_id=" + id, "downstream_request_id=catalog-" + id, "status=200"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestCorrelatingTransportKeepsCallerHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: correlatingTransport{next: http.DefaultTransport, userAgent: "order-service/test"}}
+
+	ctx := context.WithValue(context.Background(), requestIDKey, "inbound")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req.Header.Set("X-Request-ID", "explicit")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if got.Get("X-Request-ID") != "explicit" {
+		t.Errorf("X-Request-ID = %q, want the caller's own", got.Get("X-Request-ID"))
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("RoundTrip modified the caller's request")
+	}
+
+	// Without an inbound request there is no id to forward.
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp.Body.Close()
+	if got.Get("X-Request-ID") != "" {
+		t.Errorf("X-Request-ID = %q, want none", got.Get("X-Request-ID"))
+	}
+}
+
+func TestOutboundCallsIdentifyService(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("User-Agent"))
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: correlatingTransport{next: http.DefaultTransport, userAgent: "order-service/test"}}
+
+	for _, ua := range []string{"", "custom/1.0"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if ua != "" {
+			req.Header.Set("User-Agent", ua)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if len(got) != 2 || got[0] != "order-service/test" || got[1] != "custom/1.0" {
+		t.Errorf("User-Agent = %q, want the service's then the caller's own", got)
+	}
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	prev := version
+	t.Cleanup(func() { version = prev })
+	version = ""
+	if ua := defaultUserAgent(); ua != "order-service/dev" {
+		t.Errorf("unversioned User-Agent = %q", ua)
+	}
+	version = "1.4.0"
+	if ua := defaultUserAgent(); ua != "order-service/1.4.0" {
+		t.Errorf("User-Agent = %q, want order-service/1.4.0", ua)
+	}
+}
+
+func TestNewOutboundPool(t *testing.T) {
+	pool := newOutboundPool(outboundSettings{MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute})
+	if pool.MaxIdleConnsPerHost != 4 || pool.IdleConnTimeout != time.Minute {
+		t.Errorf("pool = %d idle per host, %s timeout; want 4, 1m", pool.MaxIdleConnsPerHost, pool.IdleConnTimeout)
+	}
+	if pool == http.DefaultTransport {
+		t.Error("pool is the shared default transport")
+	}
+}
+
+func TestResolveOutbound(t *testing.T) {
+	t.Setenv("OUTBOUND_USER_AGENT", "")
+	t.Setenv("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", "")
+	t.Setenv("OUTBOUND_IDLE_CONN_TIMEOUT", "")
+	if s, err := resolveOutbound(); s != defaultOutboundSettings() || err != nil {
+		t.Errorf("defaults = %+v, %v", s, err)
+	}
+
+	t.Setenv("OUTBOUND_USER_AGENT", "kitchen/2")
+	t.Setenv("OUTBOUND_MAX_IDLE_CONNS_PER_HOST", "32")
+	t.Setenv("OUTBOUND_IDLE_CONN_TIMEOUT", "30s")
+	want := outboundSettings{UserAgent: "kitchen/2", MaxIdleConnsPerHost: 32, IdleConnTimeout: 30 * time.Second}
+	if s, err := resolveOutbound(); s != want || err != nil {
+		t.Errorf("configured = %+v, %v; want %+v", s, err, want)
+	}
+
+	for name, v := range map[string]string{
+		"OUTBOUND_MAX_IDLE_CONNS_PER_HOST": "0",
+		"OUTBOUND_IDLE_CONN_TIMEOUT":       "soon",
+	} {
+		t.Setenv(name, v)
+		if _, err := resolveOutbound(); err == nil {
+			t.Errorf("%s=%q accepted", name, v)
+		}
+		t.Setenv(name, "")
+	}
+}
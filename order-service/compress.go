@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultCompressLevel    = 5
+	defaultCompressMinBytes = 1024
+)
+
+// compressibleTypes are the media types worth gzipping. Event streams are
+// left alone so frames reach the client as soon as they are flushed.
+var compressibleTypes = map[string]bool{
+	"application/json":     true,
+	problemContentType:     true,
+	"application/x-ndjson": true,
+	"text/csv":             true,
+	"text/plain":           true,
+}
+
+// resolveCompression reads COMPRESS_LEVEL (1-9, 0 disables compression,
+// default 5) and COMPRESS_MIN_BYTES (default 1024).
+func resolveCompression() (level, minBytes int, err error) {
+	level, minBytes = defaultCompressLevel, defaultCompressMinBytes
+	if v := setting("COMPRESS_LEVEL"); v != "" {
+		level, err = strconv.Atoi(v)
+		if err != nil || level < 0 || level > gzip.BestCompression {
+			return 0, 0, fmt.Errorf("invalid COMPRESS_LEVEL %q: must be between 0 and 9", v)
+		}
+	}
+	if v := setting("COMPRESS_MIN_BYTES"); v != "" {
+		minBytes, err = strconv.Atoi(v)
+		if err != nil || minBytes < 0 {
+			return 0, 0, fmt.Errorf("invalid COMPRESS_MIN_BYTES %q: must be a non-negative integer", v)
+		}
+	}
+	return level, minBytes, nil
+}
+
+// compressMiddleware gzips responses of at least minBytes for clients that
+// accept it. Responses that already carry a Content-Encoding, such as the
+// Prometheus handler's own gzip output, pass through untouched.
+func compressMiddleware(level, minBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w, level: level, minBytes: minBytes}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter holds back the first minBytes of a response so it can decide
+// whether compressing is worthwhile before any header is sent.
+type gzipWriter struct {
+	http.ResponseWriter
+	level, minBytes int
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (gw *gzipWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	gw.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		gw.decide(false)
+	}
+}
+
+func (gw *gzipWriter) Write(p []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.decided {
+		if gw.gz != nil {
+			return gw.gz.Write(p)
+		}
+		return gw.ResponseWriter.Write(p)
+	}
+	gw.buf = append(gw.buf, p...)
+	if len(gw.buf) >= gw.minBytes {
+		if err := gw.decide(gw.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (gw *gzipWriter) compressible() bool {
+	if gw.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(gw.Header().Get("Content-Type"))
+	return compressibleTypes[mediaType]
+}
+
+// decide sends the header, switching to gzip if compress is set, and then
+// releases whatever was buffered.
+func (gw *gzipWriter) decide(compress bool) error {
+	gw.decided = true
+	if compress {
+		gw.Header().Set("Content-Encoding", "gzip")
+		gw.Header().Del("Content-Length")
+		gw.gz, _ = gzip.NewWriterLevel(gw.ResponseWriter, gw.level)
+	}
+	gw.ResponseWriter.WriteHeader(gw.status)
+	buf := gw.buf
+	gw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(buf)
+	} else {
+		_, err = gw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush sends buffered output now. A response flushed before reaching
+// minBytes is sent uncompressed.
+func (gw *gzipWriter) Flush() {
+	if !gw.decided {
+		if !gw.wroteHeader {
+			gw.WriteHeader(http.StatusOK)
+		}
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+func (gw *gzipWriter) close() {
+	if !gw.decided && gw.wroteHeader {
+		gw.decide(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// bodyOf returns a handler answering with body as the given media type.
+func bodyOf(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	})
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	big := `{"notes":"` + strings.Repeat("a", 2000) + `"}`
+	h := compressMiddleware(5, 1024)(bodyOf("application/json", big))
+
+	rec := serve(t, h, http.MethodGet, "/orders", "", "Accept-Encoding", "gzip, deflate")
+	wantStatus(t, rec, http.StatusOK)
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q", rec.Header().Get("Vary"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != big {
+		t.Errorf("decompressed %d bytes, want the %d-byte body", len(got), len(big))
+	}
+}
+
+func TestCompressMiddlewareLeavesResponsesAlone(t *testing.T) {
+	big := strings.Repeat("a", 2000)
+	for _, tc := range []struct {
+		name    string
+		h       http.Handler
+		headers []string
+	}{
+		{"client does not accept gzip", bodyOf("application/json", big), nil},
+		{"gzip refused with q=0", bodyOf("application/json", big), []string{"Accept-Encoding", "gzip;q=0"}},
+		{"below the minimum", bodyOf("application/json", `{"a":1}`), []string{"Accept-Encoding", "gzip"}},
+		{"event stream", bodyOf("text/event-stream", big), []string{"Accept-Encoding", "gzip"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, compressMiddleware(5, 1024)(tc.h), http.MethodGet, "/orders", "", tc.headers...)
+			if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+				t.Fatalf("Content-Encoding = %q, want none", enc)
+			}
+			if rec.Body.Len() == 0 {
+				t.Error("body was lost")
+			}
+		})
+	}
+}
+
+func TestResolveCompression(t *testing.T) {
+	t.Setenv("COMPRESS_LEVEL", "")
+	t.Setenv("COMPRESS_MIN_BYTES", "")
+	if level, min, err := resolveCompression(); level != defaultCompressLevel || min != defaultCompressMinBytes || err != nil {
+		t.Errorf("default = %d, %d, %v", level, min, err)
+	}
+	t.Setenv("COMPRESS_LEVEL", "0")
+	t.Setenv("COMPRESS_MIN_BYTES", "256")
+	if level, min, err := resolveCompression(); level != 0 || min != 256 || err != nil {
+		t.Errorf("COMPRESS_LEVEL=0 COMPRESS_MIN_BYTES=256 = %d, %d, %v", level, min, err)
+	}
+	for name, v := range map[string]string{"COMPRESS_LEVEL": "10", "COMPRESS_MIN_BYTES": "-1"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, v)
+			if _, _, err := resolveCompression(); err == nil {
+				t.Errorf("%s=%s accepted", name, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileSettings holds the values read from CONFIG_FILE, keyed by the
+// environment variable each one stands in for.
+var fileSettings = map[string]string{}
+
+// setting returns the environment variable name if set, falling back to
+// CONFIG_FILE. Every service setting is read through it so env always wins.
+func setting(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fileSettings[name]
+}
+
+// fileSettingNames lists the environment variables CONFIG_FILE may set.
+// CONSUL_HTTP_ADDR and the OTEL_* variables are read by their client
+// libraries and stay env-only.
+var fileSettingNames = []string{
+	"PORT", "GRPC_PORT",
+	"ORDER_STORE", "DB_PATH", "MAX_ORDERS",
+	"FOOD_CATALOG_URL", "FOOD_CATALOG_FALLBACKS", "STATIC_DISCOVERY",
+	"CATALOG_MODE", "CATALOG_MOCK_ITEMS", "CATALOG_TIMEOUT", "CATALOG_CACHE_TTL",
+	"CATALOG_CONCURRENCY", "CATALOG_VALIDATION", "CATALOG_BULK_VALIDATE",
+	"CATALOG_MAX_UNKNOWN_ITEMS", "CUSTOMER_SERVICE_URL", "BLOCKED_ITEMS", "ALLOWED_ITEMS",
+	"OUTBOUND_USER_AGENT", "OUTBOUND_MAX_IDLE_CONNS_PER_HOST", "OUTBOUND_IDLE_CONN_TIMEOUT",
+	"IDEMPOTENCY_TTL", "RATE_LIMIT_RPS", "RATE_LIMIT_BURST",
+	"MAX_BODY_BYTES", "MAX_JSON_DEPTH", "MAX_BATCH_SIZE", "MAX_IN_FLIGHT", "REQUEST_TIMEOUT", "SHUTDOWN_TIMEOUT",
+	"EVENT_LOG_MAX_PER_ORDER", "PRETTY_JSON", "COMPRESS_LEVEL", "COMPRESS_MIN_BYTES",
+	"ADMIN_ENABLED", "CORS_ALLOWED_ORIGINS", "API_KEYS", "CACHE_CONTROL", "NATS_URL",
+	"WAIT_FOR_DEPS", "WAIT_FOR_DEPS_TIMEOUT", "SLOW_REQUEST_MS",
+	"BASE_PATH", "REVALIDATE_AUTO_CANCEL", "TLS_CERT_FILE", "TLS_KEY_FILE",
+	"WEBHOOK_SECRET", "DEFAULT_CURRENCY", "ORDER_RETENTION", "ORDER_RETENTION_INTERVAL",
+	"READ_HEADER_TIMEOUT", "IDLE_TIMEOUT", "MAX_HEADER_BYTES", "LOG_LEVEL",
+	"CONSUL_REREGISTER_INTERVAL", "MAX_NOTES_LENGTH", "MAX_ITEM_ID_LENGTH", "ERROR_FORMAT",
+	"MAINTENANCE_MODE", "PREP_TIME_BASE", "PREP_TIME_PER_ITEM",
+}
+
+// loadConfigFile reads a YAML (or JSON) file whose keys are the setting
+// names in lower case, e.g. "port: 8081" or "api_keys: [a, b]".
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CONFIG_FILE: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing CONFIG_FILE %s: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		name := strings.ToUpper(key)
+		if !slices.Contains(fileSettingNames, name) {
+			return nil, fmt.Errorf("CONFIG_FILE %s: unknown setting %q", path, key)
+		}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			settings[name] = strings.Join(parts, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("CONFIG_FILE %s: setting %q must be a scalar or a list", path, key)
+		default:
+			settings[name] = fmt.Sprint(v)
+		}
+	}
+	return settings, nil
+}
+
+// Config is the fully resolved service configuration.
+type Config struct {
+	Port     string
+	GRPCPort string
+	BasePath string
+	// TLSCertFile and TLSKeyFile switch the HTTP server to HTTPS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Server holds the HTTP server's header and keep-alive limits.
+	Server serverLimits
+
+	OrderStore string
+	DBPath     string
+	MaxOrders  int
+	// OrderRetention is how long terminal orders are kept; 0 keeps them.
+	OrderRetention    time.Duration
+	RetentionInterval time.Duration
+
+	CatalogURL         string
+	CatalogFallbacks   []string
+	CatalogMode        string
+	CatalogMockItems   string
+	CatalogTimeout     time.Duration
+	CatalogCacheTTL    time.Duration
+	CatalogConcurrency int
+	// CatalogBulkValidate prefers the catalog's POST /items/validate.
+	CatalogBulkValidate bool
+	ValidationMode      validationMode
+	// MaxUnknownItems is how many items an order may leave unchecked
+	// during a partial catalog outage.
+	MaxUnknownItems int
+	// CustomerServiceURL, when set, limits orders to known customers.
+	CustomerServiceURL string
+	// BlockedItems and AllowedItems seed the item policy.
+	BlockedItems []string
+	AllowedItems []string
+	Outbound     outboundSettings
+
+	IdempotencyTTL       time.Duration
+	RateLimitRPS         float64
+	RateLimitBurst       int
+	MaxBodyBytes         int64
+	MaxJSONDepth         int
+	MaxBatchSize         int
+	MaxInFlight          int
+	RequestTimeout       time.Duration
+	ShutdownTimeout      time.Duration
+	EventsPerOrder       int
+	PrettyJSON           bool
+	CompressLevel        int
+	CompressMinBytes     int
+	AdminEnabled         bool
+	MaintenanceMode      bool
+	SlowRequest          time.Duration
+	LogLevel             slog.Level
+	RevalidateAutoCancel bool
+	AllowedOrigins       []string
+	APIKeys              []string
+	CacheControl         string
+	NATSURL              string
+	WebhookSecret        string
+	DefaultCurrency      string
+	MaxNotesLength       int
+	MaxItemIDLength      int
+	PrepTimes            prepTimes
+	ErrorFormat          string
+
+	WaitForDeps        bool
+	WaitForDepsTimeout time.Duration
+
+	ConsulReregisterInterval time.Duration
+}
+
+// LoadConfig resolves every setting from the environment, then CONFIG_FILE
+// if one is named, then the built-in defaults.
+func LoadConfig() (Config, error) {
+	fileSettings = map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		settings, err := loadConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		fileSettings = settings
+	}
+
+	var cfg Config
+	var err error
+	if cfg.Port, err = resolvePort(); err != nil {
+		return Config{}, err
+	}
+	if cfg.GRPCPort, err = resolveGRPCPort(); err != nil {
+		return Config{}, err
+	}
+	if cfg.BasePath, err = resolveBasePath(); err != nil {
+		return Config{}, err
+	}
+	if cfg.TLSCertFile, cfg.TLSKeyFile, err = resolveTLS(); err != nil {
+		return Config{}, err
+	}
+	if cfg.OrderStore, cfg.DBPath, err = resolveOrderStore(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxOrders, err = resolveMaxOrders(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CatalogURL = setting("FOOD_CATALOG_URL"); cfg.CatalogURL != "" {
+		if err := validateServiceURL("FOOD_CATALOG_URL", cfg.CatalogURL); err != nil {
+			return Config{}, err
+		}
+	}
+	cfg.CatalogFallbacks = parseList(setting("FOOD_CATALOG_FALLBACKS"))
+	for _, f := range cfg.CatalogFallbacks {
+		if err := validateServiceURL("FOOD_CATALOG_FALLBACKS", f); err != nil {
+			return Config{}, err
+		}
+	}
+	if cfg.CatalogMode, err = resolveCatalogMode(); err != nil {
+		return Config{}, err
+	}
+	cfg.CatalogMockItems = setting("CATALOG_MOCK_ITEMS")
+	if cfg.CatalogTimeout, err = resolveCatalogTimeout(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CatalogCacheTTL, err = resolveCatalogCacheTTL(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CatalogConcurrency, err = resolveCatalogConcurrency(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CatalogBulkValidate, err = resolveCatalogBulkValidate(); err != nil {
+		return Config{}, err
+	}
+	if cfg.ValidationMode, err = resolveValidationMode(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxUnknownItems, err = resolveMaxUnknownItems(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CustomerServiceURL, err = resolveCustomerServiceURL(); err != nil {
+		return Config{}, err
+	}
+	cfg.BlockedItems = parseList(setting("BLOCKED_ITEMS"))
+	cfg.AllowedItems = parseList(setting("ALLOWED_ITEMS"))
+	if cfg.Outbound, err = resolveOutbound(); err != nil {
+		return Config{}, err
+	}
+	if cfg.IdempotencyTTL, err = resolveIdempotencyTTL(); err != nil {
+		return Config{}, err
+	}
+	if cfg.RateLimitRPS, cfg.RateLimitBurst, err = resolveRateLimit(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxBodyBytes, err = resolveMaxBodyBytes(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxJSONDepth, err = resolveMaxJSONDepth(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxBatchSize, err = resolveMaxBatchSize(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxInFlight, err = resolveMaxInFlight(); err != nil {
+		return Config{}, err
+	}
+	if cfg.RequestTimeout, err = resolveRequestTimeout(); err != nil {
+		return Config{}, err
+	}
+	if cfg.ShutdownTimeout, err = resolveShutdownTimeout(); err != nil {
+		return Config{}, err
+	}
+	if cfg.EventsPerOrder, err = resolveEventsPerOrder(); err != nil {
+		return Config{}, err
+	}
+	if cfg.PrettyJSON, err = resolvePrettyJSON(); err != nil {
+		return Config{}, err
+	}
+	if cfg.CompressLevel, cfg.CompressMinBytes, err = resolveCompression(); err != nil {
+		return Config{}, err
+	}
+	if cfg.AdminEnabled, err = resolveAdminEnabled(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaintenanceMode, err = resolveMaintenanceMode(); err != nil {
+		return Config{}, err
+	}
+	if cfg.SlowRequest, err = resolveSlowRequestThreshold(); err != nil {
+		return Config{}, err
+	}
+	if cfg.RevalidateAutoCancel, err = resolveRevalidateAutoCancel(); err != nil {
+		return Config{}, err
+	}
+	cfg.AllowedOrigins = resolveAllowedOrigins()
+	cfg.APIKeys = resolveAPIKeys()
+	cfg.CacheControl = resolveCacheControl()
+	cfg.NATSURL = setting("NATS_URL")
+	cfg.WebhookSecret = setting("WEBHOOK_SECRET")
+	if cfg.DefaultCurrency, err = resolveDefaultCurrency(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxNotesLength, err = resolveMaxNotesLength(); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxItemIDLength, err = resolveMaxItemIDLength(); err != nil {
+		return Config{}, err
+	}
+	if cfg.ErrorFormat, err = resolveErrorFormat(); err != nil {
+		return Config{}, err
+	}
+	if cfg.PrepTimes, err = resolvePrepTimes(); err != nil {
+		return Config{}, err
+	}
+	if cfg.LogLevel, err = resolveLogLevel(); err != nil {
+		return Config{}, err
+	}
+	if cfg.Server, err = resolveServerLimits(); err != nil {
+		return Config{}, err
+	}
+	if cfg.OrderRetention, cfg.RetentionInterval, err = resolveRetention(); err != nil {
+		return Config{}, err
+	}
+	if cfg.WaitForDeps, cfg.WaitForDepsTimeout, err = resolveWaitForDeps(); err != nil {
+		return Config{}, err
+	}
+	if cfg.ConsulReregisterInterval, err = resolveConsulReregisterInterval(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
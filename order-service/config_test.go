@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes contents to a temporary CONFIG_FILE and points the
+// environment at it. The settings loaded from it are dropped afterwards.
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Cleanup(func() { fileSettings = map[string]string{} })
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != "8081" || cfg.GRPCPort != "9090" || cfg.OrderStore != "sqlite" || cfg.MaxBodyBytes != defaultMaxBodyBytes {
+		t.Errorf("cfg = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	writeConfigFile(t, "port: 8181\napi_keys: [k1, k2]\nmax_orders: 50\ncatalog_cache_ttl: null\n")
+	t.Setenv("MAX_ORDERS", "75")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != "8181" {
+		t.Errorf("Port = %q, want 8181 from the file", cfg.Port)
+	}
+	if !slices.Equal(cfg.APIKeys, []string{"k1", "k2"}) {
+		t.Errorf("APIKeys = %v, want the file's list", cfg.APIKeys)
+	}
+	if cfg.MaxOrders != 75 {
+		t.Errorf("MaxOrders = %d, want 75 from the environment", cfg.MaxOrders)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	for name, tc := range map[string]struct{ contents, want string }{
+		"unknown setting": {"prot: 8081\n", "unknown setting"},
+		"nested value":    {"port:\n  http: 8081\n", "scalar or a list"},
+		"not yaml":        {"port: [8081\n", "parsing CONFIG_FILE"},
+		"bad value":       {"port: 0\n", "invalid PORT"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			writeConfigFile(t, tc.contents)
+			if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("LoadConfig = %v, want an error about %q", err, tc.want)
+			}
+		})
+	}
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "reading CONFIG_FILE") {
+		t.Fatalf("LoadConfig with a missing file = %v", err)
+	}
+}
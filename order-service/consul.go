@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// consulClient returns the client for the Consul agent at CONSUL_HTTP_ADDR.
+// It is built on first use and then shared, so registration and every
+// discovery lookup reuse one pooled HTTP transport.
+var consulClient = sync.OnceValues(func() (*api.Client, error) {
+	return api.NewClient(api.DefaultConfig())
+})
+
+// newServiceRegistration describes this instance to Consul, including an
+// HTTP health check against /health, over HTTPS when useTLS is set.
+func newServiceRegistration(port string, useTLS bool) (*api.AgentServiceRegistration, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", port, err)
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("resolving hostname: %w", err)
+	}
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	return &api.AgentServiceRegistration{
+		ID:      "order-service-" + host,
+		Name:    "order-service",
+		Address: host,
+		Port:    p,
+		Check: &api.AgentServiceCheck{
+			HTTP:     fmt.Sprintf("%s://%s:%d/health", scheme, host, p),
+			Interval: "10s",
+			Timeout:  "2s",
+			// The certificate is issued for the service name, not the
+			// pod hostname Consul dials.
+			TLSSkipVerify: useTLS,
+		},
+	}, nil
+}
+
+const defaultConsulReregisterInterval = 30 * time.Second
+
+// resolveConsulReregisterInterval reads CONSUL_REREGISTER_INTERVAL, how often
+// the service checks it is still registered, defaulting to 30s.
+func resolveConsulReregisterInterval() (time.Duration, error) {
+	v := setting("CONSUL_REREGISTER_INTERVAL")
+	if v == "" {
+		return defaultConsulReregisterInterval, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid CONSUL_REREGISTER_INTERVAL %q: must be a positive duration", v)
+	}
+	return d, nil
+}
+
+// consulRegistrar keeps this instance registered with a Consul agent.
+type consulRegistrar struct {
+	agent *api.Agent
+	reg   *api.AgentServiceRegistration
+}
+
+// ensure registers the service unless the agent already lists it, so a
+// restarted agent or a dropped registration is repaired. Registering is
+// idempotent, so a lost race just re-registers the same service.
+func (c *consulRegistrar) ensure() error {
+	services, err := c.agent.Services()
+	if err != nil {
+		return fmt.Errorf("listing Consul services: %w", err)
+	}
+	if _, ok := services[c.reg.ID]; ok {
+		return nil
+	}
+	if err := c.agent.ServiceRegister(c.reg); err != nil {
+		return fmt.Errorf("registering with Consul: %w", err)
+	}
+	log.Printf("Registered %s with Consul", c.reg.ID)
+	return nil
+}
+
+// run registers now and then every interval until ctx is done, when it
+// deregisters. Failures are logged and retried on the next tick.
+func (c *consulRegistrar) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.ensure(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			if err := c.agent.ServiceDeregister(c.reg.ID); err != nil {
+				log.Printf("Warning: failed to deregister from Consul: %v", err)
+				return
+			}
+			log.Printf("Deregistered %s from Consul", c.reg.ID)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Service registration with the Consul agent at CONSUL_HTTP_ADDR, kept up
+// until ctx is done. Failures are logged and the service keeps serving
+// without discovery; without CONSUL_HTTP_ADDR this returns at once.
+func registerServiceWithConsul(ctx context.Context, port string, useTLS bool, interval time.Duration) {
+	if os.Getenv("CONSUL_HTTP_ADDR") == "" {
+		log.Println("CONSUL_HTTP_ADDR not set, skipping service registration")
+		return
+	}
+	reg, err := newServiceRegistration(port, useTLS)
+	if err != nil {
+		log.Printf("Warning: could not build Consul registration: %v", err)
+		return
+	}
+	client, err := consulClient()
+	if err != nil {
+		log.Printf("Warning: could not create Consul client: %v", err)
+		return
+	}
+	(&consulRegistrar{agent: client.Agent(), reg: reg}).run(ctx, interval)
+}
+
+// staticServices maps service names to their Kubernetes DNS addresses and is
+// used instead of Consul when STATIC_DISCOVERY=true (offline development).
+var staticServices = map[string]string{
+	"food-catalog-service": "http://food-catalog-service:8080",
+	"customer-service":     "http://customer-service:8080",
+}
+
+// Discover a healthy instance of serviceName through Consul's health API.
+// FOOD_CATALOG_URL, when set, takes precedence over any discovery. The
+// Consul query is abandoned if ctx is cancelled.
+func findService(ctx context.Context, serviceName string) (string, error) {
+	if serviceName == "food-catalog-service" {
+		if addr := setting("FOOD_CATALOG_URL"); addr != "" {
+			return strings.TrimSuffix(addr, "/"), nil
+		}
+	}
+	if setting("STATIC_DISCOVERY") == "true" {
+		if addr, ok := staticServices[serviceName]; ok {
+			return addr, nil
+		}
+		return "", fmt.Errorf("service %s not found", serviceName)
+	}
+
+	client, err := consulClient()
+	if err != nil {
+		return "", fmt.Errorf("creating Consul client: %w", err)
+	}
+	entries, _, err := client.Health().Service(serviceName, "", true, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("querying Consul for %s: %w", serviceName, err)
+	}
+	addr, ok := pickServiceAddress(entries)
+	if !ok {
+		return "", fmt.Errorf("service %s not found", serviceName)
+	}
+	return addr, nil
+}
+
+// pickServiceAddress chooses one of the healthy instances at random to spread
+// load across them.
+func pickServiceAddress(entries []*api.ServiceEntry) (string, bool) {
+	if len(entries) == 0 {
+		return "", false
+	}
+	e := entries[rand.IntN(len(entries))]
+	host := e.Service.Address
+	if host == "" {
+		host = e.Node.Address
+	}
+	return fmt.Sprintf("http://%s:%d", host, e.Service.Port), true
+}
+
+// validateServiceURL checks that an address override such as
+// FOOD_CATALOG_URL is an absolute http(s) URL.
+func validateServiceURL(name, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s %q: must be an absolute http or https URL", name, raw)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeConsulAgent is just enough of the Consul agent API for registration:
+// it lists, registers and deregisters services, recording each call.
+type fakeConsulAgent struct {
+	mu       sync.Mutex
+	services map[string]bool
+	calls    []string
+}
+
+func newFakeConsulAgent(t *testing.T) (*fakeConsulAgent, *api.Agent) {
+	a := &fakeConsulAgent{services: map[string]bool{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.calls = append(a.calls, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/v1/agent/services":
+			w.Header().Set("Content-Type", "application/json")
+			var ids []string
+			for id := range a.services {
+				ids = append(ids, `"`+id+`":{"ID":"`+id+`"}`)
+			}
+			w.Write([]byte("{" + strings.Join(ids, ",") + "}"))
+		case r.URL.Path == "/v1/agent/service/register":
+			a.services["order-service-test"] = true
+		case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+			delete(a.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("api.NewClient: %v", err)
+	}
+	return a, client.Agent()
+}
+
+// count reports how many calls matched method and path.
+func (a *fakeConsulAgent) count(call string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for _, c := range a.calls {
+		if c == call {
+			n++
+		}
+	}
+	return n
+}
+
+func TestNewServiceRegistration(t *testing.T) {
+	reg, err := newServiceRegistration("8081", false)
+	if err != nil {
+		t.Fatalf("newServiceRegistration: %v", err)
+	}
+	if reg.Name != "order-service" || reg.Port != 8081 || !strings.HasPrefix(reg.ID, "order-service-") {
+		t.Errorf("registration = %+v", reg)
+	}
+	if !strings.HasPrefix(reg.Check.HTTP, "http://") || !strings.HasSuffix(reg.Check.HTTP, ":8081/health") {
+		t.Errorf("check URL = %q", reg.Check.HTTP)
+	}
+
+	reg, _ = newServiceRegistration("8443", true)
+	if !strings.HasPrefix(reg.Check.HTTP, "https://") || !reg.Check.TLSSkipVerify {
+		t.Errorf("TLS check = %+v, want https with TLSSkipVerify", reg.Check)
+	}
+	if _, err := newServiceRegistration("http", false); err == nil {
+		t.Error("non-numeric port accepted")
+	}
+}
+
+func TestConsulRegistrarRegistersAndDeregisters(t *testing.T) {
+	agent, client := newFakeConsulAgent(t)
+	r := &consulRegistrar{agent: client, reg: &api.AgentServiceRegistration{ID: "order-service-test", Name: "order-service"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.run(ctx, time.Hour)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for agent.count("PUT /v1/agent/service/register") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if n := agent.count("PUT /v1/agent/service/register"); n != 1 {
+		t.Errorf("registered %d times, want 1", n)
+	}
+	if n := agent.count("PUT /v1/agent/service/deregister/order-service-test"); n != 1 {
+		t.Errorf("deregistered %d times, want 1", n)
+	}
+}
+
+// An agent that loses the registration, say after a restart, gets it back
+// on the next tick; one that still lists it is left alone.
+func TestConsulRegistrarReregisters(t *testing.T) {
+	agent, client := newFakeConsulAgent(t)
+	r := &consulRegistrar{agent: client, reg: &api.AgentServiceRegistration{ID: "order-service-test", Name: "order-service"}}
+	if err := r.ensure(); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if err := r.ensure(); err != nil {
+		t.Fatalf("ensure: %v", err)
+	}
+	if n := agent.count("PUT /v1/agent/service/register"); n != 1 {
+		t.Fatalf("registered %d times while listed, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.run(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	agent.mu.Lock()
+	delete(agent.services, "order-service-test")
+	agent.mu.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for agent.count("PUT /v1/agent/service/register") < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := agent.count("PUT /v1/agent/service/register"); n != 2 {
+		t.Errorf("registered %d times, want a second registration after it was dropped", n)
+	}
+}
+
+func TestResolveConsulReregisterInterval(t *testing.T) {
+	t.Setenv("CONSUL_REREGISTER_INTERVAL", "")
+	if d, err := resolveConsulReregisterInterval(); d != defaultConsulReregisterInterval || err != nil {
+		t.Errorf("default = %s, %v; want %s", d, err, defaultConsulReregisterInterval)
+	}
+	t.Setenv("CONSUL_REREGISTER_INTERVAL", "5s")
+	if d, err := resolveConsulReregisterInterval(); d != 5*time.Second || err != nil {
+		t.Errorf("5s = %s, %v", d, err)
+	}
+	for _, v := range []string{"0s", "30"} {
+		t.Setenv("CONSUL_REREGISTER_INTERVAL", v)
+		if _, err := resolveConsulReregisterInterval(); err == nil {
+			t.Errorf("CONSUL_REREGISTER_INTERVAL=%q accepted", v)
+		}
+	}
+}
+
+func TestRegisterSkippedWithoutConsul(t *testing.T) {
+	t.Setenv("CONSUL_HTTP_ADDR", "")
+	done := make(chan struct{})
+	go func() {
+		registerServiceWithConsul(context.Background(), "8081", false, time.Hour)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("registration without CONSUL_HTTP_ADDR did not return")
+	}
+}
+
+func TestConsulClientIsShared(t *testing.T) {
+	first, err := consulClient()
+	if err != nil {
+		t.Fatalf("consulClient: %v", err)
+	}
+	second, _ := consulClient()
+	if first != second {
+		t.Error("consulClient built a second client")
+	}
+}
+
+func TestFindServiceStatic(t *testing.T) {
+	t.Setenv("STATIC_DISCOVERY", "true")
+
+	addr, err := findService(context.Background(), "food-catalog-service")
+	if err != nil || addr != "http://food-catalog-service:8080" {
+		t.Errorf("findService = %q, %v; want the static catalog address", addr, err)
+	}
+	if _, err := findService(context.Background(), "billing-service"); err == nil {
+		t.Error("findService found a service with no static address")
+	}
+}
+
+func TestFindServiceCatalogOverride(t *testing.T) {
+	t.Setenv("FOOD_CATALOG_URL", "http://catalog.local:9000/")
+
+	addr, err := findService(context.Background(), "food-catalog-service")
+	if err != nil || addr != "http://catalog.local:9000" {
+		t.Errorf("findService = %q, %v; want http://catalog.local:9000", addr, err)
+	}
+}
+
+func TestPickServiceAddress(t *testing.T) {
+	if _, ok := pickServiceAddress(nil); ok {
+		t.Error("pickServiceAddress found an address among no instances")
+	}
+	entries := []*api.ServiceEntry{{
+		Node:    &api.Node{Address: "10.0.0.9"},
+		Service: &api.AgentService{Port: 8080},
+	}}
+	if addr, ok := pickServiceAddress(entries); !ok || addr != "http://10.0.0.9:8080" {
+		t.Errorf("pickServiceAddress = %q, %t; want the node address", addr, ok)
+	}
+	entries[0].Service.Address = "catalog-1"
+	if addr, _ := pickServiceAddress(entries); addr != "http://catalog-1:8080" {
+		t.Errorf("pickServiceAddress = %q, want the service address", addr)
+	}
+}
+
+func TestValidateServiceURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"http://catalog:8080":      true,
+		"https://catalog.example/": true,
+		"catalog:8080":             false,
+		"ftp://catalog":            false,
+		"http://":                  false,
+		"/catalog":                 false,
+	} {
+		if err := validateServiceURL("FOOD_CATALOG_URL", raw); (err == nil) != ok {
+			t.Errorf("validateServiceURL(%q) = %v, want ok %v", raw, err, ok)
+		}
+	}
+}
+
+func TestLoadConfigRejectsBadCatalogURL(t *testing.T) {
+	t.Setenv("FOOD_CATALOG_URL", "catalog:8080")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "FOOD_CATALOG_URL") {
+		t.Fatalf("LoadConfig = %v, want a FOOD_CATALOG_URL error", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+func resolveAllowedOrigins() []string {
+	return parseList(setting("CORS_ALLOWED_ORIGINS"))
+}
+
+// corsMiddleware allows browser clients from the given origins. With no
+// origins configured no CORS headers are sent, so cross-origin requests
+// are denied by the browser.
+func corsMiddleware(origins []string) func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-None-Match", "X-API-Version", deadlineHeader},
+		ExposedHeaders: []string{
+			"X-Request-ID", "X-Total-Count", "X-API-Version", "Content-Disposition", "ETag", "Location", "Retry-After",
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset",
+		},
+		MaxAge: 300,
+	})
+}
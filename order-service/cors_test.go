@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCORSAllowsConfiguredOrigins(t *testing.T) {
+	routes := newTestHandler(t, WithAllowedOrigins([]string{"https://shop.example.com"})).routes()
+
+	rec := serve(t, routes, http.MethodOptions, "/orders", "",
+		"Origin", "https://shop.example.com",
+		"Access-Control-Request-Method", http.MethodPost,
+		"Access-Control-Request-Headers", "Content-Type, Idempotency-Key")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://shop.example.com" {
+		t.Fatalf("preflight Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != http.MethodPost {
+		t.Errorf("preflight Allow-Methods = %q", got)
+	}
+
+	// Conditional GETs and caller deadlines pass the preflight too.
+	rec = serve(t, routes, http.MethodOptions, "/orders/o1", "",
+		"Origin", "https://shop.example.com",
+		"Access-Control-Request-Method", http.MethodGet,
+		"Access-Control-Request-Headers", "if-none-match,x-request-deadline")
+	allowed := strings.ToLower(rec.Header().Get("Access-Control-Allow-Headers"))
+	for _, want := range []string{"if-none-match", "x-request-deadline"} {
+		if !strings.Contains(allowed, want) {
+			t.Errorf("preflight Allow-Headers = %q, missing %s", allowed, want)
+		}
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders", "", "Origin", "https://shop.example.com")
+	wantStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://shop.example.com" {
+		t.Errorf("GET Allow-Origin = %q", got)
+	}
+	exposed := rec.Header().Get("Access-Control-Expose-Headers")
+	for _, want := range []string{"X-Request-Id", "X-Total-Count", "Etag", "Location", "Retry-After", "X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset"} {
+		if !strings.Contains(exposed, want) {
+			t.Errorf("Expose-Headers = %q, missing %s", exposed, want)
+		}
+	}
+}
+
+func TestCORSIgnoresOtherOrigins(t *testing.T) {
+	for name, h := range map[string]*handler{
+		"not listed":   newTestHandler(t, WithAllowedOrigins([]string{"https://shop.example.com"})),
+		"none allowed": newTestHandler(t),
+	} {
+		rec := serve(t, h.routes(), http.MethodGet, "/orders", "", "Origin", "https://evil.example")
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: Allow-Origin = %q, want none", name, got)
+		}
+	}
+}
+
+func TestResolveAllowedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example , ,https://b.example")
+	if got := resolveAllowedOrigins(); !slices.Equal(got, []string{"https://a.example", "https://b.example"}) {
+		t.Errorf("resolveAllowedOrigins = %q", got)
+	}
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	if got := resolveAllowedOrigins(); len(got) != 0 {
+		t.Errorf("unset = %q, want none", got)
+	}
+}
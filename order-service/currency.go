@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCurrency applies to orders that do not name a currency. Totals are
+// always kept in integer minor units (cents) of that currency.
+const defaultCurrency = "USD"
+
+// resolveDefaultCurrency reads DEFAULT_CURRENCY, defaulting to USD.
+func resolveDefaultCurrency() (string, error) {
+	v := setting("DEFAULT_CURRENCY")
+	if v == "" {
+		return defaultCurrency, nil
+	}
+	if !validCurrency(v) {
+		return "", fmt.Errorf("invalid DEFAULT_CURRENCY %q: must be a 3-letter ISO 4217 code", v)
+	}
+	return strings.ToUpper(v), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCreateOrderCurrency(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	if o := createTestOrder(t, routes, simpleOrder); o.Currency != defaultCurrency || o.TotalCents != 250 {
+		t.Errorf("default = %q totalling %d, want %s 250", o.Currency, o.TotalCents, defaultCurrency)
+	}
+	if o := createTestOrder(t, routes, `{"customer_id":"c1","currency":"eur","items":[{"item_id":"1","quantity":1}]}`); o.Currency != "EUR" {
+		t.Errorf("explicit currency = %q, want EUR", o.Currency)
+	}
+
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","currency":"EURO","items":[{"item_id":"1","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+	if d := decodeBody[errorResponse](t, rec).Details; len(d) != 1 || d[0].Field != "currency" {
+		t.Errorf("details = %+v, want a currency error", d)
+	}
+
+	routes = newTestHandler(t, WithDefaultCurrency("GBP")).routes()
+	if o := createTestOrder(t, routes, simpleOrder); o.Currency != "GBP" {
+		t.Errorf("WithDefaultCurrency(GBP) = %q", o.Currency)
+	}
+}
+
+func TestStoreKeepsCurrency(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		o := storedOrder("o1")
+		o.Currency = "JPY"
+		if err := s.Save(o); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if got, _ := s.Get("o1"); got.Currency != "JPY" {
+			t.Errorf("Currency = %q, want JPY", got.Currency)
+		}
+	})
+}
+
+func TestResolveDefaultCurrency(t *testing.T) {
+	t.Setenv("DEFAULT_CURRENCY", "")
+	if c, err := resolveDefaultCurrency(); c != "USD" || err != nil {
+		t.Errorf("default = %q, %v; want USD", c, err)
+	}
+	t.Setenv("DEFAULT_CURRENCY", "nzd")
+	if c, err := resolveDefaultCurrency(); c != "NZD" || err != nil {
+		t.Errorf("nzd = %q, %v; want NZD", c, err)
+	}
+	for _, v := range []string{"US", "DOLLARS", "U$D"} {
+		t.Setenv("DEFAULT_CURRENCY", v)
+		if _, err := resolveDefaultCurrency(); err == nil {
+			t.Errorf("DEFAULT_CURRENCY=%q accepted", v)
+		}
+	}
+}
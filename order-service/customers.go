@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sony/gobreaker"
+)
+
+// customerServiceDiscover is the CUSTOMER_SERVICE_URL value that finds the
+// customer service through discovery instead of a fixed address.
+const customerServiceDiscover = "consul"
+
+// errCustomerServiceUnavailable is returned when the customer service
+// cannot be reached.
+var errCustomerServiceUnavailable = errors.New("customer service unavailable")
+
+// CustomerDirectory tells registered customers from unknown ones.
+type CustomerDirectory interface {
+	// KnownCustomer reports whether id is registered, or returns an error
+	// wrapping errCustomerServiceUnavailable when the service cannot
+	// answer.
+	KnownCustomer(ctx context.Context, id string) (bool, error)
+}
+
+// resolveCustomerServiceURL reads CUSTOMER_SERVICE_URL. Empty, the default,
+// accepts orders from any customer; "consul" discovers customer-service;
+// anything else must be the service's http or https address.
+func resolveCustomerServiceURL() (string, error) {
+	v := setting("CUSTOMER_SERVICE_URL")
+	if v == "" || v == customerServiceDiscover {
+		return v, nil
+	}
+	if err := validateServiceURL("CUSTOMER_SERVICE_URL", v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(v, "/"), nil
+}
+
+// httpCustomers asks customer-service about customers over HTTP, with the
+// same timeout, retries and circuit breaking as catalog lookups.
+type httpCustomers struct {
+	// baseURL is the service's address; when empty it is discovered on
+	// every call.
+	baseURL string
+	client  *http.Client
+	retry   retryPolicy
+	breaker *gobreaker.CircuitBreaker
+}
+
+// newCustomerDirectory returns the directory for cfg, or nil when
+// CUSTOMER_SERVICE_URL is unset. Lookups time out after CATALOG_TIMEOUT.
+func newCustomerDirectory(cfg Config) CustomerDirectory {
+	if cfg.CustomerServiceURL == "" {
+		return nil
+	}
+	c := &httpCustomers{
+		client:  newOutboundClient(cfg.CatalogTimeout),
+		retry:   defaultRetryPolicy,
+		breaker: newServiceBreaker("customer-service", errCustomerServiceUnavailable, catalogBreakerFailures, catalogBreakerCooldown),
+	}
+	if cfg.CustomerServiceURL != customerServiceDiscover {
+		c.baseURL = cfg.CustomerServiceURL
+	}
+	return c
+}
+
+// KnownCustomer looks id up with GET /customers/{id}: 200 means known and
+// 404 unknown.
+func (c *httpCustomers) KnownCustomer(ctx context.Context, id string) (bool, error) {
+	addr := c.baseURL
+	if addr == "" {
+		found, err := findService(ctx, "customer-service")
+		if err != nil {
+			return false, fmt.Errorf("%w: finding customer service: %v", errCustomerServiceUnavailable, err)
+		}
+		addr = found
+	}
+	known, err := c.breaker.Execute(func() (any, error) {
+		return c.lookup(ctx, addr, id)
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return false, fmt.Errorf("%w: %v", errCustomerServiceUnavailable, err)
+	}
+	if err != nil {
+		return false, err
+	}
+	return known.(bool), nil
+}
+
+func (c *httpCustomers) lookup(ctx context.Context, addr, id string) (bool, error) {
+	resp, err := doWithRetry(c.client, c.retry, func(int) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, addr+"/customers/"+url.PathEscape(id), nil)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, fmt.Errorf("%w: %v", errCustomerServiceUnavailable, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: customer lookup returned %s", errCustomerServiceUnavailable, resp.Status)
+	}
+}
+
+// checkCustomer rejects orders from customers the directory does not know.
+// Without a directory every customer is accepted.
+func (h *handler) checkCustomer(ctx context.Context, id string) *requestError {
+	if h.customers == nil {
+		return nil
+	}
+	known, err := h.customers.KnownCustomer(ctx, id)
+	switch {
+	case err == nil && known:
+		return nil
+	case err == nil:
+		return &requestError{status: http.StatusForbidden, code: CodeUnknownCustomer, msg: fmt.Sprintf("customer %s is not registered", id)}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return timeoutError(ctx)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return &requestError{status: statusClientClosedRequest, code: CodeRequestCancelled, msg: "request cancelled"}
+	}
+	requestLogger(ctx).Error("checking customer", "error", err)
+	return &requestError{status: http.StatusServiceUnavailable, code: CodeCustomerServiceUnavailable, msg: "customer service not available"}
+}
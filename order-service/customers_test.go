@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCustomers serves GET /customers/{id}, knowing only the given ids.
+func fakeCustomers(t *testing.T, known ...string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/customers/")
+		for _, k := range known {
+			if id == k {
+				w.Write([]byte(`{"id":"` + id + `"}`))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testCustomers is a directory for baseURL that does not retry and has a
+// breaker of its own.
+func testCustomers(baseURL string) *httpCustomers {
+	return &httpCustomers{
+		baseURL: baseURL,
+		client:  newOutboundClient(time.Second),
+		retry:   retryPolicy{MaxAttempts: 1},
+		breaker: newServiceBreaker("customer-service", errCustomerServiceUnavailable, catalogBreakerFailures, catalogBreakerCooldown),
+	}
+}
+
+func TestCreateOrderKnownCustomers(t *testing.T) {
+	srv := fakeCustomers(t, "c1")
+	h := newTestHandler(t, WithCustomerDirectory(testCustomers(srv.URL)))
+	routes := h.routes()
+
+	createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"stranger","items":[{"item_id":"1","quantity":1}]}`)
+	wantError(t, rec, http.StatusForbidden, CodeUnknownCustomer)
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "stranger") {
+		t.Errorf("error = %q, want it to name the customer", msg)
+	}
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want 1", n)
+	}
+}
+
+func TestCreateOrderCustomerServiceDown(t *testing.T) {
+	srv := fakeCustomers(t, "c1")
+	srv.Close()
+	h := newTestHandler(t, WithCustomerDirectory(testCustomers(srv.URL)))
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCustomerServiceUnavailable)
+	if len(h.store.All()) != 0 {
+		t.Error("order was stored while the customer service was down")
+	}
+}
+
+func TestCustomerLookupUnexpectedStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+	rec := serve(t, newTestHandler(t, WithCustomerDirectory(testCustomers(srv.URL))).routes(), http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCustomerServiceUnavailable)
+}
+
+func TestNewCustomerDirectory(t *testing.T) {
+	if d := newCustomerDirectory(Config{}); d != nil {
+		t.Errorf("directory without CUSTOMER_SERVICE_URL = %v, want nil", d)
+	}
+	d, _ := newCustomerDirectory(Config{CustomerServiceURL: "http://customers:8080"}).(*httpCustomers)
+	if d == nil || d.baseURL != "http://customers:8080" {
+		t.Errorf("directory = %+v, want the configured address", d)
+	}
+	d, _ = newCustomerDirectory(Config{CustomerServiceURL: customerServiceDiscover}).(*httpCustomers)
+	if d == nil || d.baseURL != "" {
+		t.Errorf("discovering directory = %+v, want no fixed address", d)
+	}
+}
+
+func TestResolveCustomerServiceURL(t *testing.T) {
+	for v, want := range map[string]string{
+		"":                        "",
+		"consul":                  customerServiceDiscover,
+		"http://customers:8080/":  "http://customers:8080",
+		"https://customers.local": "https://customers.local",
+	} {
+		t.Setenv("CUSTOMER_SERVICE_URL", v)
+		if got, err := resolveCustomerServiceURL(); got != want || err != nil {
+			t.Errorf("CUSTOMER_SERVICE_URL=%q = %q, %v; want %q", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"customers:8080", "ftp://customers"} {
+		t.Setenv("CUSTOMER_SERVICE_URL", v)
+		if _, err := resolveCustomerServiceURL(); err == nil {
+			t.Errorf("CUSTOMER_SERVICE_URL=%q accepted", v)
+		}
+	}
+}
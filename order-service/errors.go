@@ -0,0 +1,41 @@
+package main
+
+// ErrorCode is a stable, machine-readable error identifier returned in the
+// "code" field of every error response. Clients may branch on these values,
+// so existing codes must never change meaning.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest             ErrorCode = "INVALID_REQUEST"
+	CodeValidationFailed           ErrorCode = "VALIDATION_FAILED"
+	CodeUnsupportedMediaType       ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeBodyTooLarge               ErrorCode = "BODY_TOO_LARGE"
+	CodeUnauthorized               ErrorCode = "UNAUTHORIZED"
+	CodeRateLimited                ErrorCode = "RATE_LIMITED"
+	CodeRouteNotFound              ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed           ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeOrderNotFound              ErrorCode = "ORDER_NOT_FOUND"
+	CodeOrderExists                ErrorCode = "ORDER_ALREADY_EXISTS"
+	CodeIdempotencyKeyInUse        ErrorCode = "IDEMPOTENCY_KEY_IN_USE"
+	CodeOrderNotCancellable        ErrorCode = "ORDER_NOT_CANCELLABLE"
+	CodeOrderLocked                ErrorCode = "ORDER_LOCKED"
+	CodeOrderNotDeleted            ErrorCode = "ORDER_NOT_DELETED"
+	CodeInvalidTransition          ErrorCode = "INVALID_TRANSITION"
+	CodeInvalidItem                ErrorCode = "INVALID_ITEM"
+	CodeItemUnavailable            ErrorCode = "ITEM_UNAVAILABLE"
+	CodeInsufficientStock          ErrorCode = "INSUFFICIENT_STOCK"
+	CodeItemOutsideWindow          ErrorCode = "ITEM_OUTSIDE_WINDOW"
+	CodeItemBlocked                ErrorCode = "ITEM_BLOCKED"
+	CodeItemNotAllowed             ErrorCode = "ITEM_NOT_ALLOWED"
+	CodeCatalogBadResponse         ErrorCode = "CATALOG_BAD_RESPONSE"
+	CodeCatalogUnavailable         ErrorCode = "CATALOG_UNAVAILABLE"
+	CodeUnknownCustomer            ErrorCode = "UNKNOWN_CUSTOMER"
+	CodeCustomerServiceUnavailable ErrorCode = "CUSTOMER_SERVICE_UNAVAILABLE"
+	CodeRequestCancelled           ErrorCode = "REQUEST_CANCELLED"
+	CodeRequestTimeout             ErrorCode = "REQUEST_TIMEOUT"
+	CodeDeadlineExceeded           ErrorCode = "DEADLINE_EXCEEDED"
+	CodeUnsupportedAPIVersion      ErrorCode = "UNSUPPORTED_API_VERSION"
+	CodeMaintenance                ErrorCode = "MAINTENANCE"
+	CodeOverloaded                 ErrorCode = "OVERLOADED"
+	CodeInternal                   ErrorCode = "INTERNAL_ERROR"
+)
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default preparation times used to estimate when an order will be ready.
+const (
+	defaultPrepBase    = 5 * time.Minute
+	defaultPrepPerItem = time.Minute
+)
+
+// prepTimes drive the EstimatedReadyAt of new orders.
+type prepTimes struct {
+	// Base is spent on every order regardless of its size.
+	Base time.Duration
+	// PerItem is added for each unit ordered whose catalog entry has no
+	// prep_seconds of its own.
+	PerItem time.Duration
+}
+
+// resolvePrepTimes reads PREP_TIME_BASE (default 5m) and PREP_TIME_PER_ITEM
+// (default 1m).
+func resolvePrepTimes() (prepTimes, error) {
+	p := prepTimes{Base: defaultPrepBase, PerItem: defaultPrepPerItem}
+	if v := setting("PREP_TIME_BASE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return prepTimes{}, fmt.Errorf("invalid PREP_TIME_BASE %q: must be a non-negative duration", v)
+		}
+		p.Base = d
+	}
+	if v := setting("PREP_TIME_PER_ITEM"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return prepTimes{}, fmt.Errorf("invalid PREP_TIME_PER_ITEM %q: must be a non-negative duration", v)
+		}
+		p.PerItem = d
+	}
+	return p, nil
+}
+
+// estimate returns how long lines should take to prepare. items may be nil
+// when validation was skipped, in which case PerItem applies throughout.
+func (p prepTimes) estimate(lines []LineItem, items map[string]catalogItem) time.Duration {
+	d := p.Base
+	for _, li := range lines {
+		per := p.PerItem
+		if s := items[li.ItemID].PrepSeconds; s != nil {
+			per = time.Duration(*s) * time.Second
+		}
+		d += per * time.Duration(li.Quantity)
+	}
+	return d
+}
+
+// restartEstimate moves an order's EstimatedReadyAt so that its estimated
+// prep time counts from started, when the kitchen began preparing it,
+// instead of from when it was placed.
+func restartEstimate(o Order, started time.Time) Order {
+	if o.EstimatedReadyAt == nil {
+		return o
+	}
+	eta := started.Add(o.EstimatedReadyAt.Sub(o.CreatedAt))
+	o.EstimatedReadyAt = &eta
+	return o
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrepTimesEstimate(t *testing.T) {
+	p := prepTimes{Base: 5 * time.Minute, PerItem: time.Minute}
+	thirty := 30
+	items := map[string]catalogItem{"1": {ID: "1", PrepSeconds: &thirty}}
+	lines := []LineItem{{ItemID: "1", Quantity: 2}, {ItemID: "2", Quantity: 3}}
+
+	if got, want := p.estimate(lines, items), 5*time.Minute+time.Minute+3*time.Minute; got != want {
+		t.Errorf("estimate = %v, want %v", got, want)
+	}
+	if got, want := p.estimate(lines, nil), 5*time.Minute+5*time.Minute; got != want {
+		t.Errorf("estimate without catalog items = %v, want %v", got, want)
+	}
+}
+
+func TestRestartEstimate(t *testing.T) {
+	eta := testTime.Add(10 * time.Minute)
+	o := Order{CreatedAt: testTime, EstimatedReadyAt: &eta}
+	started := testTime.Add(time.Hour)
+
+	got := restartEstimate(o, started)
+	if want := started.Add(10 * time.Minute); !got.EstimatedReadyAt.Equal(want) {
+		t.Errorf("EstimatedReadyAt = %v, want %v", got.EstimatedReadyAt, want)
+	}
+	if !o.EstimatedReadyAt.Equal(eta) {
+		t.Error("restartEstimate modified its argument")
+	}
+	if got := restartEstimate(Order{}, started); got.EstimatedReadyAt != nil {
+		t.Errorf("EstimatedReadyAt = %v for an order without one, want nil", got.EstimatedReadyAt)
+	}
+}
+
+func TestNewOrderGetsEstimate(t *testing.T) {
+	h := newTestHandler(t, WithPrepTimes(prepTimes{Base: 5 * time.Minute, PerItem: time.Minute}))
+	order := createTestOrder(t, h.routes(), `{"customer_id":"c1","items":[{"item_id":"1","quantity":3}]}`)
+
+	if want := testTime.Add(8 * time.Minute); order.EstimatedReadyAt == nil || !order.EstimatedReadyAt.Equal(want) {
+		t.Errorf("estimated_ready_at = %v, want %v", order.EstimatedReadyAt, want)
+	}
+}
+
+// Starting preparation restarts the estimate in the same store update as
+// the status change, so both are stored together.
+func TestPreparingRestartsStoredEstimate(t *testing.T) {
+	now := testTime
+	h := newTestHandler(t,
+		WithPrepTimes(prepTimes{Base: 5 * time.Minute, PerItem: time.Minute}),
+		WithClock(func() time.Time { return now }),
+	)
+	routes := h.routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	now = testTime.Add(time.Hour)
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"preparing"}`)
+	wantStatus(t, rec, http.StatusOK)
+
+	want := now.Add(6 * time.Minute)
+	if got := decodeBody[Order](t, rec); got.EstimatedReadyAt == nil || !got.EstimatedReadyAt.Equal(want) {
+		t.Errorf("response estimated_ready_at = %v, want %v", got.EstimatedReadyAt, want)
+	}
+	stored, err := h.store.Get(order.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if stored.Status != StatusPreparing || stored.EstimatedReadyAt == nil || !stored.EstimatedReadyAt.Equal(want) {
+		t.Errorf("stored status %q, estimated_ready_at %v; want preparing, %v", stored.Status, stored.EstimatedReadyAt, want)
+	}
+}
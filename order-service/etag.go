@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// orderETag returns a weak ETag derived from the order's contents, so it
+// changes whenever the status or items change. Timings is left out: it is
+// derived from the status history and only some responses carry it, and
+// every representation of the same order must share one ETag.
+func orderETag(o Order) string {
+	o.Timings = nil
+	data, _ := json.Marshal(o)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// weak comparison as RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOrder sends an order with its ETag, or 304 if the client's copy is
+// still current.
+func writeOrder(w http.ResponseWriter, r *http.Request, o Order) {
+	etag := orderETag(o)
+	w.Header().Set("ETag", etag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOrderETagIgnoresTimings(t *testing.T) {
+	o := storedOrder("o1")
+	with := o
+	with.Timings = map[string]float64{StatusReceived: 30}
+	if orderETag(o) != orderETag(with) {
+		t.Fatal("Timings changed the ETag")
+	}
+	o.Status = StatusPreparing
+	if orderETag(o) == orderETag(with) {
+		t.Fatal("status change kept the ETag")
+	}
+}
+
+func TestStatusUpdateETagMatchesGet(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	o := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+o.ID+"/status", `{"status":"preparing"}`)
+	wantStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+o.ID, "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Fatalf("GET ETag = %s, PATCH returned %s", got, etag)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+o.ID, "", "If-None-Match", etag)
+	wantStatus(t, rec, http.StatusNotModified)
+}
+
+func TestEtagMatches(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   bool
+	}{
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"x", W/"abc"`, true},
+		{`*`, true},
+		{`W/"abd"`, false},
+	} {
+		if got := etagMatches(tc.header, `W/"abc"`); got != tc.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestGetOrderConditional(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	o := createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+o.ID, "")
+	wantStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /orders/{id} sent no ETag")
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+o.ID, "", "If-None-Match", etag)
+	wantStatus(t, rec, http.StatusNotModified)
+	if rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("304 body = %q, ETag = %q", rec.Body.String(), rec.Header().Get("ETag"))
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+o.ID, "", "If-None-Match", `W/"stale"`)
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); got.ID != o.ID {
+		t.Errorf("body = %+v", got)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Order event types recorded in the event log.
+const (
+	EventOrderCreated  = "OrderCreated"
+	EventStatusChanged = "StatusChanged"
+	EventOrderDeleted  = "OrderDeleted"
+	EventOrderRestored = "OrderRestored"
+	EventItemsChanged  = "ItemsChanged"
+	// EventOrderAssigned records From and To as the previous and new
+	// assignee.
+	EventOrderAssigned = "OrderAssigned"
+)
+
+// OrderEvent is one entry in an order's history.
+type OrderEvent struct {
+	Type      string    `json:"type"`
+	OrderID   string    `json:"order_id"`
+	At        time.Time `json:"at"`
+	RequestID string    `json:"request_id,omitempty"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+}
+
+// EventLog is an append-only record of what happened to each order.
+type EventLog interface {
+	Append(e OrderEvent)
+	// Events returns the events for orderID oldest first, never nil.
+	Events(orderID string) []OrderEvent
+	// Forget drops the history of an order that no longer exists.
+	Forget(orderID string)
+	// Clear drops every order's history.
+	Clear()
+}
+
+const defaultEventsPerOrder = 100
+
+// MemoryEventLog keeps up to maxPerOrder events per order in memory,
+// dropping the oldest once an order exceeds it. It tracks at most maxOrders
+// orders, dropping the history of the order it first heard of once full,
+// so like MemoryStore it cannot grow without bound.
+type MemoryEventLog struct {
+	mu          sync.Mutex
+	maxPerOrder int
+	maxOrders   int
+	// orders holds one *orderHistory per order, in the order they were
+	// first seen; byID indexes it.
+	orders *list.List
+	byID   map[string]*list.Element
+}
+
+type orderHistory struct {
+	orderID string
+	events  []OrderEvent
+}
+
+// NewMemoryEventLog returns an empty log; a non-positive maxOrders means
+// defaultMaxOrders.
+func NewMemoryEventLog(maxPerOrder, maxOrders int) *MemoryEventLog {
+	if maxOrders < 1 {
+		maxOrders = defaultMaxOrders
+	}
+	return &MemoryEventLog{maxPerOrder: maxPerOrder, maxOrders: maxOrders, orders: list.New(), byID: make(map[string]*list.Element)}
+}
+
+func (l *MemoryEventLog) Append(e OrderEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.byID[e.OrderID]
+	if !ok {
+		if l.orders.Len() >= l.maxOrders {
+			oldest := l.orders.Front()
+			delete(l.byID, oldest.Value.(*orderHistory).orderID)
+			l.orders.Remove(oldest)
+		}
+		el = l.orders.PushBack(&orderHistory{orderID: e.OrderID})
+		l.byID[e.OrderID] = el
+	}
+	history := el.Value.(*orderHistory)
+	history.events = append(history.events, e)
+	if len(history.events) > l.maxPerOrder {
+		history.events = history.events[len(history.events)-l.maxPerOrder:]
+	}
+}
+
+func (l *MemoryEventLog) Events(orderID string) []OrderEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := []OrderEvent{}
+	if el, ok := l.byID[orderID]; ok {
+		events = append(events, el.Value.(*orderHistory).events...)
+	}
+	return events
+}
+
+func (l *MemoryEventLog) Forget(orderID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.byID[orderID]; ok {
+		l.orders.Remove(el)
+		delete(l.byID, orderID)
+	}
+}
+
+func (l *MemoryEventLog) Clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.orders.Init()
+	l.byID = make(map[string]*list.Element)
+}
+
+// resolveEventsPerOrder reads EVENT_LOG_MAX_PER_ORDER, defaulting to 100.
+func resolveEventsPerOrder() (int, error) {
+	v := setting("EVENT_LOG_MAX_PER_ORDER")
+	if v == "" {
+		return defaultEventsPerOrder, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid EVENT_LOG_MAX_PER_ORDER %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// recordEvent appends an event for o, stamped with the current request ID.
+// The event is also pushed to live /orders/stream subscribers.
+func (h *handler) recordEvent(ctx context.Context, eventType string, o Order, from, to string) {
+	e := OrderEvent{
+		Type:      eventType,
+		OrderID:   o.ID,
+		At:        h.now().UTC(),
+		RequestID: requestIDFrom(ctx),
+		From:      from,
+		To:        to,
+	}
+	h.events.Append(e)
+	h.broker.publish(streamFrame{OrderEvent: e, Order: o})
+}
+
+// orderEvents returns an order's history. Soft-deleted orders keep theirs so
+// disputes can still be investigated.
+func (h *handler) orderEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, err := h.store.Get(id); err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+	writeJSON(w, http.StatusOK, h.events.Events(id))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryEventLogCapsEventsPerOrder(t *testing.T) {
+	l := NewMemoryEventLog(2, 10)
+	for _, to := range []string{"preparing", "ready", "delivered"} {
+		l.Append(OrderEvent{Type: EventStatusChanged, OrderID: "a", To: to})
+	}
+
+	events := l.Events("a")
+	if len(events) != 2 || events[0].To != "ready" || events[1].To != "delivered" {
+		t.Errorf("events = %+v, want the two newest", events)
+	}
+	if events := l.Events("missing"); events == nil || len(events) != 0 {
+		t.Errorf("events for an unknown order = %#v, want an empty slice", events)
+	}
+}
+
+func TestMemoryEventLogCapsOrders(t *testing.T) {
+	l := NewMemoryEventLog(10, 2)
+	l.Append(OrderEvent{Type: EventOrderCreated, OrderID: "a"})
+	l.Append(OrderEvent{Type: EventOrderCreated, OrderID: "b"})
+	l.Append(OrderEvent{Type: EventStatusChanged, OrderID: "a"})
+	l.Append(OrderEvent{Type: EventOrderCreated, OrderID: "c"})
+
+	if n := len(l.Events("a")); n != 0 {
+		t.Errorf("order a kept %d events, want its history dropped", n)
+	}
+	if len(l.Events("b")) != 1 || len(l.Events("c")) != 1 {
+		t.Error("the newer orders lost their history")
+	}
+	if len(l.byID) != 2 || l.orders.Len() != 2 {
+		t.Errorf("log tracks %d orders, want 2", len(l.byID))
+	}
+}
+
+func TestMemoryEventLogForgetAndClear(t *testing.T) {
+	l := NewMemoryEventLog(10, 10)
+	l.Append(OrderEvent{Type: EventOrderCreated, OrderID: "a"})
+	l.Append(OrderEvent{Type: EventOrderCreated, OrderID: "b"})
+
+	l.Forget("a")
+	l.Forget("missing")
+	if len(l.Events("a")) != 0 || len(l.Events("b")) != 1 {
+		t.Error("Forget dropped the wrong history")
+	}
+	l.Clear()
+	if len(l.Events("b")) != 0 || l.orders.Len() != 0 {
+		t.Error("Clear kept some history")
+	}
+}
+
+func TestResolveEventsPerOrder(t *testing.T) {
+	t.Setenv("EVENT_LOG_MAX_PER_ORDER", "")
+	if n, err := resolveEventsPerOrder(); n != defaultEventsPerOrder || err != nil {
+		t.Errorf("default = %d, %v", n, err)
+	}
+	t.Setenv("EVENT_LOG_MAX_PER_ORDER", "0")
+	if _, err := resolveEventsPerOrder(); err == nil {
+		t.Error("EVENT_LOG_MAX_PER_ORDER=0 accepted")
+	}
+}
+
+func TestOrderEventsEndpoint(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantStatus(t, serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"preparing"}`), http.StatusOK)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+order.ID+"/events", "")
+	wantStatus(t, rec, http.StatusOK)
+	events := decodeBody[[]OrderEvent](t, rec)
+	if len(events) != 2 || events[0].Type != EventOrderCreated || events[1].Type != EventStatusChanged {
+		t.Fatalf("events = %+v, want created then status changed", events)
+	}
+	if events[1].From != StatusReceived || events[1].To != StatusPreparing {
+		t.Errorf("status event = %+v, want received -> preparing", events[1])
+	}
+
+	wantError(t, serve(t, routes, http.MethodGet, "/orders/missing/events", ""), http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestSweepAndFlushForgetHistory(t *testing.T) {
+	h := newTestHandler(t, WithAdmin(true))
+	routes := h.routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantStatus(t, serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"cancelled"}`), http.StatusOK)
+
+	h.now = func() time.Time { return testTime.Add(48 * time.Hour) }
+	if n := h.sweepExpired(24 * time.Hour); n != 1 {
+		t.Fatalf("sweepExpired removed %d orders, want 1", n)
+	}
+	if n := len(h.events.Events(order.ID)); n != 0 {
+		t.Errorf("purged order kept %d events", n)
+	}
+
+	other := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/admin/orders", ""), http.StatusOK)
+	if n := len(h.events.Events(other.ID)); n != 0 {
+		t.Errorf("flushed order kept %d events", n)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+)
+
+const subjectOrderCreated = "order.created"
+
+// EventPublisher delivers domain events to downstream consumers.
+type EventPublisher interface {
+	Publish(subject string, payload []byte) error
+}
+
+// natsPublisher publishes events to a NATS server.
+type natsPublisher struct {
+	conn *nats.Conn
+}
+
+func newNATSPublisher(url string) (*natsPublisher, error) {
+	conn, err := nats.Connect(url, nats.Name("order-service"))
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn}, nil
+}
+
+func (p *natsPublisher) Publish(subject string, payload []byte) error {
+	return p.conn.Publish(subject, payload)
+}
+
+func (p *natsPublisher) Close() {
+	p.conn.Drain()
+}
+
+// noopPublisher drops events; it is used when NATS_URL is not configured.
+type noopPublisher struct{}
+
+func (noopPublisher) Publish(string, []byte) error { return nil }
+
+// publishOrderCreated announces a new order. Failures are logged only, since
+// the order has already been accepted.
+func (h *handler) publishOrderCreated(ctx context.Context, o Order) {
+	payload, err := json.Marshal(o)
+	if err != nil {
+		requestLogger(ctx).Error("encoding order event", "order_id", o.ID, "error", err)
+		return
+	}
+	if err := h.publisher.Publish(subjectOrderCreated, payload); err != nil {
+		requestLogger(ctx).Error("publishing order event", "subject", subjectOrderCreated, "order_id", o.ID, "error", err)
+	}
+}
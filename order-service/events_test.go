@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// recordingPublisher keeps every event it is given.
+type recordingPublisher struct {
+	mu       sync.Mutex
+	subjects []string
+	payloads [][]byte
+	err      error
+}
+
+func (p *recordingPublisher) Publish(subject string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subjects = append(p.subjects, subject)
+	p.payloads = append(p.payloads, payload)
+	return p.err
+}
+
+func TestCreateOrderPublishesEvent(t *testing.T) {
+	pub := &recordingPublisher{}
+	routes := newTestHandler(t, WithPublisher(pub)).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	if len(pub.subjects) != 1 || pub.subjects[0] != subjectOrderCreated {
+		t.Fatalf("published %v, want one %s", pub.subjects, subjectOrderCreated)
+	}
+	var event Order
+	if err := json.Unmarshal(pub.payloads[0], &event); err != nil {
+		t.Fatalf("decoding event: %v", err)
+	}
+	if event.ID != order.ID || event.CustomerID != "c1" {
+		t.Errorf("event = %+v, want the created order", event)
+	}
+}
+
+// The order is already stored, so a broker outage must not fail the request.
+func TestCreateOrderSurvivesPublishFailure(t *testing.T) {
+	pub := &recordingPublisher{err: errors.New("nats: connection closed")}
+	routes := newTestHandler(t, WithPublisher(pub)).routes()
+	createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	if orders := decodeBody[[]Order](t, rec); len(orders) != 1 {
+		t.Errorf("stored %d orders, want 1", len(orders))
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// expandedLineItem is a line item with the catalog's current name and
+// price for it, as returned by GET /orders/{id}?expand=items.
+type expandedLineItem struct {
+	LineItem
+	Name           string `json:"name,omitempty"`
+	UnitPriceCents *int   `json:"unit_price_cents,omitempty"`
+}
+
+// expandedOrder is an order whose items carry their catalog details.
+// ItemsPartial is set when the catalog could not name every item, whether
+// because it was unreachable or the item is no longer listed; those items
+// keep only their id and quantity.
+type expandedOrder struct {
+	Order
+	Items        []expandedLineItem
+	ItemsPartial bool
+}
+
+func (e expandedOrder) MarshalJSON() ([]byte, error) {
+	type plainOrder Order
+	return json.Marshal(struct {
+		plainOrder
+		Items        []expandedLineItem `json:"items"`
+		ItemsPartial bool               `json:"items_partial,omitempty"`
+	}{plainOrder(e.Order), e.Items, e.ItemsPartial})
+}
+
+// parseExpand reads the expand query parameter, a comma-separated list of
+// which only "items" is known, reporting whether items should be expanded.
+func parseExpand(q url.Values) (bool, error) {
+	v := q.Get("expand")
+	if v == "" {
+		return false, nil
+	}
+	for _, part := range strings.Split(v, ",") {
+		if strings.TrimSpace(part) != "items" {
+			return false, fmt.Errorf("unknown expand %q: only items can be expanded", part)
+		}
+	}
+	return true, nil
+}
+
+// expandItems looks each item of o up in the catalog, at most
+// h.catalogWorkers at a time and through the same cache as validation.
+// A failed lookup never fails the response: the item is left bare and the
+// result marked partial.
+func (h *handler) expandItems(ctx context.Context, o Order) expandedOrder {
+	var mu sync.Mutex
+	found := make(map[string]catalogItem, len(o.Items))
+	var g errgroup.Group
+	g.SetLimit(h.catalogWorkers)
+	for _, id := range o.ItemIDs() {
+		g.Go(func() error {
+			items, err := h.catalog.ValidateItems(ctx, []string{id})
+			if err != nil {
+				requestLogger(ctx).Warn("expanding order item", "order_id", o.ID, "item_id", id, "error", err)
+				return nil
+			}
+			mu.Lock()
+			found[id] = items[id]
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+
+	e := expandedOrder{Order: o, Items: make([]expandedLineItem, len(o.Items))}
+	for i, li := range o.Items {
+		e.Items[i].LineItem = li
+		item, ok := found[li.ItemID]
+		if !ok {
+			e.ItemsPartial = true
+			continue
+		}
+		price := item.PriceCents()
+		e.Items[i].Name, e.Items[i].UnitPriceCents = item.Name, &price
+	}
+	return e
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// expandedBody is the JSON of GET /orders/{id}?expand=items.
+type expandedBody struct {
+	ID    string `json:"id"`
+	Items []struct {
+		ItemID         string `json:"item_id"`
+		Quantity       int    `json:"quantity"`
+		Name           string `json:"name"`
+		UnitPriceCents *int   `json:"unit_price_cents"`
+	} `json:"items"`
+	ItemsPartial bool `json:"items_partial"`
+}
+
+// namingCatalog names every item "Item <id>" at 1.25 unless *down is set,
+// in which case lookups of that item fail as an outage.
+func namingCatalog(down *string) *stubCatalog {
+	return &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		items := make(map[string]catalogItem, len(ids))
+		for _, id := range ids {
+			if id == *down {
+				return nil, fmt.Errorf("%w: connection refused", errCatalogUnavailable)
+			}
+			items[id] = catalogItem{ID: id, Name: "Item " + id, Price: 1.25, Available: true}
+		}
+		return items, nil
+	}}
+}
+
+func TestGetOrderExpandItems(t *testing.T) {
+	var down string
+	catalog := namingCatalog(&down)
+	routes := newTestHandler(t, WithCatalog(catalog)).routes()
+	o := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":2},{"item_id":"2","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+o.ID, "")
+	wantStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); strings.Contains(body, `"name"`) || strings.Contains(body, "items_partial") {
+		t.Errorf("unexpanded order = %s, want bare items", body)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Error("unexpanded order has no ETag")
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+o.ID+"?expand=items", "")
+	wantStatus(t, rec, http.StatusOK)
+	if etag := rec.Header().Get("ETag"); etag != "" {
+		t.Errorf("expanded order has ETag %s", etag)
+	}
+	e := decodeBody[expandedBody](t, rec)
+	if e.ID != o.ID || e.ItemsPartial || len(e.Items) != 2 {
+		t.Fatalf("expanded order = %+v, want both items named", e)
+	}
+	for _, it := range e.Items {
+		if it.Name != "Item "+it.ItemID || it.UnitPriceCents == nil || *it.UnitPriceCents != 125 {
+			t.Errorf("item = %+v, want its catalog name and price", it)
+		}
+	}
+	if e.Items[0].Quantity != 2 {
+		t.Errorf("quantity = %d, want the ordered 2", e.Items[0].Quantity)
+	}
+}
+
+func TestGetOrderExpandItemsCatalogDown(t *testing.T) {
+	var down string
+	routes := newTestHandler(t, WithCatalog(namingCatalog(&down))).routes()
+	o := createTestOrder(t, routes, orderOf("1", "2"))
+
+	down = "2"
+	rec := serve(t, routes, http.MethodGet, "/orders/"+o.ID+"?expand=items", "")
+	wantStatus(t, rec, http.StatusOK)
+	e := decodeBody[expandedBody](t, rec)
+	if !e.ItemsPartial || len(e.Items) != 2 {
+		t.Fatalf("expanded order = %+v, want it marked partial", e)
+	}
+	if e.Items[0].Name != "Item 1" || e.Items[1].ItemID != "2" || e.Items[1].Name != "" || e.Items[1].UnitPriceCents != nil {
+		t.Errorf("items = %+v, want 1 named and 2 left bare", e.Items)
+	}
+}
+
+func TestGetOrderExpandRejectsUnknown(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	o := createTestOrder(t, routes, simpleOrder)
+	wantError(t, serve(t, routes, http.MethodGet, "/orders/"+o.ID+"?expand=customer", ""), http.StatusBadRequest, CodeInvalidRequest)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders/"+o.ID+"?expand=items,%20items", ""), http.StatusOK)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCSVText(t *testing.T) {
+	for in, want := range map[string]string{
+		"":            "",
+		"alice":       "alice",
+		"=1+1":        "'=1+1",
+		"+44 20":      "'+44 20",
+		"-2":          "'-2",
+		"@SUM(A1:A2)": "'@SUM(A1:A2)",
+		"\tdata":      "'\tdata",
+		"\rdata":      "'\rdata",
+		"a=b":         "a=b",
+	} {
+		if got := csvText(in); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatCents(t *testing.T) {
+	for cents, want := range map[int]string{0: "0.00", 5: "0.05", 1250: "12.50", -199: "-1.99"} {
+		if got := formatCents(cents); got != want {
+			t.Errorf("formatCents(%d) = %q, want %q", cents, got, want)
+		}
+	}
+}
+
+func TestExportOrders(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	createTestOrder(t, routes, `{"id":"a","customer_id":"=HYPERLINK(\"http://evil\")","items":[{"item_id":"1","quantity":2}]}`)
+	createTestOrder(t, routes, `{"id":"b","customer_id":"bob","items":[{"item_id":"2","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/export?sort=created_at", "")
+	wantStatus(t, rec, http.StatusOK)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="orders-20240501.csv"` {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	rows, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing export: %v", err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(exportHeader, ",") {
+		t.Fatalf("rows = %q, want a header and two orders", rows)
+	}
+	byID := map[string][]string{}
+	for _, row := range rows[1:] {
+		byID[row[0]] = row
+	}
+	if got := byID["a"][3]; got != `'=HYPERLINK("http://evil")` {
+		t.Errorf("customer_id cell = %q, want it prefixed with a quote", got)
+	}
+	if got := byID["a"][4:6]; got[0] != "2" || got[1] != "5.00" {
+		t.Errorf("item_count, total = %q, want 2, 5.00", got)
+	}
+	if got := byID["b"][3]; got != "bob" {
+		t.Errorf("customer_id cell = %q, want bob", got)
+	}
+}
+
+func TestExportOrdersFilters(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	createTestOrder(t, routes, `{"customer_id":"alice","items":[{"item_id":"1","quantity":1}]}`)
+	createTestOrder(t, routes, `{"customer_id":"bob","items":[{"item_id":"1","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/export?customer=bob", "")
+	wantStatus(t, rec, http.StatusOK)
+	rows, _ := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if len(rows) != 2 || rows[1][3] != "bob" {
+		t.Errorf("rows = %q, want only bob's order", rows)
+	}
+}
+
+func TestExportOrdersRejectsOtherFormats(t *testing.T) {
+	routes := newTestHandler(t).routes()
+
+	wantError(t, serve(t, routes, http.MethodGet, "/orders/export?format=xlsx", ""), http.StatusBadRequest, CodeInvalidRequest)
+}
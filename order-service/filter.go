@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// orderFilter holds the query parameters that narrow GET /orders.
+type orderFilter struct {
+	status     string
+	itemID     string
+	customerID string
+	assignedTo string
+	tag        string
+	// from and to bound CreatedAt, inclusively; zero means unbounded.
+	from, to time.Time
+
+	includeDeleted bool
+}
+
+func parseOrderFilter(q url.Values) (orderFilter, error) {
+	f := orderFilter{
+		status:     q.Get("status"),
+		itemID:     q.Get("item"),
+		customerID: q.Get("customer"),
+		assignedTo: q.Get("assigned_to"),
+		tag:        strings.ToLower(q.Get("tag")),
+
+		includeDeleted: includeDeleted(q),
+	}
+	var err error
+	if f.from, err = parseTimeParam(q, "from"); err != nil {
+		return orderFilter{}, err
+	}
+	if f.to, err = parseTimeParam(q, "to"); err != nil {
+		return orderFilter{}, err
+	}
+	if !f.from.IsZero() && !f.to.IsZero() && f.from.After(f.to) {
+		return orderFilter{}, errors.New("from must not be after to")
+	}
+	return f, nil
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp from q.
+func parseTimeParam(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp such as 2024-05-01T00:00:00Z", name)
+	}
+	return t, nil
+}
+
+// includeDeleted reports whether ?include_deleted asks for soft-deleted
+// orders to be returned too.
+func includeDeleted(q url.Values) bool {
+	v, _ := strconv.ParseBool(q.Get("include_deleted"))
+	return v
+}
+
+func (f orderFilter) matches(o Order) bool {
+	if o.Deleted && !f.includeDeleted {
+		return false
+	}
+	if f.status != "" && o.Status != f.status {
+		return false
+	}
+	if f.customerID != "" && o.CustomerID != f.customerID {
+		return false
+	}
+	if f.assignedTo != "" && o.AssignedTo != f.assignedTo {
+		return false
+	}
+	if f.tag != "" && !slices.Contains(o.Tags, f.tag) {
+		return false
+	}
+	if f.itemID != "" && !slices.Contains(o.ItemIDs(), f.itemID) {
+		return false
+	}
+	if !f.from.IsZero() && o.CreatedAt.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && o.CreatedAt.After(f.to) {
+		return false
+	}
+	return true
+}
+
+// filterOrders returns the orders matching f, never nil.
+func filterOrders(orders []Order, f orderFilter) []Order {
+	result := []Order{}
+	for _, o := range orders {
+		if f.matches(o) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestListOrdersFiltersByStatus(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	first := createTestOrder(t, routes, simpleOrder)
+	createTestOrder(t, routes, simpleOrder)
+	if _, err := h.store.UpdateStatus(first.ID, StatusPreparing, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+	if all := decodeBody[[]Order](t, rec); len(all) != 2 {
+		t.Fatalf("GET /orders returned %d orders, want 2", len(all))
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders?status=preparing", "")
+	wantStatus(t, rec, http.StatusOK)
+	preparing := decodeBody[[]Order](t, rec)
+	if len(preparing) != 1 || preparing[0].ID != first.ID {
+		t.Fatalf("?status=preparing returned %+v, want only %s", preparing, first.ID)
+	}
+}
+
+func TestListOrdersEmpty(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodGet, "/orders?status=ready", "")
+	wantStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Fatalf("body = %q, want an empty array", body)
+	}
+}
+
+func TestListOrdersFiltersByItem(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	withTwo := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1},{"item_id":"2","quantity":3}]}`)
+	createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodGet, "/orders?item=2", "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[[]Order](t, rec)
+	if len(got) != 1 || got[0].ID != withTwo.ID {
+		t.Fatalf("?item=2 returned %+v, want only %s", got, withTwo.ID)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders?item=1", "")
+	if all := decodeBody[[]Order](t, rec); len(all) != 2 {
+		t.Fatalf("?item=1 returned %d orders, want 2", len(all))
+	}
+	rec = serve(t, routes, http.MethodGet, "/orders?item=3&status=received", "")
+	if none := decodeBody[[]Order](t, rec); len(none) != 0 {
+		t.Fatalf("?item=3 returned %+v, want none", none)
+	}
+}
+
+func TestListOrdersFiltersByCustomer(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	mine := createTestOrder(t, routes, `{"customer_id":"c2","items":[{"item_id":"1","quantity":1}]}`)
+	createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodGet, "/orders?customer=c2", "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[[]Order](t, rec)
+	if len(got) != 1 || got[0].ID != mine.ID || got[0].CustomerID != "c2" {
+		t.Fatalf("?customer=c2 returned %+v, want only %s", got, mine.ID)
+	}
+	rec = serve(t, routes, http.MethodGet, "/orders?customer=nobody", "")
+	if none := decodeBody[[]Order](t, rec); len(none) != 0 {
+		t.Fatalf("?customer=nobody returned %+v", none)
+	}
+}
+
+func TestListOrdersFiltersByTimeRange(t *testing.T) {
+	now := testTime
+	routes := newTestHandler(t, WithClock(func() time.Time { return now })).routes()
+	var ids []string
+	for _, hour := range []int{9, 10, 11, 12} {
+		now = time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC)
+		body := simpleOrder
+		if hour == 11 {
+			body = `{"customer_id":"c2","items":[{"item_id":"1","quantity":1}]}`
+		}
+		ids = append(ids, createTestOrder(t, routes, body).ID)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"from=2024-05-01T10:00:00Z&to=2024-05-01T11:00:00Z", ids[1:3]},
+		{"from=2024-05-01T11:30:00Z", ids[3:]},
+		{"to=2024-05-01T09:00:00Z", ids[:1]},
+		// Offsets are honoured: 12:00+02:00 is 10:00 UTC.
+		{"to=2024-05-01T12:00:00%2B02:00", ids[:2]},
+		{"from=2024-05-01T10:00:00Z&customer=c1", []string{ids[1], ids[3]}},
+		{"from=2024-05-01T10:00:00Z&sort=created_at&limit=1&offset=1", ids[2:3]},
+	} {
+		rec := serve(t, routes, http.MethodGet, "/orders?"+tc.query, "")
+		wantStatus(t, rec, http.StatusOK)
+		var got []string
+		for _, o := range decodeBody[[]Order](t, rec) {
+			got = append(got, o.ID)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("?%s returned %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestListOrdersRejectsBadTimeRange(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	for _, query := range []string{
+		"from=yesterday",
+		"to=2024-05-01",
+		"from=2024-05-02T00:00:00Z&to=2024-05-01T00:00:00Z",
+	} {
+		wantError(t, serve(t, routes, http.MethodGet, "/orders?"+query, ""), http.StatusBadRequest, CodeInvalidRequest)
+		wantError(t, serve(t, routes, http.MethodGet, "/orders/export?"+query, ""), http.StatusBadRequest, CodeInvalidRequest)
+	}
+	// A single instant is a valid range.
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders?from=2024-05-01T00:00:00Z&to=2024-05-01T00:00:00Z", ""), http.StatusOK)
+}
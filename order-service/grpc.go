@@ -0,0 +1,265 @@
+package main
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=order-service --go-grpc_out=. --go-grpc_opt=module=order-service proto/order.proto
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"order-service/orderpb"
+)
+
+// resolveGRPCPort reads the gRPC listen port from GRPC_PORT, defaulting to 9090.
+func resolveGRPCPort() (string, error) {
+	port := setting("GRPC_PORT")
+	if port == "" {
+		return "9090", nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid GRPC_PORT %q: must be a number between 1 and 65535", port)
+	}
+	return port, nil
+}
+
+// grpcServer serves the OrderService RPCs on top of h's store. API keys are
+// enforced from the "authorization" metadata, and calls are protected as
+// the HTTP order routes are: panics are recovered, clients are rate limited
+// and every call runs under REQUEST_TIMEOUT.
+func (h *handler) grpcServer() *grpc.Server {
+	interceptors := []grpc.UnaryServerInterceptor{h.grpcInterceptor, grpcRecoverInterceptor}
+	if h.rateLimiter != nil {
+		interceptors = append(interceptors, h.rateLimiter.grpcInterceptor(len(h.apiKeys) > 0))
+	}
+	interceptors = append(interceptors, grpcTimeoutInterceptor(h.requestTimeout))
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	orderpb.RegisterOrderServiceServer(s, &grpcOrders{h: h})
+	return s
+}
+
+// grpcInterceptor gives every call a request ID, checks its API key and
+// turns calls away during maintenance, matching what the HTTP middleware
+// does for the order routes.
+func (h *handler) grpcInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	if id, err := uuid.GenerateUUID(); err == nil {
+		ctx = context.WithValue(ctx, requestIDKey, id)
+	}
+	if len(h.apiKeys) > 0 {
+		key, ok := grpcBearerToken(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		if !validAPIKey(h.apiKeys, key) {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+	}
+	if h.maintenance.Load() {
+		return nil, status.Error(codes.Unavailable, "service is down for maintenance, please retry later")
+	}
+	resp, err := next(ctx, req)
+	requestLogger(ctx).Debug("rpc", "method", info.FullMethod, "code", status.Code(err).String())
+	return resp, err
+}
+
+// grpcRecoverInterceptor turns a panicking RPC into codes.Internal, as
+// recoverMiddleware does for HTTP; grpc-go would otherwise let the panic
+// take down the process. The stack trace is logged, never sent.
+func grpcRecoverInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		requestLogger(ctx).Error("panic serving rpc",
+			"method", info.FullMethod,
+			"panic", rec,
+			"stack", string(debug.Stack()),
+		)
+		resp, err = nil, status.Error(codes.Internal, "internal server error")
+	}()
+	return next(ctx, req)
+}
+
+// grpcInterceptor rejects clients that exceed their rate with
+// codes.ResourceExhausted and a "retry-after" header in seconds. Clients
+// are identified as by middleware: by API key when byKey is set, otherwise
+// by peer address.
+func (l *rateLimiter) grpcInterceptor(byKey bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		if delay := l.reserve(grpcClientKey(ctx, byKey)).retryAfter; delay > 0 {
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(delay.Seconds())))))
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return next(ctx, req)
+	}
+}
+
+// grpcClientKey is clientKey for a gRPC call.
+func grpcClientKey(ctx context.Context, byKey bool) string {
+	if byKey {
+		if key, ok := grpcBearerToken(ctx); ok {
+			return "key:" + key
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// grpcTimeoutInterceptor gives each call a deadline of d, or the caller's
+// own if that is sooner, as timeoutMiddleware does for HTTP. A call that
+// runs out of time fails with codes.DeadlineExceeded when the caller's
+// deadline passed and codes.Unavailable when d did.
+func grpcTimeoutInterceptor(d time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+		deadline := time.Now().Add(d)
+		if t, ok := ctx.Deadline(); ok && t.Before(deadline) {
+			deadline = t
+			ctx = context.WithValue(ctx, callerDeadlineKey, true)
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		if ctx.Err() != nil {
+			return nil, grpcError(timeoutError(ctx))
+		}
+		return next(ctx, req)
+	}
+}
+
+func grpcBearerToken(ctx context.Context) (string, bool) {
+	const prefix = "Bearer "
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", false
+	}
+	v := values[0]
+	if len(v) <= len(prefix) || !strings.EqualFold(v[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(v[len(prefix):]), true
+}
+
+// grpcOrders implements orderpb.OrderServiceServer.
+type grpcOrders struct {
+	orderpb.UnimplementedOrderServiceServer
+	h *handler
+}
+
+func (s *grpcOrders) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	o := Order{CustomerID: req.GetCustomerId(), Priority: int(req.GetPriority()), Currency: req.GetCurrency(), Notes: req.GetNotes(), Tags: req.GetTags()}
+	for _, li := range req.GetItems() {
+		o.Items = append(o.Items, LineItem{ItemID: li.GetItemId(), Quantity: int(li.GetQuantity())})
+	}
+	created, reqErr := s.h.placeOrder(ctx, o)
+	if reqErr != nil {
+		return nil, grpcError(reqErr)
+	}
+	return toProtoOrder(created), nil
+}
+
+func (s *grpcOrders) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	o, err := s.h.activeOrder(req.GetId())
+	if err != nil {
+		return nil, grpcError(storeError(ctx, err, "load order"))
+	}
+	return toProtoOrder(o), nil
+}
+
+func (s *grpcOrders) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	if req.GetLimit() < 0 || req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must be non-negative")
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	result := filterOrders(s.h.store.All(), orderFilter{
+		status:     req.GetStatus(),
+		itemID:     req.GetItemId(),
+		customerID: req.GetCustomerId(),
+		assignedTo: req.GetAssignedTo(),
+		tag:        strings.ToLower(req.GetTag()),
+	})
+	sortOrders(result, defaultSort)
+	resp := &orderpb.ListOrdersResponse{TotalCount: int32(len(result))}
+	for _, o := range paginate(result, limit, int(req.GetOffset())) {
+		resp.Orders = append(resp.Orders, toProtoOrder(o))
+	}
+	return resp, nil
+}
+
+// Quantities are int32 on the wire. validateLineItems caps them at
+// maxItemQuantity, so converting a stored quantity cannot truncate; this
+// stops compiling if the cap is ever raised past what int32 holds.
+const _ uint32 = math.MaxInt32 - maxItemQuantity
+
+func toProtoOrder(o Order) *orderpb.Order {
+	p := &orderpb.Order{
+		Id:          o.ID,
+		OrderNumber: o.OrderNumber,
+		CustomerId:  o.CustomerID,
+		Status:      o.Status,
+		TotalCents:  int64(o.TotalCents),
+		Priority:    int32(o.Priority),
+		Currency:    o.Currency,
+		AssignedTo:  o.AssignedTo,
+		Notes:       o.Notes,
+		Tags:        o.Tags,
+		CreatedAt:   timestamppb.New(o.CreatedAt),
+		UpdatedAt:   timestamppb.New(o.UpdatedAt),
+	}
+	if o.EstimatedReadyAt != nil {
+		p.EstimatedReadyAt = timestamppb.New(*o.EstimatedReadyAt)
+	}
+	for _, li := range o.Items {
+		p.Items = append(p.Items, &orderpb.LineItem{ItemId: li.ItemID, Quantity: int32(li.Quantity)})
+	}
+	return p
+}
+
+// grpcError maps an HTTP-shaped request error onto the closest gRPC code.
+func grpcError(err *requestError) error {
+	code := codes.Internal
+	switch err.status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.FailedPrecondition
+		if err.code == CodeOrderExists {
+			code = codes.AlreadyExists
+		}
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusGatewayTimeout:
+		code = codes.DeadlineExceeded
+	case statusClientClosedRequest:
+		code = codes.Canceled
+	}
+	return status.Error(code, err.msg)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"order-service/orderpb"
+)
+
+// dialTestGRPC serves h's gRPC API over an in-memory listener and returns
+// a client for it.
+func dialTestGRPC(t *testing.T, h *handler) orderpb.OrderServiceClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	s := h.grpcServer()
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return orderpb.NewOrderServiceClient(conn)
+}
+
+func TestGRPCCreateGetList(t *testing.T) {
+	client := dialTestGRPC(t, newTestHandler(t))
+	ctx := context.Background()
+
+	created, err := client.CreateOrder(ctx, &orderpb.CreateOrderRequest{
+		CustomerId: "c1",
+		Items:      []*orderpb.LineItem{{ItemId: "1", Quantity: 2}},
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if created.GetId() == "" || created.GetStatus() != StatusReceived || created.GetTotalCents() != 500 {
+		t.Fatalf("created = %v, want a received order totalling 500", created)
+	}
+
+	got, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: created.GetId()})
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if got.GetCustomerId() != "c1" || len(got.GetItems()) != 1 || got.GetItems()[0].GetQuantity() != 2 {
+		t.Errorf("GetOrder = %v", got)
+	}
+
+	list, err := client.ListOrders(ctx, &orderpb.ListOrdersRequest{CustomerId: "c1"})
+	if err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if list.GetTotalCount() != 1 || list.GetOrders()[0].GetId() != created.GetId() {
+		t.Errorf("ListOrders = %v", list)
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	client := dialTestGRPC(t, newTestHandler(t))
+	ctx := context.Background()
+
+	_, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetOrder(missing) = %v, want NotFound", err)
+	}
+	_, err = client.CreateOrder(ctx, &orderpb.CreateOrderRequest{CustomerId: "c1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateOrder without items = %v, want InvalidArgument", err)
+	}
+	_, err = client.ListOrders(ctx, &orderpb.ListOrdersRequest{Limit: -1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ListOrders(limit -1) = %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCRequiresAPIKey(t *testing.T) {
+	client := dialTestGRPC(t, newTestHandler(t, WithAPIKeys([]string{"k1"})))
+	req := &orderpb.GetOrderRequest{Id: "missing"}
+
+	if _, err := client.GetOrder(context.Background(), req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without a key = %v, want Unauthenticated", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer wrong")
+	if _, err := client.GetOrder(ctx, req); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("with a wrong key = %v, want Unauthenticated", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer k1")
+	if _, err := client.GetOrder(ctx, req); status.Code(err) != codes.NotFound {
+		t.Errorf("with the key = %v, want NotFound", err)
+	}
+}
+
+func TestGRPCError(t *testing.T) {
+	for httpStatus, want := range map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusConflict:            codes.FailedPrecondition,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+		statusClientClosedRequest:      codes.Canceled,
+		http.StatusInternalServerError: codes.Internal,
+	} {
+		if got := status.Code(grpcError(&requestError{status: httpStatus})); got != want {
+			t.Errorf("grpcError(%d) = %s, want %s", httpStatus, got, want)
+		}
+	}
+	if got := status.Code(grpcError(&requestError{status: http.StatusConflict, code: CodeOrderExists})); got != codes.AlreadyExists {
+		t.Errorf("grpcError(ORDER_ALREADY_EXISTS) = %s, want AlreadyExists", got)
+	}
+}
+
+func TestResolveGRPCPort(t *testing.T) {
+	t.Setenv("GRPC_PORT", "")
+	if port, err := resolveGRPCPort(); port != "9090" || err != nil {
+		t.Errorf("default = %q, %v; want 9090", port, err)
+	}
+	t.Setenv("GRPC_PORT", "70000")
+	if _, err := resolveGRPCPort(); err == nil {
+		t.Error("GRPC_PORT=70000 accepted")
+	}
+}
+
+// panickingStore is a store whose lookups panic.
+type panickingStore struct{ *MemoryStore }
+
+func (panickingStore) Get(string) (Order, error) { panic("corrupt index") }
+
+func TestGRPCRecoversPanics(t *testing.T) {
+	logs := captureLogs(t)
+	catalog, _ := newMockCatalog("1")
+	client := dialTestGRPC(t, newHandler(panickingStore{NewMemoryStore()}, WithCatalog(catalog)))
+	ctx := context.Background()
+
+	_, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: "o1"})
+	if status.Code(err) != codes.Internal || strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("panicking GetOrder = %v, want a bare Internal", err)
+	}
+	if !strings.Contains(logs.String(), "panic serving rpc") || !strings.Contains(logs.String(), "corrupt index") {
+		t.Errorf("panic was not logged:\n%s", logs)
+	}
+	// The server is still up.
+	if _, err := client.ListOrders(ctx, &orderpb.ListOrdersRequest{}); err != nil {
+		t.Errorf("ListOrders after the panic: %v", err)
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	client := dialTestGRPC(t, newTestHandler(t, WithRateLimit(1, 1)))
+	req := &orderpb.GetOrderRequest{Id: "missing"}
+	if _, err := client.GetOrder(context.Background(), req); status.Code(err) != codes.NotFound {
+		t.Errorf("first call = %v, want NotFound", err)
+	}
+	var header metadata.MD
+	_, err := client.GetOrder(context.Background(), req, grpc.Header(&header))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second call = %v, want ResourceExhausted", err)
+	}
+	if got := header.Get("retry-after"); len(got) != 1 || got[0] != "1" {
+		t.Errorf("retry-after = %v, want 1", got)
+	}
+}
+
+func TestGRPCRequestTimeout(t *testing.T) {
+	hanging := &stubCatalog{validate: func(ctx context.Context, _ []string) (map[string]catalogItem, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	req := &orderpb.CreateOrderRequest{CustomerId: "c1", Items: []*orderpb.LineItem{{ItemId: "1", Quantity: 1}}}
+
+	client := dialTestGRPC(t, newTestHandler(t, WithCatalog(hanging), WithRequestTimeout(20*time.Millisecond)))
+	if _, err := client.CreateOrder(context.Background(), req); status.Code(err) != codes.Unavailable {
+		t.Errorf("CreateOrder past REQUEST_TIMEOUT = %v, want Unavailable", err)
+	}
+
+	client = dialTestGRPC(t, newTestHandler(t, WithCatalog(hanging), WithRequestTimeout(time.Hour)))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.CreateOrder(ctx, req); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("CreateOrder past the caller's deadline = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestGRPCTimeoutInterceptor(t *testing.T) {
+	var got time.Time
+	next := func(ctx context.Context, _ any) (any, error) {
+		got, _ = ctx.Deadline()
+		return nil, nil
+	}
+	grpcTimeoutInterceptor(time.Minute)(context.Background(), nil, &grpc.UnaryServerInfo{}, next)
+	if d := time.Until(got); d <= 0 || d > time.Minute {
+		t.Errorf("deadline in %s, want within REQUEST_TIMEOUT", d)
+	}
+
+	past, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := grpcTimeoutInterceptor(time.Minute)(past, nil, &grpc.UnaryServerInfo{}, func(context.Context, any) (any, error) {
+		t.Error("handler ran past the caller's deadline")
+		return nil, nil
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("expired caller deadline = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestGRPCRejectsQuantityOverCap(t *testing.T) {
+	h := newTestHandler(t)
+	client := dialTestGRPC(t, h)
+	_, err := client.CreateOrder(context.Background(), &orderpb.CreateOrderRequest{
+		CustomerId: "c1",
+		Items:      []*orderpb.LineItem{{ItemId: "1", Quantity: math.MaxInt32}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateOrder with quantity MaxInt32 = %v, want InvalidArgument", err)
+	}
+
+	// Over HTTP the same bound applies, so every stored quantity survives
+	// the trip to int32.
+	o := createTestOrder(t, h.routes(), `{"customer_id":"c1","items":[{"item_id":"1","quantity":1000}]}`)
+	got, err := client.GetOrder(context.Background(), &orderpb.GetOrderRequest{Id: o.ID})
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if q := got.GetItems()[0].GetQuantity(); q != maxItemQuantity {
+		t.Errorf("quantity over gRPC = %d, want %d", q, maxItemQuantity)
+	}
+}
@@ -0,0 +1,942 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// handler serves the order API on top of an OrderStore.
+type handler struct {
+	store          OrderStore
+	idempotency    *idempotencyKeys
+	publisher      EventPublisher
+	allowedOrigins []string
+	apiKeys        []string
+	rateLimiter    *rateLimiter
+	maxBodyBytes   int64
+	maxBatchSize   int
+	now            func() time.Time
+	catalog        CatalogClient
+	catalogWorkers int
+	validationMode validationMode
+	requestTimeout time.Duration
+	cacheControl   string
+	events         EventLog
+	prettyJSON     bool
+	broker         *orderBroker
+	compressLevel  int
+	compressMin    int
+	adminEnabled   bool
+	slowRequest    time.Duration
+	basePath       string
+
+	revalidateAutoCancel bool
+	webhooks             *webhookNotifier
+	currency             string
+	maxNotesLength       int
+	maxItemIDLen         int
+	maxInFlight          int
+	ids                  IDGenerator
+	errorFormat          string
+	prepTimes            prepTimes
+	maxJSONDepth         int
+	maxUnknownItems      int
+	customers            CustomerDirectory
+	// maintenance turns away every order request with a 503; it can be
+	// flipped at runtime through /admin/maintenance.
+	maintenance atomic.Bool
+	// itemPolicy blocks or allows items ahead of the catalog; it can be
+	// replaced at runtime through /admin/item-policy.
+	itemPolicy atomic.Pointer[itemPolicy]
+	// workers runs background work, such as webhook deliveries, that
+	// shutdown must wait for.
+	workers *workerGroup
+}
+
+// HandlerOption customises the handler built by NewHandler.
+type HandlerOption func(*handler)
+
+// WithIdempotencyTTL sets how long Idempotency-Key values are remembered.
+func WithIdempotencyTTL(ttl time.Duration) HandlerOption {
+	return func(h *handler) { h.idempotency = newIdempotencyKeys(ttl) }
+}
+
+// WithPublisher sets where order events are sent.
+func WithPublisher(p EventPublisher) HandlerOption {
+	return func(h *handler) { h.publisher = p }
+}
+
+// WithAllowedOrigins enables CORS for the given browser origins.
+func WithAllowedOrigins(origins []string) HandlerOption {
+	return func(h *handler) { h.allowedOrigins = origins }
+}
+
+// WithAPIKeys requires one of keys as a bearer token on the order routes.
+func WithAPIKeys(keys []string) HandlerOption {
+	return func(h *handler) { h.apiKeys = keys }
+}
+
+// WithRateLimit limits each client on the order routes to rps requests per
+// second with the given burst.
+func WithRateLimit(rps float64, burst int) HandlerOption {
+	return func(h *handler) { h.rateLimiter = newRateLimiter(rps, burst) }
+}
+
+// WithMaxBodyBytes caps the size of request bodies on the order routes.
+func WithMaxBodyBytes(n int64) HandlerOption {
+	return func(h *handler) { h.maxBodyBytes = n }
+}
+
+// WithMaxJSONDepth caps how deeply request bodies on the order routes may
+// nest arrays and objects.
+func WithMaxJSONDepth(n int) HandlerOption {
+	return func(h *handler) { h.maxJSONDepth = n }
+}
+
+// WithMaxBatchSize caps how many orders POST /orders/batch accepts, and how
+// many ids the bulk status and batch-get endpoints take.
+func WithMaxBatchSize(n int) HandlerOption {
+	return func(h *handler) { h.maxBatchSize = n }
+}
+
+// WithClock replaces time.Now, letting tests pin order timestamps.
+func WithClock(now func() time.Time) HandlerOption {
+	return func(h *handler) { h.now = now }
+}
+
+// WithCatalog sets the catalog used to validate order items.
+func WithCatalog(c CatalogClient) HandlerOption {
+	return func(h *handler) { h.catalog = c }
+}
+
+// WithCatalogConcurrency bounds how many catalog lookups one request that
+// checks items one by one runs at once.
+func WithCatalogConcurrency(n int) HandlerOption {
+	return func(h *handler) { h.catalogWorkers = n }
+}
+
+// WithValidationMode sets how strictly items are checked against the catalog.
+func WithValidationMode(m validationMode) HandlerOption {
+	return func(h *handler) { h.validationMode = m }
+}
+
+// WithRequestTimeout bounds how long an order route may run before its
+// context is cancelled and the client gets a 503.
+func WithRequestTimeout(d time.Duration) HandlerOption {
+	return func(h *handler) { h.requestTimeout = d }
+}
+
+// WithCacheControl sets the Cache-Control header sent on order responses.
+func WithCacheControl(v string) HandlerOption {
+	return func(h *handler) { h.cacheControl = v }
+}
+
+// WithEventLog sets where order history is recorded.
+func WithEventLog(l EventLog) HandlerOption {
+	return func(h *handler) { h.events = l }
+}
+
+// WithPrettyJSON indents JSON responses unless a request passes ?pretty=false.
+func WithPrettyJSON(pretty bool) HandlerOption {
+	return func(h *handler) { h.prettyJSON = pretty }
+}
+
+// WithCompression gzips responses of at least minBytes at the given level;
+// a level of 0 turns compression off.
+func WithCompression(level, minBytes int) HandlerOption {
+	return func(h *handler) { h.compressLevel, h.compressMin = level, minBytes }
+}
+
+// WithAdmin mounts the /admin routes: the order flush, which exists only for
+// test and demo environments, the maintenance switch and the item policy.
+func WithAdmin(enabled bool) HandlerOption {
+	return func(h *handler) { h.adminEnabled = enabled }
+}
+
+// WithSlowRequestThreshold logs requests slower than d at WARN; 0 turns
+// this off.
+func WithSlowRequestThreshold(d time.Duration) HandlerOption {
+	return func(h *handler) { h.slowRequest = d }
+}
+
+// WithBasePath mounts every route under prefix, e.g. "/order-service".
+func WithBasePath(prefix string) HandlerOption {
+	return func(h *handler) { h.basePath = prefix }
+}
+
+// WithRevalidateAutoCancel cancels orders that fail revalidation, as long as
+// they can still be cancelled.
+func WithRevalidateAutoCancel(enabled bool) HandlerOption {
+	return func(h *handler) { h.revalidateAutoCancel = enabled }
+}
+
+// WithWebhookSecret sets the HMAC key used to sign status-change callbacks.
+func WithWebhookSecret(secret string) HandlerOption {
+	return func(h *handler) { h.webhooks.secret = []byte(secret) }
+}
+
+// WithWebhookClient sets the client callbacks are sent with.
+func WithWebhookClient(c *http.Client) HandlerOption {
+	return func(h *handler) { h.webhooks.client = c }
+}
+
+// WithWebhookDrainTimeout sets how long shutdown spends sending the
+// webhooks still queued before dropping them.
+func WithWebhookDrainTimeout(d time.Duration) HandlerOption {
+	return func(h *handler) { h.webhooks.drainTimeout = d }
+}
+
+// WithWebhookQueue replaces the in-memory webhook queue, e.g. with one kept
+// in durable storage so pending retries survive a restart.
+func WithWebhookQueue(q WebhookQueue) HandlerOption {
+	return func(h *handler) { h.webhooks.queue = q }
+}
+
+// WithDefaultCurrency sets the currency of orders that do not name one.
+func WithDefaultCurrency(code string) HandlerOption {
+	return func(h *handler) { h.currency = code }
+}
+
+// WithMaxInFlight turns requests away with 503 while n are already being
+// served. Zero sets no limit.
+func WithMaxInFlight(n int) HandlerOption {
+	return func(h *handler) { h.maxInFlight = n }
+}
+
+// WithMaxItemIDLength caps how long the item ids of an order may be.
+func WithMaxItemIDLength(n int) HandlerOption {
+	return func(h *handler) { h.maxItemIDLen = n }
+}
+
+// WithMaxNotesLength caps how many characters an order's notes may hold.
+func WithMaxNotesLength(n int) HandlerOption {
+	return func(h *handler) { h.maxNotesLength = n }
+}
+
+// WithIDGenerator replaces the UUID generator used for new order ids,
+// letting tests predict them.
+func WithIDGenerator(g IDGenerator) HandlerOption {
+	return func(h *handler) { h.ids = g }
+}
+
+// WithErrorFormat selects how error responses are written: errorFormatSimple
+// or errorFormatProblem.
+func WithErrorFormat(format string) HandlerOption {
+	return func(h *handler) { h.errorFormat = format }
+}
+
+// WithItemPolicy rejects the blocked items and, when allowed is not empty,
+// every item not on it, whatever the catalog says.
+func WithItemPolicy(blocked, allowed []string) HandlerOption {
+	return func(h *handler) { h.itemPolicy.Store(newItemPolicy(blocked, allowed)) }
+}
+
+// WithMaintenanceMode starts the handler in maintenance mode.
+func WithMaintenanceMode(enabled bool) HandlerOption {
+	return func(h *handler) { h.maintenance.Store(enabled) }
+}
+
+// WithPrepTimes sets how EstimatedReadyAt is worked out for new orders.
+func WithPrepTimes(p prepTimes) HandlerOption {
+	return func(h *handler) { h.prepTimes = p }
+}
+
+// WithMaxUnknownItems lets a multi-item order through a partial catalog
+// outage with up to n items unchecked. 0 disables per-item checking.
+func WithMaxUnknownItems(n int) HandlerOption {
+	return func(h *handler) { h.maxUnknownItems = n }
+}
+
+// WithCustomerDirectory only accepts orders from customers known to d.
+func WithCustomerDirectory(d CustomerDirectory) HandlerOption {
+	return func(h *handler) { h.customers = d }
+}
+
+// newHandler applies opts over the defaults. Build the HTTP router and the
+// gRPC server from the same handler so they share state such as the event
+// stream.
+func newHandler(store OrderStore, opts ...HandlerOption) *handler {
+	h := &handler{
+		store:          store,
+		idempotency:    newIdempotencyKeys(defaultIdempotencyTTL),
+		publisher:      noopPublisher{},
+		maxBodyBytes:   defaultMaxBodyBytes,
+		maxJSONDepth:   defaultMaxJSONDepth,
+		maxBatchSize:   defaultMaxBatchSize,
+		now:            time.Now,
+		catalog:        newHTTPCatalog("", nil, defaultCatalogTimeout, defaultRetryPolicy, defaultCatalogConcurrency),
+		catalogWorkers: defaultCatalogConcurrency,
+		validationMode: validationStrict,
+		requestTimeout: defaultRequestTimeout,
+		cacheControl:   defaultCacheControl,
+		events:         NewMemoryEventLog(defaultEventsPerOrder, defaultMaxOrders),
+		broker:         newOrderBroker(),
+		workers:        newWorkerGroup(),
+		compressLevel:  defaultCompressLevel,
+		compressMin:    defaultCompressMinBytes,
+		slowRequest:    defaultSlowRequestThreshold,
+		webhooks:       newWebhookNotifier(""),
+		currency:       defaultCurrency,
+		maxNotesLength: defaultMaxNotesLength,
+		maxItemIDLen:   defaultMaxItemIDLength,
+		ids:            uuidGenerator{},
+		errorFormat:    errorFormatSimple,
+		prepTimes:      prepTimes{Base: defaultPrepBase, PerItem: defaultPrepPerItem},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.webhooks.workers = h.workers
+	return h
+}
+
+// NewHandler builds the order service router backed by store.
+func NewHandler(store OrderStore, opts ...HandlerOption) http.Handler {
+	return newHandler(store, opts...).routes()
+}
+
+// routes builds the HTTP router for h.
+func (h *handler) routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(otelhttp.NewMiddleware("order-service"))
+	r.Use(routeSpanName)
+	r.Use(requestIDMiddleware)
+	r.Use(accessLogMiddleware)
+	if h.slowRequest > 0 {
+		r.Use(slowRequestMiddleware(h.slowRequest))
+	}
+	r.Use(metricsMiddleware)
+	r.Use(h.inFlightMiddleware)
+	if h.compressLevel > 0 {
+		r.Use(compressMiddleware(h.compressLevel, h.compressMin))
+	}
+	r.Use(prettyJSON(h.prettyJSON))
+	if h.errorFormat == errorFormatProblem {
+		r.Use(problemJSON)
+	}
+	r.Use(recoverMiddleware)
+	if len(h.allowedOrigins) > 0 {
+		r.Use(corsMiddleware(h.allowedOrigins))
+	}
+	// /orders/ is served as /orders rather than redirected, so clients
+	// that add the slash keep their method and body.
+	r.Use(middleware.StripSlashes)
+	r.NotFound(notFound)
+	r.MethodNotAllowed(methodNotAllowed(r))
+
+	r.Handle("/metrics", promhttp.Handler())
+	r.Get("/health", h.health)
+	r.Get("/health/detail", h.healthDetailHandler)
+	r.Get("/ready", h.ready)
+	// Kubernetes-style probe paths.
+	r.Get("/healthz", h.liveness)
+	r.Get("/readyz", h.readyz)
+	r.Get("/version", h.version)
+
+	if h.adminEnabled {
+		r.Group(func(r chi.Router) {
+			r.Use(securityHeaders(h.cacheControl))
+			if len(h.apiKeys) > 0 {
+				r.Use(apiKeyMiddleware(h.apiKeys))
+			}
+			r.Use(limitBody(h.maxBodyBytes))
+			// The maintenance switch has to stay reachable while
+			// maintenance mode is on, and the item policy with it.
+			r.Get("/admin/maintenance", h.getMaintenance)
+			r.Put("/admin/maintenance", h.setMaintenance)
+			r.Get("/admin/item-policy", h.getItemPolicy)
+			r.Put("/admin/item-policy", h.setItemPolicy)
+			r.Group(func(r chi.Router) {
+				r.Use(h.maintenanceMiddleware)
+				r.Delete("/admin/orders", h.flushOrders)
+			})
+		})
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(securityHeaders(h.cacheControl))
+		if len(h.apiKeys) > 0 {
+			r.Use(apiKeyMiddleware(h.apiKeys))
+		}
+		if h.rateLimiter != nil {
+			r.Use(h.rateLimiter.middleware(len(h.apiKeys) > 0))
+		}
+		r.Use(h.maintenanceMiddleware)
+		r.Use(limitBody(h.maxBodyBytes))
+		r.Use(limitJSONDepth(h.maxJSONDepth))
+		r.Use(apiVersionMiddleware)
+		h.ordersV1(r)
+	})
+	return withBasePath(h.basePath, r)
+}
+
+// ordersV1 registers the v1 order endpoints. A future version gets its own
+// method so its handlers can diverge while v1 clients keep this behaviour.
+func (h *handler) ordersV1(r chi.Router) {
+	// The stream is long-lived by design, so it sits outside the
+	// request timeout.
+	r.Get("/orders/stream", h.streamOrders)
+	r.Group(func(r chi.Router) {
+		r.Use(timeoutMiddleware(h.requestTimeout))
+		r.Post("/orders", h.createOrder)
+		r.Post("/orders/batch", h.createOrderBatch)
+		r.Post("/orders/import", h.importOrders)
+		r.Post("/orders/batch-get", h.getOrderBatch)
+		r.Post("/orders/status/bulk", h.updateStatusBulk)
+		r.Get("/orders", h.listOrders)
+		r.Get("/orders/export", h.exportOrders)
+		r.Get("/orders/stats", h.getOrderStats)
+		r.Get("/orders/{id}", h.getOrder)
+		r.Patch("/orders/{id}", h.updateItems)
+		r.Delete("/orders/{id}", h.deleteOrder)
+		r.Get("/orders/{id}/status", h.getStatus)
+		r.Patch("/orders/{id}/status", h.updateStatus)
+		r.Get("/orders/{id}/events", h.orderEvents)
+		r.Post("/orders/{id}/restore", h.restoreOrder)
+		r.Post("/orders/{id}/revalidate", h.revalidateOrder)
+		r.Post("/orders/{id}/assign", h.assignOrder)
+		r.Post("/orders/{id}/reorder", h.reorderOrder)
+	})
+}
+
+// limitBody stops reading request bodies after n bytes.
+func limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":          "ok",
+		"catalog_breaker": catalogBreaker.State().String(),
+	})
+}
+
+// healthDetailHandler reports dependency health. It always answers 200 so it
+// can double as a liveness probe; degradation is reported in the body.
+func (h *handler) healthDetailHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.healthDetail(r.Context()))
+}
+
+func (h *handler) ready(w http.ResponseWriter, r *http.Request) {
+	if failed := h.readinessFailures(r.Context()); len(failed) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"status": "not ready",
+			"failed": failed,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// liveness answers /healthz: the process is up and serving.
+func (h *handler) liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz answers /readyz with the same dependency checks as /ready.
+func (h *handler) readyz(w http.ResponseWriter, r *http.Request) {
+	if failed := h.readinessFailures(r.Context()); len(failed) > 0 {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{
+			"status": "unavailable",
+			"failed": failed,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *handler) createOrder(w http.ResponseWriter, r *http.Request) {
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		h.dryRunOrder(w, r)
+		return
+	}
+
+	var idempotencyKey string
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		idempotencyKey = clientKey(r, len(h.apiKeys) > 0) + " " + key
+		existing, replay, reqErr := h.reserveIdempotencyKey(r.Context(), idempotencyKey)
+		if reqErr != nil {
+			writeRequestError(w, reqErr)
+			return
+		}
+		if replay {
+			writeJSON(w, http.StatusOK, existing)
+			return
+		}
+		defer h.idempotency.Release(idempotencyKey)
+	}
+
+	var newOrder Order
+	if !decodeJSONBody(w, r, &newOrder) {
+		return
+	}
+	created, reqErr := h.placeOrder(r.Context(), newOrder)
+	if reqErr != nil {
+		writeRequestError(w, reqErr)
+		return
+	}
+	if idempotencyKey != "" {
+		h.idempotency.Remember(idempotencyKey, created.ID)
+	}
+	w.Header().Set("Location", "/orders/"+url.PathEscape(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// reserveIdempotencyKey claims key for this request. replay is set when key
+// already created an order, which is returned.
+func (h *handler) reserveIdempotencyKey(ctx context.Context, key string) (existing Order, replay bool, reqErr *requestError) {
+	for {
+		id, err := h.idempotency.Reserve(key)
+		if err != nil {
+			return Order{}, false, &requestError{status: http.StatusConflict, code: CodeIdempotencyKeyInUse, msg: err.Error()}
+		}
+		if id == "" {
+			return Order{}, false, nil
+		}
+		existing, err := h.store.Get(id)
+		if err == nil {
+			return existing, true, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return Order{}, false, storeError(ctx, err, "load order")
+		}
+		// The order is gone, e.g. evicted, so the key starts afresh.
+		h.idempotency.Forget(key, id)
+	}
+}
+
+// dryRunOrder answers POST /orders?dry_run=true: the order is validated and
+// priced exactly as it would be, but nothing is stored or announced.
+func (h *handler) dryRunOrder(w http.ResponseWriter, r *http.Request) {
+	var newOrder Order
+	if !decodeJSONBody(w, r, &newOrder) {
+		return
+	}
+	prepared, reqErr := h.prepareOrder(r.Context(), newOrder)
+	if reqErr != nil {
+		writeRequestError(w, reqErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, prepared)
+}
+
+// prepareOrder validates a client-submitted order against the catalog,
+// merges repeated items and fills in its status, total and timestamps. A
+// missing id is left for placeOrder to generate.
+func (h *handler) prepareOrder(ctx context.Context, newOrder Order) (Order, *requestError) {
+	newOrder.Notes = sanitizeNotes(newOrder.Notes)
+	newOrder.Tags = normalizeTags(newOrder.Tags)
+	if err := validateNewOrder(newOrder, h.maxNotesLength, h.maxItemIDLen); err != nil {
+		return Order{}, validationFailed(err)
+	}
+	if reqErr := h.checkCustomer(ctx, newOrder.CustomerID); reqErr != nil {
+		return Order{}, reqErr
+	}
+	newOrder.Items = mergeLineItems(newOrder.Items)
+
+	items, skipped, checks, reqErr := h.checkItems(ctx, newOrder.Items)
+	if reqErr != nil {
+		return Order{}, reqErr
+	}
+
+	newOrder.Status = StatusReceived
+	newOrder.TotalCents = totalCents(newOrder.Items, items)
+	if newOrder.Currency == "" {
+		newOrder.Currency = h.currency
+	}
+	newOrder.Currency = strings.ToUpper(newOrder.Currency)
+	newOrder.ValidationSkipped = skipped
+	newOrder.ItemChecks = checks
+	newOrder.CreatedAt = h.now().UTC()
+	newOrder.UpdatedAt = newOrder.CreatedAt
+	newOrder.StatusHistory = []statusChange{{Status: newOrder.Status, At: newOrder.CreatedAt}}
+	eta := newOrder.CreatedAt.Add(h.prepTimes.estimate(newOrder.Items, items))
+	newOrder.EstimatedReadyAt = &eta
+	return newOrder, nil
+}
+
+// placeOrder prepares a client-submitted order, then generates an id unless
+// the client supplied one, creates it and announces it.
+func (h *handler) placeOrder(ctx context.Context, newOrder Order) (Order, *requestError) {
+	newOrder, reqErr := h.prepareOrder(ctx, newOrder)
+	if reqErr != nil {
+		return Order{}, reqErr
+	}
+
+	if newOrder.ID == "" {
+		id, err := h.ids.NewID()
+		if err != nil {
+			requestLogger(ctx).Error("generating order id", "error", err)
+			return Order{}, &requestError{status: http.StatusInternalServerError, code: CodeInternal, msg: "failed to generate order id"}
+		}
+		newOrder.ID = id
+	}
+	created, err := h.store.Create(newOrder)
+	if err != nil {
+		return Order{}, storeError(ctx, err, "save order")
+	}
+	h.recordEvent(ctx, EventOrderCreated, created, "", created.Status)
+	h.publishOrderCreated(ctx, created)
+	return created, nil
+}
+
+// checkItems validates line items against the catalog according to the
+// configured validation mode, including any stock and availability window
+// the catalog reports.
+// skipped reports that the order is being accepted without validation.
+// checks is the per-item breakdown, filled in only when a catalog outage
+// made the items be checked one at a time.
+func (h *handler) checkItems(ctx context.Context, lines []LineItem) (items map[string]catalogItem, skipped bool, checks []itemCheck, reqErr *requestError) {
+	// The item policy is consulted first, even with validation off.
+	if err := h.itemPolicy.Load().check(lines); err != nil {
+		_, reqErr = h.catalogFailure(ctx, err)
+		return nil, false, nil, reqErr
+	}
+	if h.validationMode == validationOff {
+		return nil, true, nil, nil
+	}
+	ids := Order{Items: lines}.ItemIDs()
+	items, err := h.catalog.ValidateItems(ctx, ids)
+	if err == nil {
+		err = checkStock(lines, items)
+	}
+	if err == nil {
+		err = checkWindows(lines, items, h.now())
+	}
+	if err == nil {
+		return items, false, nil, nil
+	}
+	if h.maxUnknownItems > 0 && len(ids) > 1 && errors.Is(err, errCatalogUnavailable) && ctx.Err() == nil {
+		return h.checkEachItem(ctx, lines)
+	}
+	skipped, reqErr = h.catalogFailure(ctx, err)
+	return nil, skipped, nil, reqErr
+}
+
+// catalogFailure maps an error from validating items onto the response,
+// or reports skipped when lenient mode accepts the order anyway.
+func (h *handler) catalogFailure(ctx context.Context, err error) (skipped bool, reqErr *requestError) {
+	var invalid *invalidItemError
+	if errors.As(err, &invalid) {
+		return false, &requestError{status: http.StatusBadRequest, code: CodeInvalidItem, msg: invalid.Error()}
+	}
+	var unavailable *unavailableItemError
+	if errors.As(err, &unavailable) {
+		return false, &requestError{status: http.StatusConflict, code: CodeItemUnavailable, msg: unavailable.Error()}
+	}
+	var short *insufficientStockError
+	if errors.As(err, &short) {
+		return false, &requestError{status: http.StatusConflict, code: CodeInsufficientStock, msg: short.Error()}
+	}
+	var outside *outsideWindowError
+	if errors.As(err, &outside) {
+		return false, &requestError{status: http.StatusConflict, code: CodeItemOutsideWindow, msg: outside.Error()}
+	}
+	var blocked *blockedItemError
+	if errors.As(err, &blocked) && blocked.NotAllowed {
+		return false, &requestError{status: http.StatusConflict, code: CodeItemNotAllowed, msg: blocked.Error()}
+	}
+	if errors.As(err, &blocked) {
+		return false, &requestError{status: http.StatusConflict, code: CodeItemBlocked, msg: blocked.Error()}
+	}
+	if errors.Is(err, errCatalogBadResponse) {
+		requestLogger(ctx).Error("validating items", "error", err)
+		return false, &requestError{status: http.StatusBadGateway, code: CodeCatalogBadResponse, msg: "food catalog returned an invalid response"}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false, timeoutError(ctx)
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// The client hung up; nobody will read the response, but the order
+		// must not be accepted as if the catalog were down.
+		requestLogger(ctx).Info("client cancelled request during item validation")
+		return false, &requestError{status: statusClientClosedRequest, code: CodeRequestCancelled, msg: "request cancelled"}
+	}
+	if h.validationMode == validationLenient {
+		requestLogger(ctx).Warn("catalog unavailable, accepting order without validation", "error", err)
+		return true, nil
+	}
+	requestLogger(ctx).Error("validating items", "error", err)
+	status := http.StatusInternalServerError
+	if errors.Is(err, errCatalogUnavailable) {
+		status = http.StatusServiceUnavailable
+	}
+	return false, &requestError{status: status, code: CodeCatalogUnavailable, msg: "food catalog service not available"}
+}
+
+func (h *handler) listOrders(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	sortKey, err := parseSort(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	result := filterOrders(h.store.All(), filter)
+	sortOrders(result, sortKey)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(result)))
+	page := paginate(result, limit, offset)
+	if wantsEnvelope(r) {
+		writeJSON(w, http.StatusOK, listEnvelope{Data: page, Total: len(result), Limit: limit, Offset: offset})
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// activeOrder loads an order, treating soft-deleted orders as missing.
+func (h *handler) activeOrder(id string) (Order, error) {
+	order, err := h.store.Get(id)
+	if err == nil && order.Deleted {
+		err = ErrNotFound
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	return order, nil
+}
+
+// lookupOrder finds an order by id, or failing that by order number, so
+// staff can use the short number printed on tickets.
+func (h *handler) lookupOrder(ref string) (Order, error) {
+	o, err := h.store.Get(ref)
+	if !errors.Is(err, ErrNotFound) {
+		return o, err
+	}
+	if n, perr := strconv.ParseInt(ref, 10, 64); perr == nil && n > 0 {
+		return h.store.GetByNumber(n)
+	}
+	return Order{}, err
+}
+
+// getOrder answers GET /orders/{id}. With ?expand=items each item also
+// carries its catalog name and price; that response has no ETag, since the
+// catalog can change while the order does not.
+func (h *handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	expand, err := parseExpand(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	order, err := h.lookupOrder(chi.URLParam(r, "id"))
+	if err == nil && order.Deleted && !includeDeleted(r.URL.Query()) {
+		err = ErrNotFound
+	}
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+	order.Timings = statusTimings(order.StatusHistory)
+	if expand {
+		writeJSON(w, http.StatusOK, h.expandItems(r.Context(), order))
+		return
+	}
+	writeOrder(w, r, order)
+}
+
+// getStatus returns only the fulfillment state of an order, for cheap
+// polling by status widgets.
+func (h *handler) getStatus(w http.ResponseWriter, r *http.Request) {
+	order, err := h.activeOrder(chi.URLParam(r, "id"))
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": order.ID, "status": order.Status})
+}
+
+// deleteOrder soft-deletes an order: it is kept for audit and can be
+// restored, but disappears from the API unless include_deleted is set.
+func (h *handler) deleteOrder(w http.ResponseWriter, r *http.Request) {
+	now := h.now().UTC()
+	order, err := h.store.Update(chi.URLParam(r, "id"), func(o *Order) error {
+		if o.Deleted {
+			return ErrNotFound
+		}
+		if o.Status != StatusReceived {
+			return &requestError{status: http.StatusConflict, code: CodeOrderNotCancellable, msg: fmt.Sprintf("order cannot be cancelled once it is %s", o.Status)}
+		}
+		o.Deleted = true
+		o.DeletedAt = &now
+		o.UpdatedAt = now
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "delete order"))
+		return
+	}
+	h.recordEvent(r.Context(), EventOrderDeleted, order, "", "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreOrder undoes a soft delete.
+func (h *handler) restoreOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.store.Update(chi.URLParam(r, "id"), func(o *Order) error {
+		if !o.Deleted {
+			return &requestError{status: http.StatusConflict, code: CodeOrderNotDeleted, msg: "order is not deleted"}
+		}
+		o.Deleted = false
+		o.DeletedAt = nil
+		o.UpdatedAt = h.now().UTC()
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "restore order"))
+		return
+	}
+	h.recordEvent(r.Context(), EventOrderRestored, order, "", "")
+	writeOrder(w, r, order)
+}
+
+// updateItems replaces an order's items, re-validating them against the
+// catalog and recomputing the total. Items can only change while the order
+// is still "received".
+func (h *handler) updateItems(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items   []LineItem `json:"items"`
+		ItemIDs []string   `json:"item_ids"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Items) > 0 && len(req.ItemIDs) > 0 {
+		writeJSONError(w, http.StatusBadRequest, CodeValidationFailed, "use either items or item_ids, not both")
+		return
+	}
+	for _, id := range req.ItemIDs {
+		req.Items = append(req.Items, LineItem{ItemID: id, Quantity: 1})
+	}
+	if err := validateLineItems(req.Items, h.maxItemIDLen); err != nil {
+		writeRequestError(w, validationFailed(err))
+		return
+	}
+	req.Items = mergeLineItems(req.Items)
+
+	id := chi.URLParam(r, "id")
+	order, err := h.activeOrder(id)
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+	if reqErr := itemsLocked(order); reqErr != nil {
+		writeRequestError(w, reqErr)
+		return
+	}
+
+	items, skipped, checks, reqErr := h.checkItems(r.Context(), req.Items)
+	if reqErr != nil {
+		writeRequestError(w, reqErr)
+		return
+	}
+	// The catalog call can take a while, so the status is checked again
+	// when the new items are written.
+	order, err = h.store.Update(id, func(o *Order) error {
+		if o.Deleted {
+			return ErrNotFound
+		}
+		if reqErr := itemsLocked(*o); reqErr != nil {
+			return reqErr
+		}
+		o.Items = req.Items
+		o.TotalCents = totalCents(o.Items, items)
+		o.ValidationSkipped = skipped
+		o.ItemChecks = checks
+		o.UpdatedAt = h.now().UTC()
+		eta := o.CreatedAt.Add(h.prepTimes.estimate(o.Items, items))
+		o.EstimatedReadyAt = &eta
+		return nil
+	})
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "update order"))
+		return
+	}
+	h.recordEvent(r.Context(), EventItemsChanged, order, "", "")
+	writeOrder(w, r, order)
+}
+
+// itemsLocked reports the conflict when o's items can no longer change.
+func itemsLocked(o Order) *requestError {
+	if o.Status == StatusReceived {
+		return nil
+	}
+	return &requestError{status: http.StatusConflict, code: CodeOrderLocked, msg: fmt.Sprintf("items cannot be changed once an order is %s", o.Status)}
+}
+
+func (h *handler) updateStatus(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	order, reqErr := h.transition(r.Context(), chi.URLParam(r, "id"), req.Status)
+	if reqErr != nil && reqErr.code == CodeInvalidTransition {
+		writeJSON(w, reqErr.status, map[string]any{
+			"error":   reqErr.msg,
+			"status":  reqErr.status,
+			"code":    reqErr.code,
+			"allowed": allowedTransitions(order.Status),
+		})
+		return
+	}
+	if reqErr != nil {
+		writeRequestError(w, reqErr)
+		return
+	}
+	w.Header().Set("ETag", orderETag(order))
+	writeJSON(w, http.StatusOK, order)
+}
+
+// transition moves an order to status if the workflow allows it. On an
+// invalid transition the unchanged order is returned alongside the error so
+// callers can report which statuses were allowed. Starting preparation
+// restarts the ready estimate in the same store update.
+func (h *handler) transition(ctx context.Context, id, status string) (Order, *requestError) {
+	at := h.now().UTC()
+	var current Order
+	updated, err := h.store.Update(id, func(o *Order) error {
+		if o.Deleted {
+			return ErrNotFound
+		}
+		current = *o
+		if err := moveTo(status, at)(o); err != nil {
+			return err
+		}
+		if status == StatusPreparing {
+			*o = restartEstimate(*o, at)
+		}
+		return nil
+	})
+	if err != nil {
+		return current, storeError(ctx, err, "update order status")
+	}
+	h.recordEvent(ctx, EventStatusChanged, updated, current.Status, updated.Status)
+	h.webhooks.notify(ctx, EventStatusChanged, updated)
+	return updated, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// A status change that lands while PATCH /orders/{id} is waiting on the
+// catalog wins: the items are not written and the order is not put back to
+// received.
+func TestUpdateItemsRechecksStatusAfterCatalog(t *testing.T) {
+	catalog := &stubCatalog{}
+	h := newTestHandler(t, WithCatalog(catalog))
+	routes := h.routes()
+	catalog.validate = func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		items := map[string]catalogItem{}
+		for _, id := range ids {
+			items[id] = catalogItem{ID: id, Name: id, Price: 1, Available: true}
+		}
+		return items, nil
+	}
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	catalog.validate = func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		if _, err := h.store.UpdateStatus(order.ID, StatusPreparing, testTime.Add(time.Minute)); err != nil {
+			t.Errorf("UpdateStatus: %v", err)
+		}
+		return map[string]catalogItem{"2": {ID: "2", Name: "2", Price: 5, Available: true}}, nil
+	}
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID, `{"items":[{"item_id":"2","quantity":1}]}`)
+	wantError(t, rec, http.StatusConflict, CodeOrderLocked)
+
+	stored, err := h.store.Get(order.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if stored.Status != StatusPreparing {
+		t.Errorf("status = %q, want %q", stored.Status, StatusPreparing)
+	}
+	if stored.Items[0].ItemID != "1" {
+		t.Errorf("items = %+v, want the original item", stored.Items)
+	}
+}
+
+func TestDeleteOrderConflictsOnceStarted(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	if _, err := h.store.UpdateStatus(order.ID, StatusPreparing, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	rec := serve(t, routes, http.MethodDelete, "/orders/"+order.ID, "")
+	wantError(t, rec, http.StatusConflict, CodeOrderNotCancellable)
+	if stored, _ := h.store.Get(order.ID); stored.Deleted {
+		t.Error("order was deleted despite the conflict")
+	}
+}
+
+func TestRestoreOrderRequiresDeleted(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/restore", "")
+	wantError(t, rec, http.StatusConflict, CodeOrderNotDeleted)
+}
+
+func TestGetOrder(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	created := createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+created.ID, "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[Order](t, rec)
+	if got.ID != created.ID || got.CustomerID != "c1" || got.Status != StatusReceived {
+		t.Fatalf("GET returned %+v, want the created order", got)
+	}
+	if len(got.Items) != 1 || got.Items[0].ItemID != "1" {
+		t.Errorf("items = %+v", got.Items)
+	}
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders/missing", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestDeleteOrder(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodDelete, "/orders/"+order.ID, "")
+	wantStatus(t, rec, http.StatusNoContent)
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", rec.Body.String())
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+order.ID, "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+	rec = serve(t, routes, http.MethodDelete, "/orders/"+order.ID, "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestDeleteOrderNotFound(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodDelete, "/orders/missing", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestGetOrderStatus(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, simpleOrder)
+	if _, err := h.store.UpdateStatus(order.ID, StatusPreparing, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+order.ID+"/status", "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[map[string]string](t, rec)
+	if got["id"] != order.ID || got["status"] != StatusPreparing || len(got) != 2 {
+		t.Fatalf("body = %v, want only id and status %q", got, StatusPreparing)
+	}
+}
+
+func TestGetOrderStatusNotFound(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders/missing/status", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+
+	order := createTestOrder(t, routes, simpleOrder)
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/"+order.ID, ""), http.StatusNoContent)
+	rec = serve(t, routes, http.MethodGet, "/orders/"+order.ID+"/status", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestOrderTimestamps(t *testing.T) {
+	now := testTime
+	routes := newTestHandler(t, WithClock(func() time.Time { return now })).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+	if !order.CreatedAt.Equal(testTime) || !order.UpdatedAt.Equal(testTime) {
+		t.Fatalf("created_at = %s, updated_at = %s; want both %s", order.CreatedAt, order.UpdatedAt, testTime)
+	}
+
+	now = testTime.Add(5 * time.Minute)
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"preparing"}`)
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[Order](t, rec)
+	if !got.CreatedAt.Equal(testTime) {
+		t.Errorf("created_at moved to %s", got.CreatedAt)
+	}
+	if !got.UpdatedAt.Equal(now) {
+		t.Errorf("updated_at = %s, want %s", got.UpdatedAt, now)
+	}
+}
+
+func TestCreateOrderSetsLocation(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder)
+	wantStatus(t, rec, http.StatusCreated)
+	created := decodeBody[Order](t, rec)
+	loc := rec.Header().Get("Location")
+	if loc != "/orders/"+created.ID {
+		t.Fatalf("Location = %q, want /orders/%s", loc, created.ID)
+	}
+
+	rec = serve(t, routes, http.MethodGet, loc, "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); got.ID != created.ID {
+		t.Errorf("Location led to %+v", got)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	order := createTestOrder(t, routes, simpleOrder)
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/"+order.ID, ""), http.StatusNoContent)
+
+	stored, err := h.store.Get(order.ID)
+	if err != nil {
+		t.Fatalf("soft-deleted order is gone from the store: %v", err)
+	}
+	if !stored.Deleted || stored.DeletedAt == nil || !stored.DeletedAt.Equal(testTime) {
+		t.Errorf("stored = %+v, want deleted at %s", stored, testTime)
+	}
+
+	rec := serve(t, routes, http.MethodGet, "/orders/"+order.ID+"?include_deleted=true", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); !got.Deleted {
+		t.Errorf("include_deleted returned %+v, want it marked deleted", got)
+	}
+	if list := decodeBody[[]Order](t, serve(t, routes, http.MethodGet, "/orders", "")); len(list) != 0 {
+		t.Errorf("GET /orders lists the deleted order: %+v", list)
+	}
+	if list := decodeBody[[]Order](t, serve(t, routes, http.MethodGet, "/orders?include_deleted=true", "")); len(list) != 1 {
+		t.Errorf("GET /orders?include_deleted=true returned %d orders, want 1", len(list))
+	}
+	rec = serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"preparing"}`)
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+
+	rec = serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/restore", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); got.Deleted || got.DeletedAt != nil {
+		t.Errorf("restored order = %+v", got)
+	}
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders/"+order.ID, ""), http.StatusOK)
+}
+
+func TestRestoreOrderNotFound(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodPost, "/orders/missing/restore", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestCreateOrderDryRun(t *testing.T) {
+	pub := &recordingPublisher{}
+	h := newTestHandler(t, WithPublisher(pub))
+	routes := h.routes()
+
+	rec := serve(t, routes, http.MethodPost, "/orders?dry_run=true", `{"customer_id":"c1","items":[{"item_id":"1","quantity":2},{"item_id":"2","quantity":1}]}`)
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[Order](t, rec)
+	if got.TotalCents != 1000 || got.Status != StatusReceived {
+		t.Errorf("dry run = %+v, want a received order totalling 1000", got)
+	}
+	if rec.Header().Get("Location") != "" {
+		t.Errorf("dry run sent Location %q", rec.Header().Get("Location"))
+	}
+	if orders := h.store.All(); len(orders) != 0 {
+		t.Errorf("dry run stored %+v", orders)
+	}
+	if len(pub.subjects) != 0 {
+		t.Errorf("dry run published %v", pub.subjects)
+	}
+
+	rec = serve(t, routes, http.MethodPost, "/orders?dry_run=true", `{"customer_id":"c1","items":[{"item_id":"99","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidItem)
+}
+
+func TestCreateOrderWithClientID(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	body := `{"id":"till-7-0042","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`
+	order := createTestOrder(t, routes, body)
+	if order.ID != "till-7-0042" {
+		t.Fatalf("id = %q, want the client's", order.ID)
+	}
+
+	rec := serve(t, routes, http.MethodPost, "/orders", body)
+	wantError(t, rec, http.StatusConflict, CodeOrderExists)
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"id":"bad id","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestGetOrderByNumber(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	createTestOrder(t, routes, simpleOrder)
+	second := createTestOrder(t, routes, simpleOrder)
+	if second.OrderNumber != 2 {
+		t.Fatalf("second order number = %d, want 2", second.OrderNumber)
+	}
+
+	rec := serve(t, routes, http.MethodGet, "/orders/2", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); got.ID != second.ID {
+		t.Errorf("GET /orders/2 = %s, want %s", got.ID, second.ID)
+	}
+	rec = serve(t, routes, http.MethodGet, "/orders/99", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSecurityHeadersOnOrderRoutes(t *testing.T) {
+	routes := newTestHandler(t, WithCacheControl("private, max-age=5")).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+	for name, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Cache-Control":          "private, max-age=5",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/health", "")
+	if got := rec.Header().Get("X-Frame-Options"); got != "" {
+		t.Errorf("/health got X-Frame-Options %q, want none", got)
+	}
+}
+
+func TestResolveCacheControl(t *testing.T) {
+	t.Setenv("CACHE_CONTROL", "")
+	if got := resolveCacheControl(); got != defaultCacheControl {
+		t.Errorf("default = %q, want %q", got, defaultCacheControl)
+	}
+	t.Setenv("CACHE_CONTROL", "no-store")
+	if got := resolveCacheControl(); got != "no-store" {
+		t.Errorf("CACHE_CONTROL=no-store = %q", got)
+	}
+}
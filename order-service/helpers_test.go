@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTime is the clock reading used by handlers built with newTestHandler.
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestHandler returns a handler on a fresh memory store, with a mock
+// catalog stocking items "1" (2.50), "2" (5.00) and "3" (free) and the clock
+// pinned to testTime. opts are applied after those defaults.
+func newTestHandler(t *testing.T, opts ...HandlerOption) *handler {
+	t.Helper()
+	catalog, err := newMockCatalog("1=2.50,2=5,3")
+	if err != nil {
+		t.Fatalf("newMockCatalog: %v", err)
+	}
+	defaults := []HandlerOption{
+		WithCatalog(catalog),
+		WithClock(func() time.Time { return testTime }),
+	}
+	return newHandler(NewMemoryStore(), append(defaults, opts...)...)
+}
+
+// newTestSQLiteStore opens a SQLite store in a temporary directory that is
+// removed, along with the store, when the test ends.
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// serve sends a request through h's router. A non-empty body is sent as
+// JSON; headers are name, value pairs.
+func serve(t *testing.T, h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeBody unmarshals a JSON response body into a T.
+func decodeBody[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// wantStatus fails the test unless rec has the given status.
+func wantStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, status, rec.Body.String())
+	}
+}
+
+// wantError fails the test unless rec is an error envelope with the given
+// status and code.
+func wantError(t *testing.T, rec *httptest.ResponseRecorder, status int, code ErrorCode) {
+	t.Helper()
+	wantStatus(t, rec, status)
+	body := decodeBody[errorResponse](t, rec)
+	if body.Code != code || body.Status != status {
+		t.Fatalf("error = %+v, want status %d and code %s", body, status, code)
+	}
+}
+
+// simpleOrder is a valid order body for one of item "1".
+const simpleOrder = `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`
+
+// createTestOrder places an order through the API and returns it.
+func createTestOrder(t *testing.T, h http.Handler, body string) Order {
+	t.Helper()
+	rec := serve(t, h, http.MethodPost, "/orders", body)
+	wantStatus(t, rec, http.StatusCreated)
+	return decodeBody[Order](t, rec)
+}
+
+// captureLogs sends the default slog output, debug lines included, to a
+// buffer in text form until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// stubCatalog is a CatalogClient whose answers are set by each test.
+type stubCatalog struct {
+	mu       sync.Mutex
+	validate func(ctx context.Context, ids []string) (map[string]catalogItem, error)
+	calls    int
+	// pingErr is what Ping returns.
+	pingErr error
+}
+
+func (c *stubCatalog) ValidateItems(ctx context.Context, ids []string) (map[string]catalogItem, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return c.validate(ctx, ids)
+}
+
+func (c *stubCatalog) Ping(context.Context) error { return c.pingErr }
+
+// callCount reports how many times ValidateItems has been called.
+func (c *stubCatalog) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyKeys remembers which order an Idempotency-Key created so that
+// client retries return the original order instead of a duplicate. Callers
+// scope keys to the client sending them, so one client cannot replay
+// another's.
+type idempotencyKeys struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]idempotencyEntry
+	// queue lists keys in the order they were remembered. Every key lives
+	// for the same ttl, so that is also the order they expire in and
+	// expiring only ever looks at the front.
+	queue []queuedKey
+}
+
+// idempotencyEntry is what a key maps to. orderID is empty while the
+// request holding the key is still creating its order.
+type idempotencyEntry struct {
+	orderID string
+	expires time.Time
+}
+
+type queuedKey struct {
+	key     string
+	expires time.Time
+}
+
+// errIdempotencyKeyInUse is returned by Reserve while another request
+// holding the same key has not finished.
+var errIdempotencyKeyInUse = errors.New("a request with this Idempotency-Key is still in progress")
+
+func newIdempotencyKeys(ttl time.Duration) *idempotencyKeys {
+	return &idempotencyKeys{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]idempotencyEntry),
+	}
+}
+
+// Reserve claims key for a request about to create an order. It returns the
+// id of the order key already created, if that has not expired, or
+// errIdempotencyKeyInUse while another request holds key. Otherwise key is
+// now held by the caller, who must Remember or Release it.
+func (k *idempotencyKeys) Reserve(key string) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	k.expire(now)
+	e, ok := k.entries[key]
+	switch {
+	case ok && e.orderID == "":
+		return "", errIdempotencyKeyInUse
+	case ok && now.Before(e.expires):
+		return e.orderID, nil
+	}
+	k.entries[key] = idempotencyEntry{expires: now.Add(k.ttl)}
+	return "", nil
+}
+
+// Remember records that key, held since Reserve, created orderID.
+func (k *idempotencyKeys) Remember(key, orderID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	expires := k.now().Add(k.ttl)
+	k.entries[key] = idempotencyEntry{orderID: orderID, expires: expires}
+	k.queue = append(k.queue, queuedKey{key: key, expires: expires})
+}
+
+// Release gives up a key held since Reserve without creating an order, so
+// a retry can use it. It does nothing once the key has been remembered.
+func (k *idempotencyKeys) Release(key string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if e, ok := k.entries[key]; ok && e.orderID == "" {
+		delete(k.entries, key)
+	}
+}
+
+// Forget drops key if it still maps to orderID, e.g. because that order no
+// longer exists.
+func (k *idempotencyKeys) Forget(key, orderID string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if e, ok := k.entries[key]; ok && e.orderID == orderID {
+		delete(k.entries, key)
+	}
+}
+
+// expire drops the keys that expired by now. The caller holds k.mu.
+func (k *idempotencyKeys) expire(now time.Time) {
+	n := 0
+	for ; n < len(k.queue) && !now.Before(k.queue[n].expires); n++ {
+		q := k.queue[n]
+		// The key may have been forgotten and remembered again since.
+		if e, ok := k.entries[q.key]; ok && e.expires.Equal(q.expires) {
+			delete(k.entries, q.key)
+		}
+	}
+	k.queue = k.queue[n:]
+}
+
+// resolveIdempotencyTTL reads IDEMPOTENCY_TTL (a Go duration), defaulting to 24h.
+func resolveIdempotencyTTL() (time.Duration, error) {
+	v := setting("IDEMPOTENCY_TTL")
+	if v == "" {
+		return defaultIdempotencyTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid IDEMPOTENCY_TTL %q: must be a positive duration", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestIdempotencyKeys(now *time.Time) *idempotencyKeys {
+	k := newIdempotencyKeys(time.Hour)
+	k.now = func() time.Time { return *now }
+	return k
+}
+
+func TestIdempotencyReserveAndRemember(t *testing.T) {
+	now := testTime
+	k := newTestIdempotencyKeys(&now)
+
+	if id, err := k.Reserve("a"); id != "" || err != nil {
+		t.Fatalf("first Reserve = %q, %v; want the key reserved", id, err)
+	}
+	if _, err := k.Reserve("a"); !errors.Is(err, errIdempotencyKeyInUse) {
+		t.Fatalf("Reserve while held = %v, want errIdempotencyKeyInUse", err)
+	}
+	k.Remember("a", "order-1")
+	if id, err := k.Reserve("a"); id != "order-1" || err != nil {
+		t.Errorf("Reserve after Remember = %q, %v; want order-1", id, err)
+	}
+	k.Release("a")
+	if id, _ := k.Reserve("a"); id != "order-1" {
+		t.Errorf("Release dropped a remembered key")
+	}
+}
+
+func TestIdempotencyRelease(t *testing.T) {
+	now := testTime
+	k := newTestIdempotencyKeys(&now)
+
+	k.Reserve("a")
+	k.Release("a")
+	if id, err := k.Reserve("a"); id != "" || err != nil {
+		t.Errorf("Reserve after Release = %q, %v; want the key reserved again", id, err)
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	now := testTime
+	k := newTestIdempotencyKeys(&now)
+
+	k.Reserve("a")
+	k.Remember("a", "order-1")
+	now = now.Add(30 * time.Minute)
+	k.Reserve("b")
+	k.Remember("b", "order-2")
+
+	now = testTime.Add(time.Hour)
+	if id, err := k.Reserve("a"); id != "" || err != nil {
+		t.Errorf("Reserve of an expired key = %q, %v; want it reserved afresh", id, err)
+	}
+	if id, _ := k.Reserve("b"); id != "order-2" {
+		t.Errorf("Reserve(b) = %q, want order-2 until it expires", id)
+	}
+	if len(k.queue) != 1 {
+		t.Errorf("%d keys queued for expiry, want 1", len(k.queue))
+	}
+}
+
+// Forgetting and remembering a key again must not let the first entry's
+// expiry remove the second.
+func TestIdempotencyExpireSkipsReplacedKeys(t *testing.T) {
+	now := testTime
+	k := newTestIdempotencyKeys(&now)
+
+	k.Reserve("a")
+	k.Remember("a", "order-1")
+	k.Forget("a", "order-1")
+	now = now.Add(time.Minute)
+	k.Reserve("a")
+	k.Remember("a", "order-2")
+
+	now = testTime.Add(time.Hour)
+	if id, _ := k.Reserve("a"); id != "order-2" {
+		t.Errorf("Reserve = %q, want order-2", id)
+	}
+}
+
+func TestResolveIdempotencyTTL(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_TTL", "")
+	if d, err := resolveIdempotencyTTL(); d != defaultIdempotencyTTL || err != nil {
+		t.Errorf("default = %v, %v; want %v", d, err, defaultIdempotencyTTL)
+	}
+	t.Setenv("IDEMPOTENCY_TTL", "90m")
+	if d, err := resolveIdempotencyTTL(); d != 90*time.Minute || err != nil {
+		t.Errorf("90m = %v, %v", d, err)
+	}
+	for _, v := range []string{"soon", "0s", "-1h"} {
+		t.Setenv("IDEMPOTENCY_TTL", v)
+		if _, err := resolveIdempotencyTTL(); err == nil {
+			t.Errorf("IDEMPOTENCY_TTL=%q accepted", v)
+		}
+	}
+}
+
+const idempotentOrder = `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`
+
+func TestCreateOrderReplaysIdempotencyKey(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+
+	first := serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1")
+	wantStatus(t, first, http.StatusCreated)
+	again := serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1")
+	wantStatus(t, again, http.StatusOK)
+
+	if a, b := decodeBody[Order](t, first), decodeBody[Order](t, again); a.ID != b.ID {
+		t.Errorf("replay returned order %s, want %s", b.ID, a.ID)
+	}
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want 1", n)
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTheClient(t *testing.T) {
+	h := newTestHandler(t, WithAPIKeys([]string{"alice", "bob"}))
+	routes := h.routes()
+
+	alice := serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1", "Authorization", "Bearer alice")
+	wantStatus(t, alice, http.StatusCreated)
+	bob := serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1", "Authorization", "Bearer bob")
+	wantStatus(t, bob, http.StatusCreated)
+
+	if a, b := decodeBody[Order](t, alice), decodeBody[Order](t, bob); a.ID == b.ID {
+		t.Error("a second client replayed the first client's order")
+	}
+}
+
+// A duplicate arriving while the first request is still creating its order
+// is turned away instead of creating a second order.
+func TestConcurrentIdempotentRequests(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	catalog := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		close(entered)
+		<-release
+		return map[string]catalogItem{"1": {ID: "1", Price: 1, Available: true}}, nil
+	}}
+	h := newTestHandler(t, WithCatalog(catalog))
+	routes := h.routes()
+
+	done := make(chan int)
+	go func() {
+		done <- serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1").Code
+	}()
+	<-entered
+	rec := serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1")
+	wantError(t, rec, http.StatusConflict, CodeIdempotencyKeyInUse)
+	close(release)
+
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("first request status = %d, want 201", code)
+	}
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want 1", n)
+	}
+}
+
+// A request that fails gives its key back, so the client can retry.
+func TestFailedRequestReleasesIdempotencyKey(t *testing.T) {
+	routes := newTestHandler(t).routes()
+
+	bad := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"9","quantity":1}]}`, "Idempotency-Key", "k1")
+	wantError(t, bad, http.StatusBadRequest, CodeInvalidItem)
+	wantStatus(t, serve(t, routes, http.MethodPost, "/orders", idempotentOrder, "Idempotency-Key", "k1"), http.StatusCreated)
+}
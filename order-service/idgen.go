@@ -0,0 +1,15 @@
+package main
+
+import "github.com/hashicorp/go-uuid"
+
+// IDGenerator produces ids for new orders.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// uuidGenerator is the production IDGenerator, issuing random UUIDs.
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() (string, error) {
+	return uuid.GenerateUUID()
+}
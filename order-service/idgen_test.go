@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// sequenceIDs is an IDGenerator handing out order-1, order-2 and so on.
+type sequenceIDs struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (s *sequenceIDs) NewID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.n++
+	return fmt.Sprintf("order-%d", s.n), nil
+}
+
+// failingIDs is an IDGenerator that always fails.
+type failingIDs struct{}
+
+func (failingIDs) NewID() (string, error) { return "", errors.New("entropy exhausted") }
+
+func TestCreateOrderUsesIDGenerator(t *testing.T) {
+	routes := newTestHandler(t, WithIDGenerator(&sequenceIDs{})).routes()
+	for _, want := range []string{"order-1", "order-2"} {
+		rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder)
+		wantStatus(t, rec, http.StatusCreated)
+		if got := decodeBody[Order](t, rec).ID; got != want {
+			t.Errorf("id = %q, want %q", got, want)
+		}
+		if loc := rec.Header().Get("Location"); loc != "/orders/"+want {
+			t.Errorf("Location = %q, want /orders/%s", loc, want)
+		}
+	}
+
+	// A client-supplied id does not consume one from the generator.
+	createTestOrder(t, routes, `{"id":"mine","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	if o := createTestOrder(t, routes, simpleOrder); o.ID != "order-3" {
+		t.Errorf("id after a client-supplied one = %q, want order-3", o.ID)
+	}
+}
+
+func TestCreateOrderIDGeneratorFails(t *testing.T) {
+	h := newTestHandler(t, WithIDGenerator(failingIDs{}))
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusInternalServerError, CodeInternal)
+	if orders := h.store.All(); len(orders) != 0 {
+		t.Errorf("store holds %d orders, want none", len(orders))
+	}
+}
+
+func TestUUIDGenerator(t *testing.T) {
+	a, err := uuidGenerator{}.NewID()
+	if err != nil {
+		t.Fatalf("NewID: %v", err)
+	}
+	b, _ := uuidGenerator{}.NewID()
+	if len(a) != 36 || a == b {
+		t.Errorf("NewID = %q, %q; want two distinct UUIDs", a, b)
+	}
+}
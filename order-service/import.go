@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// maxImportFailures caps how many failures an import reports line by line;
+// the rest are only counted.
+const maxImportFailures = 100
+
+// importFailure reports one line of an import that did not become an order.
+type importFailure struct {
+	Line    int          `json:"line"`
+	Status  int          `json:"status"`
+	Code    ErrorCode    `json:"code"`
+	Error   string       `json:"error"`
+	Details []fieldError `json:"details,omitempty"`
+}
+
+type importSummary struct {
+	Created  int             `json:"created"`
+	Failed   int             `json:"failed"`
+	Failures []importFailure `json:"failures"`
+}
+
+func (s *importSummary) fail(line int, reqErr *requestError) {
+	s.Failed++
+	if len(s.Failures) < maxImportFailures {
+		s.Failures = append(s.Failures, importFailure{Line: line, Status: reqErr.status, Code: reqErr.code, Error: reqErr.msg, Details: reqErr.details})
+	}
+}
+
+// importOrders answers POST /orders/import, placing one order per line of
+// an application/x-ndjson body. Lines are read and placed one at a time, so
+// the body is never buffered whole and catalog lookups stay within the
+// usual per-order concurrency; the body as a whole is still bounded by
+// MAX_BODY_BYTES. Blank lines are skipped. The summary counts created and
+// failed orders and gives the line number of each failure.
+func (h *handler) importOrders(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-ndjson" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Content-Type must be application/x-ndjson")
+		return
+	}
+
+	summary := importSummary{Failures: []importFailure{}}
+	body := bufio.NewReader(r.Body)
+	for line := 1; ; line++ {
+		data, err := body.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			// Whatever was read of this line is incomplete, so drop it.
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				summary.fail(line, &requestError{status: http.StatusRequestEntityTooLarge, code: CodeBodyTooLarge, msg: "request body too large; no further lines were read"})
+			} else {
+				summary.fail(line, &requestError{status: http.StatusBadRequest, code: CodeInvalidRequest, msg: "reading request body failed"})
+			}
+			break
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if !h.importLine(r.Context(), &summary, line, data) {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// importLine places the order on one line, reporting false once the
+// request has been cancelled or timed out and the import should stop.
+func (h *handler) importLine(ctx context.Context, summary *importSummary, line int, data []byte) bool {
+	if jsonDepth(data, h.maxJSONDepth) > h.maxJSONDepth {
+		summary.fail(line, &requestError{status: http.StatusBadRequest, code: CodeInvalidRequest, msg: fmt.Sprintf("line nests deeper than %d levels", h.maxJSONDepth)})
+		return true
+	}
+	var o Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		summary.fail(line, &requestError{status: http.StatusBadRequest, code: CodeInvalidRequest, msg: err.Error()})
+		return true
+	}
+	if _, reqErr := h.placeOrder(ctx, o); reqErr != nil {
+		summary.fail(line, reqErr)
+		return ctx.Err() == nil
+	}
+	summary.Created++
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// ndjson joins lines into an application/x-ndjson body.
+func ndjson(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestImportOrders(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+
+	body := ndjson(
+		simpleOrder,
+		"",
+		`{"customer_id":"c1","items":[{"item_id":"missing","quantity":1}]}`,
+		`{"customer_id":`,
+		orderOf("1", "2"),
+	)
+	rec := serve(t, routes, http.MethodPost, "/orders/import", body, "Content-Type", "application/x-ndjson")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[importSummary](t, rec)
+	if got.Created != 2 || got.Failed != 2 || len(got.Failures) != 2 {
+		t.Fatalf("summary = %+v, want 2 created and 2 failed", got)
+	}
+	if f := got.Failures[0]; f.Line != 3 || f.Status != http.StatusBadRequest || f.Code != CodeInvalidItem {
+		t.Errorf("first failure = %+v, want line 3 INVALID_ITEM", f)
+	}
+	if f := got.Failures[1]; f.Line != 4 || f.Code != CodeInvalidRequest {
+		t.Errorf("second failure = %+v, want line 4 INVALID_REQUEST", f)
+	}
+	if n := len(h.store.All()); n != 2 {
+		t.Errorf("store holds %d orders, want 2", n)
+	}
+}
+
+func TestImportOrdersRequiresNDJSON(t *testing.T) {
+	h := newTestHandler(t)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders/import", ndjson(simpleOrder))
+	wantError(t, rec, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType)
+	if len(h.store.All()) != 0 {
+		t.Error("import with the wrong Content-Type stored an order")
+	}
+}
+
+func TestImportOrdersRejectsDeepLine(t *testing.T) {
+	h := newTestHandler(t, WithMaxJSONDepth(4))
+	body := ndjson(`{"customer_id":"c1","items":[{"item_id":"1","quantity":1,"x":[[[]]]}]}`, simpleOrder)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders/import", body, "Content-Type", "application/x-ndjson")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[importSummary](t, rec)
+	if got.Created != 1 || got.Failed != 1 || got.Failures[0].Line != 1 || got.Failures[0].Code != CodeInvalidRequest {
+		t.Errorf("summary = %+v, want line 1 rejected and line 2 created", got)
+	}
+}
+
+func TestImportOrdersBodyTooLarge(t *testing.T) {
+	h := newTestHandler(t, WithMaxBodyBytes(int64(len(simpleOrder)+10)))
+	body := ndjson(simpleOrder, simpleOrder, simpleOrder)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders/import", body, "Content-Type", "application/x-ndjson")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[importSummary](t, rec)
+	if got.Created != 1 || got.Failed != 1 {
+		t.Fatalf("summary = %+v, want 1 created before the limit", got)
+	}
+	if f := got.Failures[0]; f.Line != 2 || f.Status != http.StatusRequestEntityTooLarge || f.Code != CodeBodyTooLarge {
+		t.Errorf("failure = %+v, want line 2 BODY_TOO_LARGE", f)
+	}
+}
+
+func TestImportSummaryCapsFailures(t *testing.T) {
+	var s importSummary
+	for line := range maxImportFailures + 5 {
+		s.fail(line+1, &requestError{status: http.StatusBadRequest, code: CodeInvalidRequest, msg: "bad"})
+	}
+	if s.Failed != maxImportFailures+5 || len(s.Failures) != maxImportFailures {
+		t.Errorf("Failed = %d with %d reported, want %d with %d", s.Failed, len(s.Failures), maxImportFailures+5, maxImportFailures)
+	}
+}
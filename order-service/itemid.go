@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const defaultMaxItemIDLength = 64
+
+// resolveMaxItemIDLength reads MAX_ITEM_ID_LENGTH, the longest item id an
+// order may reference, defaulting to 64.
+func resolveMaxItemIDLength() (int, error) {
+	v := setting("MAX_ITEM_ID_LENGTH")
+	if v == "" {
+		return defaultMaxItemIDLength, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_ITEM_ID_LENGTH %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// checkItemID describes what is wrong with an item id, or returns "" if it
+// is 1-max letters, digits, '-' or '_'. An over-long id is cut short in the
+// message so it cannot bloat the response or the logs in turn.
+func checkItemID(id string, max int) string {
+	switch {
+	case id == "":
+		return "item_id is required"
+	case len(id) > max:
+		return fmt.Sprintf("item id %q... is longer than %d characters", id[:max], max)
+	case !validOrderID(id):
+		return fmt.Sprintf("item id %q must contain only letters, digits, '-' or '_'", id)
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckItemID(t *testing.T) {
+	for id, want := range map[string]string{
+		"1":                    "",
+		"bun-2_a":              "",
+		strings.Repeat("a", 8): "",
+		"":                     "required",
+		strings.Repeat("a", 9): "longer than 8 characters",
+		"fries;":               "only letters, digits",
+		"café":                 "only letters, digits",
+	} {
+		got := checkItemID(id, 8)
+		if (want == "") != (got == "") || !strings.Contains(got, want) {
+			t.Errorf("checkItemID(%q) = %q, want %q", id, got, want)
+		}
+	}
+	// The message quotes only the first max characters of a long id.
+	if got := checkItemID(strings.Repeat("x", 1000), 8); strings.Count(got, "x") != 8 {
+		t.Errorf("message for a long id = %q, want it cut to 8 characters", got)
+	}
+}
+
+func TestCreateOrderRejectsBadItemIDs(t *testing.T) {
+	h := newTestHandler(t, WithMaxItemIDLength(8))
+	routes := h.routes()
+	for name, id := range map[string]string{
+		"empty":     "",
+		"too long":  "123456789",
+		"malformed": "1 OR 1=1",
+	} {
+		rec := serve(t, routes, http.MethodPost, "/orders", orderOf(id))
+		wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+		details := decodeBody[errorResponse](t, rec).Details
+		if len(details) != 1 || details[0].Field != "items[0].item_id" {
+			t.Errorf("%s: details = %+v, want items[0].item_id", name, details)
+			continue
+		}
+		if id != "" && !strings.Contains(details[0].Message, id[:min(len(id), 8)]) {
+			t.Errorf("%s: message %q does not name the id", name, details[0].Message)
+		}
+	}
+	if n := len(h.store.All()); n != 0 {
+		t.Errorf("store holds %d orders, want none", n)
+	}
+	createTestOrder(t, routes, simpleOrder)
+}
+
+func TestUpdateItemsRejectsBadItemIDs(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	o := createTestOrder(t, routes, simpleOrder)
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+o.ID, `{"items":[{"item_id":"`+strings.Repeat("1", defaultMaxItemIDLength+1)+`","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestResolveMaxItemIDLength(t *testing.T) {
+	t.Setenv("MAX_ITEM_ID_LENGTH", "")
+	if n, err := resolveMaxItemIDLength(); n != defaultMaxItemIDLength || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxItemIDLength)
+	}
+	t.Setenv("MAX_ITEM_ID_LENGTH", "16")
+	if n, err := resolveMaxItemIDLength(); n != 16 || err != nil {
+		t.Errorf("16 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "long"} {
+		t.Setenv("MAX_ITEM_ID_LENGTH", v)
+		if _, err := resolveMaxItemIDLength(); err == nil {
+			t.Errorf("MAX_ITEM_ID_LENGTH=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// itemPolicy blocks or allows item IDs regardless of what the catalog says,
+// so operations can pull an item, e.g. a recalled product, faster than the
+// catalog can be updated. A blocked item is always rejected; when allowed is
+// non-empty, so is every item not on it.
+type itemPolicy struct {
+	blocked map[string]bool
+	allowed map[string]bool
+}
+
+func newItemPolicy(blocked, allowed []string) *itemPolicy {
+	p := &itemPolicy{blocked: make(map[string]bool, len(blocked)), allowed: make(map[string]bool, len(allowed))}
+	for _, id := range blocked {
+		p.blocked[id] = true
+	}
+	for _, id := range allowed {
+		p.allowed[id] = true
+	}
+	return p
+}
+
+// blockedItemError reports an item the item policy turns away.
+type blockedItemError struct {
+	ID string
+	// NotAllowed is set when the item is rejected for missing from the
+	// allowlist rather than for being blocked.
+	NotAllowed bool
+}
+
+func (e *blockedItemError) Error() string {
+	if e.NotAllowed {
+		return fmt.Sprintf("item %s is not on the list of items that can be ordered", e.ID)
+	}
+	return fmt.Sprintf("item %s is blocked", e.ID)
+}
+
+// check returns a *blockedItemError for the first item of lines the policy
+// rejects. A nil policy allows everything.
+func (p *itemPolicy) check(lines []LineItem) error {
+	if p == nil {
+		return nil
+	}
+	for _, id := range (Order{Items: lines}).ItemIDs() {
+		switch {
+		case p.blocked[id]:
+			return &blockedItemError{ID: id}
+		case len(p.allowed) > 0 && !p.allowed[id]:
+			return &blockedItemError{ID: id, NotAllowed: true}
+		}
+	}
+	return nil
+}
+
+// itemPolicyState is the item policy as GET and PUT /admin/item-policy see
+// it, with the IDs sorted.
+type itemPolicyState struct {
+	Blocked []string `json:"blocked"`
+	Allowed []string `json:"allowed"`
+}
+
+func (p *itemPolicy) state() itemPolicyState {
+	s := itemPolicyState{Blocked: []string{}, Allowed: []string{}}
+	if p == nil {
+		return s
+	}
+	for id := range p.blocked {
+		s.Blocked = append(s.Blocked, id)
+	}
+	for id := range p.allowed {
+		s.Allowed = append(s.Allowed, id)
+	}
+	slices.Sort(s.Blocked)
+	slices.Sort(s.Allowed)
+	return s
+}
+
+func (h *handler) getItemPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.itemPolicy.Load().state())
+}
+
+// setItemPolicy replaces the item policy with {"blocked": [...], "allowed":
+// [...]}, taking effect from the next order without a restart. Either list
+// may be left out or empty; an empty allowlist allows every item.
+func (h *handler) setItemPolicy(w http.ResponseWriter, r *http.Request) {
+	var req itemPolicyState
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	for _, id := range slices.Concat(req.Blocked, req.Allowed) {
+		if id == "" {
+			writeJSONError(w, http.StatusBadRequest, CodeValidationFailed, "item IDs must not be empty")
+			return
+		}
+	}
+	p := newItemPolicy(req.Blocked, req.Allowed)
+	h.itemPolicy.Store(p)
+	requestLogger(r.Context()).Warn("item policy replaced", "blocked", len(p.blocked), "allowed", len(p.allowed))
+	writeJSON(w, http.StatusOK, p.state())
+}
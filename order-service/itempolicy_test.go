@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestItemPolicyCheck(t *testing.T) {
+	lines := func(ids ...string) []LineItem {
+		var out []LineItem
+		for _, id := range ids {
+			out = append(out, LineItem{ItemID: id, Quantity: 1})
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name       string
+		policy     *itemPolicy
+		lines      []LineItem
+		wantID     string
+		notAllowed bool
+	}{
+		{"nil policy", nil, lines("1"), "", false},
+		{"empty policy", newItemPolicy(nil, nil), lines("1", "2"), "", false},
+		{"blocked", newItemPolicy([]string{"2"}, nil), lines("1", "2"), "2", false},
+		{"allowed", newItemPolicy(nil, []string{"1", "2"}), lines("1", "2"), "", false},
+		{"not allowed", newItemPolicy(nil, []string{"1"}), lines("1", "3"), "3", true},
+		{"blocked wins over allowed", newItemPolicy([]string{"1"}, []string{"1"}), lines("1"), "1", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.check(tc.lines)
+			if tc.wantID == "" {
+				if err != nil {
+					t.Fatalf("check = %v, want nil", err)
+				}
+				return
+			}
+			var blocked *blockedItemError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("check = %v, want a *blockedItemError", err)
+			}
+			if blocked.ID != tc.wantID || blocked.NotAllowed != tc.notAllowed {
+				t.Errorf("check = %+v, want ID %s, NotAllowed %t", blocked, tc.wantID, tc.notAllowed)
+			}
+		})
+	}
+}
+
+func TestItemPolicyRejectsOrders(t *testing.T) {
+	// The policy applies before the catalog, even with validation off.
+	routes := newTestHandler(t, WithItemPolicy([]string{"2"}, nil), WithValidationMode(validationOff)).routes()
+
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"2","quantity":1}]}`)
+	wantError(t, rec, http.StatusConflict, CodeItemBlocked)
+
+	routes = newTestHandler(t, WithItemPolicy(nil, []string{"1"})).routes()
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"2","quantity":1}]}`)
+	wantError(t, rec, http.StatusConflict, CodeItemNotAllowed)
+	createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+}
+
+func TestItemPolicyRoutesNeedAdmin(t *testing.T) {
+	routes := newTestHandler(t).routes()
+
+	wantStatus(t, serve(t, routes, http.MethodPut, "/admin/item-policy", `{"blocked":["1"]}`), http.StatusNotFound)
+	createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+}
+
+func TestItemPolicyRequiresAPIKey(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true), WithAPIKeys([]string{"secret"})).routes()
+
+	wantError(t, serve(t, routes, http.MethodPut, "/admin/item-policy", `{"blocked":["1"]}`), http.StatusUnauthorized, CodeUnauthorized)
+	wantError(t, serve(t, routes, http.MethodGet, "/admin/item-policy", ""), http.StatusUnauthorized, CodeUnauthorized)
+}
+
+func TestReplaceItemPolicy(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true), WithItemPolicy([]string{"3"}, nil)).routes()
+
+	rec := serve(t, routes, http.MethodGet, "/admin/item-policy", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got, want := decodeBody[itemPolicyState](t, rec), (itemPolicyState{Blocked: []string{"3"}, Allowed: []string{}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("policy = %+v, want %+v", got, want)
+	}
+
+	rec = serve(t, routes, http.MethodPut, "/admin/item-policy", `{"blocked":["2","1"]}`)
+	wantStatus(t, rec, http.StatusOK)
+	if got, want := decodeBody[itemPolicyState](t, rec), (itemPolicyState{Blocked: []string{"1", "2"}, Allowed: []string{}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("policy = %+v, want %+v", got, want)
+	}
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	wantError(t, rec, http.StatusConflict, CodeItemBlocked)
+	createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"3","quantity":1}]}`)
+}
+
+func TestReplaceItemPolicyRejectsEmptyIDs(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true)).routes()
+
+	wantError(t, serve(t, routes, http.MethodPut, "/admin/item-policy", `{"allowed":[""]}`), http.StatusBadRequest, CodeValidationFailed)
+}
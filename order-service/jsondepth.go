@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// defaultMaxJSONDepth leaves plenty of room: the deepest legitimate body, a
+// batch of orders, nests four levels.
+const defaultMaxJSONDepth = 20
+
+// resolveMaxJSONDepth reads MAX_JSON_DEPTH, how deeply request bodies may
+// nest arrays and objects, defaulting to 20.
+func resolveMaxJSONDepth() (int, error) {
+	v := setting("MAX_JSON_DEPTH")
+	if v == "" {
+		return defaultMaxJSONDepth, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_JSON_DEPTH %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// jsonDepth returns the deepest nesting of arrays and objects in data,
+// stopping early once it passes limit. It only tracks brackets and strings,
+// leaving everything else to the real decoder.
+func jsonDepth(data []byte, limit int) int {
+	depth, deepest := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+			if depth > deepest {
+				deepest = depth
+				if deepest > limit {
+					return deepest
+				}
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return deepest
+}
+
+// limitJSONDepth rejects JSON bodies nested deeper than maxDepth with a 400
+// before any handler decodes them. It must run after limitBody, since it
+// reads the whole body into memory.
+func limitJSONDepth(maxDepth int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if mediaType != "application/json" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeJSONError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body too large")
+					return
+				}
+				writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "reading request body failed")
+				return
+			}
+			if jsonDepth(body, maxDepth) > maxDepth {
+				writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, fmt.Sprintf("request body nests deeper than %d levels", maxDepth))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
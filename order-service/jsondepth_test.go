@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJSONDepth(t *testing.T) {
+	for body, want := range map[string]int{
+		`"flat"`:                    0,
+		`{"a":1}`:                   1,
+		`{"a":[{"b":[]}]}`:          4,
+		`{"s":"[[[{{{"}`:            1,
+		`{"s":"quote \" [[[ here"}`: 1,
+		`[[1],[2],[3]]`:             2,
+	} {
+		if got := jsonDepth([]byte(body), 100); got != want {
+			t.Errorf("jsonDepth(%s) = %d, want %d", body, got, want)
+		}
+	}
+	// Scanning stops as soon as the limit is passed.
+	if got := jsonDepth([]byte(strings.Repeat("[", 1000)), 3); got != 4 {
+		t.Errorf("jsonDepth past limit 3 = %d, want 4", got)
+	}
+}
+
+func TestLimitJSONDepth(t *testing.T) {
+	var got string
+	h := limitJSONDepth(3)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	}))
+
+	rec := serve(t, h, http.MethodPost, "/orders", `[[[[1]]]]`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+
+	body := `{"items":[{"item_id":"1"}]}`
+	rec = serve(t, h, http.MethodPost, "/orders", body)
+	wantStatus(t, rec, http.StatusOK)
+	if got != body {
+		t.Errorf("handler read %q, want the body passed through", got)
+	}
+
+	// Only JSON bodies are inspected.
+	rec = serve(t, h, http.MethodPost, "/orders", `[[[[1]]]]`, "Content-Type", "text/plain")
+	wantStatus(t, rec, http.StatusOK)
+}
+
+func TestCreateOrderRejectsDeepJSON(t *testing.T) {
+	h := newTestHandler(t, WithMaxJSONDepth(4))
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":1,"x":[[[]]]}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	if len(h.store.All()) != 0 {
+		t.Error("deeply nested order was stored")
+	}
+	createTestOrder(t, h.routes(), simpleOrder)
+}
+
+func TestResolveMaxJSONDepth(t *testing.T) {
+	t.Setenv("MAX_JSON_DEPTH", "")
+	if n, err := resolveMaxJSONDepth(); n != defaultMaxJSONDepth || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxJSONDepth)
+	}
+	t.Setenv("MAX_JSON_DEPTH", "8")
+	if n, err := resolveMaxJSONDepth(); n != 8 || err != nil {
+		t.Errorf("8 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "deep"} {
+		t.Setenv("MAX_JSON_DEPTH", v)
+		if _, err := resolveMaxJSONDepth(); err == nil {
+			t.Errorf("MAX_JSON_DEPTH=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// workerGroup tracks the service's background goroutines so shutdown can
+// stop them in order: cancel their shared context, then wait for each to
+// return instead of letting the process exit underneath them.
+type workerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	stopping bool
+	running  map[string]int
+	wg       sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel, running: make(map[string]int)}
+}
+
+// Go runs fn in a new goroutine under name, which is only used for logging
+// and may be shared by several workers. fn must return soon after ctx is
+// cancelled. Once shutdown has begun, new work is refused and logged. Go
+// reports whether fn was started.
+func (g *workerGroup) Go(name string, fn func(ctx context.Context)) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopping {
+		log.Printf("Not starting %s: shutting down", name)
+		return false
+	}
+	g.running[name]++
+	g.wg.Add(1)
+	go func() {
+		defer g.done(name)
+		fn(g.ctx)
+	}()
+	return true
+}
+
+func (g *workerGroup) done(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running[name]--; g.running[name] == 0 {
+		delete(g.running, name)
+	}
+	g.wg.Done()
+}
+
+// Shutdown cancels every worker and waits up to timeout for them to
+// return. Workers still running after that are logged and left behind.
+func (g *workerGroup) Shutdown(timeout time.Duration) error {
+	g.mu.Lock()
+	g.stopping = true
+	g.mu.Unlock()
+	g.cancel()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var names []string
+	for name, n := range g.running {
+		names = append(names, fmt.Sprintf("%s (%d)", name, n))
+	}
+	slices.Sort(names)
+	return fmt.Errorf("background workers still running after %s: %v", timeout, names)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerGroupShutdownWaits(t *testing.T) {
+	g := newWorkerGroup()
+	var stopped atomic.Int32
+	for _, name := range []string{"sweeper", "sender", "sender"} {
+		g.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+			// Shutdown must wait out work done after cancellation.
+			time.Sleep(10 * time.Millisecond)
+			stopped.Add(1)
+		})
+	}
+	if err := g.Shutdown(time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if n := stopped.Load(); n != 3 {
+		t.Errorf("%d workers finished before Shutdown returned, want 3", n)
+	}
+	if len(g.running) != 0 {
+		t.Errorf("running = %v, want none", g.running)
+	}
+}
+
+func TestWorkerGroupShutdownTimesOut(t *testing.T) {
+	g := newWorkerGroup()
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	g.Go("quick", func(ctx context.Context) { <-ctx.Done() })
+	g.Go("stuck", func(context.Context) { <-release })
+
+	err := g.Shutdown(20 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "stuck (1)") || strings.Contains(err.Error(), "quick") {
+		t.Errorf("Shutdown = %v, want only the stuck worker reported", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// loadShedRetryAfter is the Retry-After, in seconds, sent with a request
+// turned away because too many are already in flight.
+const loadShedRetryAfter = 1
+
+var (
+	httpRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served, probes and metrics scrapes aside.",
+	})
+	httpRequestsShed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "HTTP requests turned away with 503 because MAX_IN_FLIGHT was reached.",
+	})
+)
+
+// unlimitedPaths never count towards MAX_IN_FLIGHT: probes and scrapes
+// must keep answering under load, and the long-lived order stream would
+// otherwise hold a slot for as long as each client watches.
+var unlimitedPaths = slices.Concat(probePaths, []string{"/health/detail", "/metrics", "/orders/stream"})
+
+// resolveMaxInFlight reads MAX_IN_FLIGHT, how many requests may be served
+// at once before new ones are turned away. The default of 0 sets no limit.
+func resolveMaxInFlight() (int, error) {
+	v := setting("MAX_IN_FLIGHT")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid MAX_IN_FLIGHT %q: must be a non-negative integer", v)
+	}
+	return n, nil
+}
+
+// inFlightMiddleware counts the requests being served and, once maxInFlight
+// of them are, answers any more with 503 and Retry-After straight away.
+// Shedding the excess keeps latency steady for the requests already
+// admitted instead of slowing everyone down together.
+func (h *handler) inFlightMiddleware(next http.Handler) http.Handler {
+	var slots chan struct{}
+	if h.maxInFlight > 0 {
+		slots = make(chan struct{}, h.maxInFlight)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(unlimitedPaths, strings.TrimPrefix(r.URL.Path, h.basePath)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			default:
+				httpRequestsShed.Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(loadShedRetryAfter))
+				writeJSONError(w, http.StatusServiceUnavailable, CodeOverloaded, "server is at capacity, please retry later")
+				return
+			}
+		}
+		httpRequestsInFlight.Inc()
+		defer httpRequestsInFlight.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
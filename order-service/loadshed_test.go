@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInFlightLimitShedsLoad(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	catalog := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		started <- struct{}{}
+		<-release
+		return map[string]catalogItem{ids[0]: {ID: ids[0], Price: 1, Available: true}}, nil
+	}}
+	routes := newTestHandler(t, WithCatalog(catalog), WithMaxInFlight(2)).routes()
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(t, routes, http.MethodPost, "/orders", simpleOrder).Code
+		}()
+	}
+	<-started
+	<-started
+	if n := testutil.ToFloat64(httpRequestsInFlight); n != 2 {
+		t.Errorf("http_requests_in_flight = %v, want 2", n)
+	}
+
+	shed := testutil.ToFloat64(httpRequestsShed)
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	wantError(t, rec, http.StatusServiceUnavailable, CodeOverloaded)
+	if ra := rec.Header().Get("Retry-After"); ra != "1" {
+		t.Errorf("Retry-After = %q, want 1", ra)
+	}
+	if got := testutil.ToFloat64(httpRequestsShed) - shed; got != 1 {
+		t.Errorf("http_requests_shed_total rose by %v, want 1", got)
+	}
+
+	// Probes and scrapes are answered regardless.
+	for _, path := range []string{"/health", "/metrics"} {
+		wantStatus(t, serve(t, routes, http.MethodGet, path, ""), http.StatusOK)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusCreated {
+			t.Errorf("admitted request got %d, want 201", code)
+		}
+	}
+	// Finished requests free their slots.
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusOK)
+	if n := testutil.ToFloat64(httpRequestsInFlight); n != 0 {
+		t.Errorf("http_requests_in_flight = %v after the requests finished, want 0", n)
+	}
+}
+
+func TestNoInFlightLimitByDefault(t *testing.T) {
+	h := newTestHandler(t)
+	if h.maxInFlight != 0 {
+		t.Fatalf("maxInFlight = %d, want no limit", h.maxInFlight)
+	}
+	wantStatus(t, serve(t, h.routes(), http.MethodGet, "/orders", ""), http.StatusOK)
+}
+
+func TestResolveMaxInFlight(t *testing.T) {
+	t.Setenv("MAX_IN_FLIGHT", "")
+	if n, err := resolveMaxInFlight(); n != 0 || err != nil {
+		t.Errorf("default = %d, %v; want 0", n, err)
+	}
+	t.Setenv("MAX_IN_FLIGHT", "200")
+	if n, err := resolveMaxInFlight(); n != 200 || err != nil {
+		t.Errorf("200 = %d, %v", n, err)
+	}
+	for _, v := range []string{"-1", "lots"} {
+		t.Setenv("MAX_IN_FLIGHT", v)
+		if _, err := resolveMaxInFlight(); err == nil {
+			t.Errorf("MAX_IN_FLIGHT=%q accepted", v)
+		}
+	}
+}
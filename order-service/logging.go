@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/hashicorp/go-uuid"
+)
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	apiVersionKey
+	callerDeadlineKey
+	freshCatalogKey
+)
+
+// logLevel is the minimum level logged. It starts at info and is set from
+// LOG_LEVEL once the config is loaded.
+var logLevel = new(slog.LevelVar)
+
+// setupLogging routes both slog and the standard log package through a JSON
+// handler on stdout that drops lines below logLevel.
+func setupLogging() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel})))
+}
+
+// resolveLogLevel reads LOG_LEVEL (debug, info, warn or error), defaulting
+// to info.
+func resolveLogLevel() (slog.Level, error) {
+	v := setting("LOG_LEVEL")
+	if v == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		return 0, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", v)
+	}
+	return level, nil
+}
+
+// requestIDFrom returns the request ID stored by requestIDMiddleware.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// requestLogger returns a logger that tags every line with the request ID.
+func requestLogger(ctx context.Context) *slog.Logger {
+	if id := requestIDFrom(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
+
+// requestIDMiddleware assigns every request a fresh ID, stores it in the
+// request context and echoes it in the X-Request-ID response header.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			slog.Error("generating request id", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// accessLogMiddleware writes one structured log line per request, at debug
+// level so production can silence it.
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		requestLogger(r.Context()).Debug("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", ww.Status(),
+			"bytes", ww.BytesWritten(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+const defaultSlowRequestThreshold = time.Second
+
+// resolveSlowRequestThreshold reads SLOW_REQUEST_MS, defaulting to 1000; 0
+// turns slow request logging off.
+func resolveSlowRequestThreshold() (time.Duration, error) {
+	v := setting("SLOW_REQUEST_MS")
+	if v == "" {
+		return defaultSlowRequestThreshold, nil
+	}
+	ms, err := strconv.Atoi(v)
+	if err != nil || ms < 0 {
+		return 0, fmt.Errorf("invalid SLOW_REQUEST_MS %q: must be a non-negative integer", v)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// slowRequestMiddleware logs a WARN line for every request that takes longer
+// than threshold. Event streams are long-lived by design and are skipped.
+func slowRequestMiddleware(threshold time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			elapsed := time.Since(start)
+			if elapsed <= threshold || strings.HasPrefix(ww.Header().Get("Content-Type"), "text/event-stream") {
+				return
+			}
+			requestLogger(r.Context()).Warn("slow request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", ww.Status(),
+				"duration_ms", elapsed.Milliseconds(),
+				"threshold_ms", threshold.Milliseconds(),
+			)
+		})
+	}
+}
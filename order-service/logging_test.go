@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	h := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestIDFrom(r.Context())
+	}))
+
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	id := first.Header().Get("X-Request-ID")
+	if id == "" || id != seen {
+		t.Fatalf("X-Request-ID = %q, context had %q; want the same non-empty id", id, seen)
+	}
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if second.Header().Get("X-Request-ID") == id {
+		t.Error("two requests got the same id")
+	}
+}
+
+func TestRequestLoggerTagsRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	requestLogger(context.WithValue(context.Background(), requestIDKey, "req-42")).Info("hello")
+	if !strings.Contains(logs.String(), "request_id=req-42") {
+		t.Fatalf("log line missing the request id: %s", logs)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	h := requestIDMiddleware(accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders/x", nil))
+
+	for _, want := range []string{"method=GET", "path=/orders/x", "status=418", "request_id=" + rec.Header().Get("X-Request-ID")} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("access log missing %q: %s", want, logs)
+		}
+	}
+}
+
+// Request lines are logged at debug, so LOG_LEVEL=info silences them.
+func TestAccessLogFollowsLogLevel(t *testing.T) {
+	prevLevel, prevLogger := logLevel.Level(), slog.Default()
+	t.Cleanup(func() {
+		logLevel.Set(prevLevel)
+		slog.SetDefault(prevLogger)
+	})
+	var buf bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: logLevel})))
+	h := accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	logLevel.Set(slog.LevelInfo)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if buf.Len() != 0 {
+		t.Errorf("request logged at info level: %s", &buf)
+	}
+	logLevel.Set(slog.LevelDebug)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	if !strings.Contains(buf.String(), "level=DEBUG msg=request") {
+		t.Errorf("request not logged at debug level: %s", &buf)
+	}
+}
+
+func TestSlowRequestMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	sleepy := func(contentType string) http.Handler {
+		return slowRequestMiddleware(time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			time.Sleep(5 * time.Millisecond)
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	}
+
+	serve(t, sleepy("text/event-stream"), http.MethodGet, "/orders/stream", "")
+	if strings.Contains(logs.String(), "slow request") {
+		t.Fatalf("event stream was logged as slow:\n%s", logs)
+	}
+	serve(t, sleepy("application/json"), http.MethodPost, "/orders", "")
+	for _, want := range []string{"level=WARN", "slow request", "path=/orders", "status=202", "threshold_ms=1"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, logs)
+		}
+	}
+}
+
+func TestSlowRequestMiddlewareIgnoresFastRequests(t *testing.T) {
+	logs := captureLogs(t)
+	h := slowRequestMiddleware(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve(t, h, http.MethodGet, "/orders", "")
+	if strings.Contains(logs.String(), "slow request") {
+		t.Fatalf("fast request was logged:\n%s", logs)
+	}
+}
+
+func TestResolveSlowRequestThreshold(t *testing.T) {
+	for v, want := range map[string]time.Duration{"": defaultSlowRequestThreshold, "250": 250 * time.Millisecond, "0": 0} {
+		t.Setenv("SLOW_REQUEST_MS", v)
+		if d, err := resolveSlowRequestThreshold(); d != want || err != nil {
+			t.Errorf("SLOW_REQUEST_MS=%q = %s, %v; want %s", v, d, err, want)
+		}
+	}
+	for _, v := range []string{"-1", "1s"} {
+		t.Setenv("SLOW_REQUEST_MS", v)
+		if _, err := resolveSlowRequestThreshold(); err == nil {
+			t.Errorf("SLOW_REQUEST_MS=%q accepted", v)
+		}
+	}
+}
+
+func TestResolveLogLevel(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	if l, err := resolveLogLevel(); l != slog.LevelInfo || err != nil {
+		t.Errorf("default = %s, %v", l, err)
+	}
+	t.Setenv("LOG_LEVEL", "debug")
+	if l, err := resolveLogLevel(); l != slog.LevelDebug || err != nil {
+		t.Errorf("debug = %s, %v", l, err)
+	}
+	t.Setenv("LOG_LEVEL", "verbose")
+	if _, err := resolveLogLevel(); err == nil {
+		t.Error("LOG_LEVEL=verbose accepted")
+	}
+}
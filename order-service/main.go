@@ -1,80 +1,218 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/hashicorp/go-uuid"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 )
 
-type Order struct {
-	ID      string   `json:"id"`
-	ItemIDs []string `json:"item_ids"`
-	Status  string   `json:"status"`
+// resolvePort reads the listen port from PORT, defaulting to 8081.
+func resolvePort() (string, error) {
+	port := setting("PORT")
+	if port == "" {
+		return "8081", nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid PORT %q: must be a number between 1 and 65535", port)
+	}
+	return port, nil
 }
 
-var orders = make(map[string]Order)
+const defaultMaxBodyBytes = 1 << 20
 
-// Service registration with Consul (simplified - no consul dependency)
-func registerServiceWithConsul() {
-	log.Println("Service registration skipped (no consul dependency)")
+// resolveMaxBodyBytes reads MAX_BODY_BYTES, defaulting to 1MB.
+func resolveMaxBodyBytes() (int64, error) {
+	v := setting("MAX_BODY_BYTES")
+	if v == "" {
+		return defaultMaxBodyBytes, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_BODY_BYTES %q: must be a positive integer", v)
+	}
+	return n, nil
 }
 
-// Discover other services using Consul (simplified - returns hardcoded values)
-func findService(serviceName string) (string, error) {
-	// In a real Kubernetes environment, you'd use service names
-	switch serviceName {
-	case "food-catalog-service":
-		return "http://food-catalog-service:8080", nil
-	default:
-		return "", fmt.Errorf("service %s not found", serviceName)
+// parseList splits a comma-separated setting into trimmed, non-empty values.
+func parseList(v string) []string {
+	var origins []string
+	for _, o := range strings.Split(v, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
 	}
+	return origins
 }
 
-
 func main() {
-	// Try to register with Consul, but don't fail if it's not available
-	go registerServiceWithConsul()
+	setupLogging()
 
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("Flushing traces failed: %v", err)
+		}
+	}()
 
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	})
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logLevel.Set(cfg.LogLevel)
+	outboundTransport = newOutboundTransport(cfg.Outbound)
+
+	catalog, err := newCatalogClient(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	catalog = newCachedCatalog(catalog, cfg.CatalogCacheTTL)
 
-	r.Post("/orders", func(w http.ResponseWriter, r *http.Request) {
-		var newOrder Order
-		if err := json.NewDecoder(r.Body).Decode(&newOrder); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+	if cfg.WaitForDeps {
+		log.Printf("Waiting up to %s for dependencies...", cfg.WaitForDepsTimeout)
+		if err := waitForDeps(context.Background(), startupDependencies(catalog), cfg.WaitForDepsTimeout, depPollInterval); err != nil {
+			log.Fatalf("Dependencies not ready: %v", err)
 		}
+	}
 
-        // Example of inter-service communication
-        // Here you would call the food-catalog-service to validate ItemIDs
-        catalogAddr, err := findService("food-catalog-service")
-        if err != nil {
-            http.Error(w, "Food catalog service not available", http.StatusInternalServerError)
-            log.Printf("Error finding catalog service: %v", err)
-            return
-        }
-        log.Printf("Found food-catalog-service at: %s. Would validate items here.", catalogAddr)
-
-
-		orderID, _ := uuid.GenerateUUID()
-		newOrder.ID = orderID
-		newOrder.Status = "received"
-		orders[orderID] = newOrder
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(newOrder)
-	})
+	store, closeStore, err := openOrderStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open order store: %v", err)
+	}
+	defer closeStore()
+	registerOrdersGauge(store)
+
+	opts := []HandlerOption{
+		WithIdempotencyTTL(cfg.IdempotencyTTL),
+		WithAllowedOrigins(cfg.AllowedOrigins),
+		WithMaxBodyBytes(cfg.MaxBodyBytes),
+		WithMaxJSONDepth(cfg.MaxJSONDepth),
+		WithMaxBatchSize(cfg.MaxBatchSize),
+		WithMaxInFlight(cfg.MaxInFlight),
+		WithCatalog(catalog),
+		WithWebhookClient(newWebhookClient(cfg.Outbound)),
+		// Half the shutdown budget, so the dispatcher returns in time for
+		// the workers' Shutdown to see it finish.
+		WithWebhookDrainTimeout(cfg.ShutdownTimeout / 2),
+		WithCatalogConcurrency(cfg.CatalogConcurrency),
+		WithValidationMode(cfg.ValidationMode),
+		WithMaxUnknownItems(cfg.MaxUnknownItems),
+		WithRequestTimeout(cfg.RequestTimeout),
+		WithCacheControl(cfg.CacheControl),
+		WithEventLog(NewMemoryEventLog(cfg.EventsPerOrder, cfg.MaxOrders)),
+		WithPrettyJSON(cfg.PrettyJSON),
+		WithCompression(cfg.CompressLevel, cfg.CompressMinBytes),
+		WithAdmin(cfg.AdminEnabled),
+		WithSlowRequestThreshold(cfg.SlowRequest),
+		WithBasePath(cfg.BasePath),
+		WithRevalidateAutoCancel(cfg.RevalidateAutoCancel),
+		WithWebhookSecret(cfg.WebhookSecret),
+		WithDefaultCurrency(cfg.DefaultCurrency),
+		WithMaxNotesLength(cfg.MaxNotesLength),
+		WithMaxItemIDLength(cfg.MaxItemIDLength),
+		WithErrorFormat(cfg.ErrorFormat),
+		WithMaintenanceMode(cfg.MaintenanceMode),
+		WithItemPolicy(cfg.BlockedItems, cfg.AllowedItems),
+		WithPrepTimes(cfg.PrepTimes),
+	}
+	if cfg.RateLimitRPS > 0 {
+		opts = append(opts, WithRateLimit(cfg.RateLimitRPS, cfg.RateLimitBurst))
+	}
+	if customers := newCustomerDirectory(cfg); customers != nil {
+		opts = append(opts, WithCustomerDirectory(customers))
+	}
+	if len(cfg.APIKeys) > 0 {
+		opts = append(opts, WithAPIKeys(cfg.APIKeys))
+	} else {
+		log.Println("Warning: API_KEYS not set, order endpoints are unauthenticated")
+		if cfg.AdminEnabled {
+			log.Println("Warning: API_KEYS not set, the /admin routes are unauthenticated")
+		}
+	}
+	if cfg.NATSURL != "" {
+		natsPub, err := newNATSPublisher(cfg.NATSURL)
+		if err != nil {
+			log.Printf("Warning: could not connect to NATS, order events disabled: %v", err)
+		} else {
+			defer natsPub.Close()
+			opts = append(opts, WithPublisher(natsPub))
+		}
+	}
+
+	h := newHandler(store, opts...)
+	registerWebhookQueueGauge(h.webhooks.queue)
+	conns := newConnTracker()
+	server := newHTTPServer(cfg.Port, h.routes(), cfg.Server, conns)
+
+	grpcServer := h.grpcServer()
+	grpcListener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %v", err)
+	}
 
-	log.Println("Order Service starting on port 8081...")
-	http.ListenAndServe(":8081", r)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Try to register with Consul, but don't fail if it's not available.
+	// Registration follows the signal rather than the workers' context, so
+	// the service is deregistered before it starts draining.
+	h.workers.Go("consul registration", func(context.Context) {
+		registerServiceWithConsul(ctx, cfg.Port, cfg.TLSCertFile != "", cfg.ConsulReregisterInterval)
+	})
+	h.workers.Go("retention sweeper", func(ctx context.Context) {
+		h.runRetentionSweeper(ctx, cfg.OrderRetention, cfg.RetentionInterval)
+	})
+	// Open event streams would otherwise hold the drain until it times out.
+	server.RegisterOnShutdown(h.broker.close)
+	go func() {
+		var err error
+		if cfg.TLSCertFile != "" {
+			log.Printf("Order Service starting with TLS on port %s...", cfg.Port)
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			log.Printf("Order Service starting on port %s...", cfg.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+	go func() {
+		log.Printf("Order Service gRPC starting on port %s...", cfg.GRPCPort)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Order Service shutting down gracefully...")
+
+	// Stop taking requests first, draining HTTP and gRPC together, then
+	// stop the background workers, which may still be delivering webhooks
+	// for the last of them.
+	var grpcDrained sync.WaitGroup
+	grpcDrained.Add(1)
+	go func() {
+		defer grpcDrained.Done()
+		stopGRPCServer(grpcServer, cfg.ShutdownTimeout)
+	}()
+	if err := shutdownServer(server, conns, cfg.ShutdownTimeout); err != nil {
+		log.Printf("Graceful shutdown failed: %v", err)
+	}
+	grpcDrained.Wait()
+	if err := h.workers.Shutdown(cfg.ShutdownTimeout); err != nil {
+		log.Printf("Stopping background work failed: %v", err)
+	}
 }
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestResolvePort(t *testing.T) {
+	t.Setenv("PORT", "")
+	if port, err := resolvePort(); port != "8081" || err != nil {
+		t.Errorf("default = %q, %v; want 8081", port, err)
+	}
+	t.Setenv("PORT", "9090")
+	if port, err := resolvePort(); port != "9090" || err != nil {
+		t.Errorf("PORT=9090 = %q, %v", port, err)
+	}
+	for _, v := range []string{"0", "65536", "http", "-1"} {
+		t.Setenv("PORT", v)
+		if _, err := resolvePort(); err == nil {
+			t.Errorf("PORT=%q accepted", v)
+		}
+	}
+}
+
+func TestResolveMaxBodyBytes(t *testing.T) {
+	t.Setenv("MAX_BODY_BYTES", "")
+	if n, err := resolveMaxBodyBytes(); n != defaultMaxBodyBytes || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxBodyBytes)
+	}
+	t.Setenv("MAX_BODY_BYTES", "2048")
+	if n, err := resolveMaxBodyBytes(); n != 2048 || err != nil {
+		t.Errorf("MAX_BODY_BYTES=2048 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "-5", "1MB"} {
+		t.Setenv("MAX_BODY_BYTES", v)
+		if _, err := resolveMaxBodyBytes(); err == nil {
+			t.Errorf("MAX_BODY_BYTES=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// maintenanceRetryAfter is the Retry-After, in seconds, sent while the
+// service is in maintenance mode.
+const maintenanceRetryAfter = 60
+
+// resolveMaintenanceMode reads MAINTENANCE_MODE, which starts the service
+// already in maintenance. Off by default.
+func resolveMaintenanceMode() (bool, error) {
+	v := setting("MAINTENANCE_MODE")
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid MAINTENANCE_MODE %q: must be true or false", v)
+	}
+	return enabled, nil
+}
+
+// maintenanceMiddleware answers 503 with Retry-After while maintenance mode
+// is on, so traffic drains without the process looking unhealthy.
+func (h *handler) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.maintenance.Load() {
+			w.Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+			writeJSONError(w, http.StatusServiceUnavailable, CodeMaintenance, "service is down for maintenance, please retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (h *handler) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, maintenanceState{Enabled: h.maintenance.Load()})
+}
+
+// setMaintenance turns maintenance mode on or off with {"enabled": bool}.
+func (h *handler) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, CodeValidationFailed, "enabled is required")
+		return
+	}
+	if h.maintenance.Swap(*req.Enabled) != *req.Enabled {
+		log.Printf("Maintenance mode set to %t", *req.Enabled)
+	}
+	writeJSON(w, http.StatusOK, maintenanceState{Enabled: *req.Enabled})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResolveMaintenanceMode(t *testing.T) {
+	for _, tc := range []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"true", true, false},
+		{"0", false, false},
+		{"sometimes", false, true},
+	} {
+		t.Setenv("MAINTENANCE_MODE", tc.value)
+		got, err := resolveMaintenanceMode()
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("MAINTENANCE_MODE=%q: got %t, %v; want %t, error %t", tc.value, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestMaintenanceRoutesNeedAdmin(t *testing.T) {
+	routes := newTestHandler(t).routes()
+
+	wantStatus(t, serve(t, routes, http.MethodPut, "/admin/maintenance", `{"enabled":true}`), http.StatusNotFound)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusOK)
+}
+
+func TestMaintenanceModeDrainsOrderTraffic(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true)).routes()
+
+	rec := serve(t, routes, http.MethodPut, "/admin/maintenance", `{"enabled":true}`)
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[maintenanceState](t, rec); !got.Enabled {
+		t.Fatalf("enabled = false after turning maintenance on")
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders", "")
+	wantError(t, rec, http.StatusServiceUnavailable, CodeMaintenance)
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	wantError(t, serve(t, routes, http.MethodDelete, "/admin/orders", ""), http.StatusServiceUnavailable, CodeMaintenance)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/healthz", ""), http.StatusOK)
+
+	// The switch itself stays reachable.
+	if got := decodeBody[maintenanceState](t, serve(t, routes, http.MethodGet, "/admin/maintenance", "")); !got.Enabled {
+		t.Error("GET /admin/maintenance reports maintenance off")
+	}
+	wantStatus(t, serve(t, routes, http.MethodPut, "/admin/maintenance", `{"enabled":false}`), http.StatusOK)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusOK)
+}
+
+func TestSetMaintenanceRequiresEnabled(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true)).routes()
+
+	wantError(t, serve(t, routes, http.MethodPut, "/admin/maintenance", `{}`), http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestMaintenanceRequiresAPIKey(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true), WithAPIKeys([]string{"secret"})).routes()
+
+	wantError(t, serve(t, routes, http.MethodPut, "/admin/maintenance", `{"enabled":true}`), http.StatusUnauthorized, CodeUnauthorized)
+	wantStatus(t, serve(t, routes, http.MethodPut, "/admin/maintenance", `{"enabled":true}`, "Authorization", "Bearer secret"), http.StatusOK)
+}
+
+func TestMaintenanceBodyIsLimited(t *testing.T) {
+	routes := newTestHandler(t, WithAdmin(true), WithMaxBodyBytes(32)).routes()
+
+	body := `{"enabled":true,"padding":"` + strings.Repeat("x", 64) + `"}`
+	wantError(t, serve(t, routes, http.MethodPut, "/admin/maintenance", body), http.StatusRequestEntityTooLarge, CodeBodyTooLarge)
+}
+
+func TestStartInMaintenanceMode(t *testing.T) {
+	routes := newTestHandler(t, WithMaintenanceMode(true)).routes()
+
+	wantError(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusServiceUnavailable, CodeMaintenance)
+}
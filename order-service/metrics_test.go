@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCountByRoutePattern(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	counter := httpRequestsTotal.WithLabelValues(http.MethodGet, "/orders/{id}", "404")
+	before := testutil.ToFloat64(counter)
+
+	serve(t, routes, http.MethodGet, "/orders/a", "")
+	serve(t, routes, http.MethodGet, "/orders/b", "")
+
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Fatalf("GET /orders/{id} 404 count rose by %v, want 2", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	serve(t, routes, http.MethodGet, "/orders", "")
+
+	rec := serve(t, routes, http.MethodGet, "/metrics", "")
+	wantStatus(t, rec, http.StatusOK)
+	for _, want := range []string{"http_requests_total", "http_request_duration_seconds", `route="/orders"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
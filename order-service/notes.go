@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultMaxNotesLength = 500
+
+// resolveMaxNotesLength reads MAX_NOTES_LENGTH, the most characters an
+// order's notes may hold, defaulting to 500.
+func resolveMaxNotesLength() (int, error) {
+	v := setting("MAX_NOTES_LENGTH")
+	if v == "" {
+		return defaultMaxNotesLength, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_NOTES_LENGTH %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// sanitizeNotes drops control characters other than newlines and tabs, so
+// notes cannot smuggle terminal escapes onto kitchen screens or logs.
+func sanitizeNotes(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
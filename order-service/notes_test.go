@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeNotes(t *testing.T) {
+	for in, want := range map[string]string{
+		"no onions":              "no onions",
+		"line one\nline\ttwo":    "line one\nline\ttwo",
+		"ring \x07bell\x1b[31m!": "ring bell[31m!",
+		"crème brûlée\u0085":     "crème brûlée",
+	} {
+		if got := sanitizeNotes(in); got != want {
+			t.Errorf("sanitizeNotes(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCreateOrderNotes(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+
+	o := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"notes":"no onions\nextra ketchup"}`)
+	if o.Notes != "no onions\nextra ketchup" {
+		t.Errorf("notes = %q, want them verbatim", o.Notes)
+	}
+	o = createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"notes":"no\u001b[2J onions\u0000"}`)
+	if o.Notes != "no[2J onions" {
+		t.Errorf("notes = %q, want control characters stripped", o.Notes)
+	}
+	if stored, _ := h.store.Get(o.ID); stored.Notes != o.Notes {
+		t.Errorf("stored notes = %q, want %q", stored.Notes, o.Notes)
+	}
+}
+
+func TestCreateOrderNotesTooLong(t *testing.T) {
+	routes := newTestHandler(t, WithMaxNotesLength(5)).routes()
+	order := func(notes string) string {
+		return `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"notes":"` + notes + `"}`
+	}
+
+	// The limit counts characters, not bytes.
+	createTestOrder(t, routes, order("ééééé"))
+	rec := serve(t, routes, http.MethodPost, "/orders", order(strings.Repeat("a", 6)))
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+	if d := decodeBody[errorResponse](t, rec).Details; len(d) != 1 || d[0].Field != "notes" {
+		t.Errorf("details = %+v, want a notes error", d)
+	}
+}
+
+func TestResolveMaxNotesLength(t *testing.T) {
+	t.Setenv("MAX_NOTES_LENGTH", "")
+	if n, err := resolveMaxNotesLength(); n != defaultMaxNotesLength || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxNotesLength)
+	}
+	t.Setenv("MAX_NOTES_LENGTH", "120")
+	if n, err := resolveMaxNotesLength(); n != 120 || err != nil {
+		t.Errorf("120 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "long"} {
+		t.Setenv("MAX_NOTES_LENGTH", v)
+		if _, err := resolveMaxNotesLength(); err == nil {
+			t.Errorf("MAX_NOTES_LENGTH=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Order is an order as stored and served. In JSON the identifying,
+// pricing and lifecycle fields are always present, with items as an array
+// even when empty; every optional field is omitted while unset.
+type Order struct {
+	ID string `json:"id"`
+	// OrderNumber is a short sequential number assigned by the store, for
+	// staff to read out instead of the id.
+	OrderNumber int64      `json:"order_number,omitempty"`
+	CustomerID  string     `json:"customer_id"`
+	Items       []LineItem `json:"items"`
+	Status      string     `json:"status"`
+	TotalCents  int        `json:"total_cents"`
+	Currency    string     `json:"currency"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// EstimatedReadyAt is when the order should be ready, estimated from
+	// its items when placed and restarted once preparation begins.
+	EstimatedReadyAt *time.Time `json:"estimated_ready_at,omitempty"`
+	// Priority surfaces urgent orders first: 0 is normal, higher is more
+	// urgent, up to maxPriority.
+	Priority int `json:"priority,omitempty"`
+	// AssignedTo is the station or courier handling the order, set with
+	// POST /orders/{id}/assign.
+	AssignedTo string `json:"assigned_to,omitempty"`
+	// Tags are free-form labels such as "promo" or "dine-in", stored
+	// lower-cased and without duplicates.
+	Tags []string `json:"tags,omitempty"`
+	// Notes are the customer's free-text instructions, e.g. "no onions".
+	Notes string `json:"notes,omitempty"`
+	// CallbackURL, if set, receives a signed POST of the order whenever its
+	// status changes.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// ValidationSkipped marks orders accepted without checking the catalog.
+	ValidationSkipped bool `json:"validation_skipped,omitempty"`
+	// ItemChecks is set when a partial catalog outage had the items checked
+	// one by one. Items with outcome "unknown" are missing from TotalCents.
+	ItemChecks []itemCheck `json:"item_checks,omitempty"`
+	// StatusHistory records when the order entered each status, oldest
+	// first.
+	StatusHistory []statusChange `json:"status_history,omitempty"`
+	// Timings is the seconds spent in each status the order has left,
+	// filled in from StatusHistory by GET /orders/{id}.
+	Timings map[string]float64 `json:"timings,omitempty"`
+	// Deleted orders are kept for audit but hidden from the API by default.
+	Deleted   bool       `json:"deleted,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// LineItem is one catalog item in an order and how many of it were ordered.
+type LineItem struct {
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// UnmarshalJSON accepts the legacy {"item_ids": [...]} request shape as well
+// as "items", mapping each legacy id to a quantity of one. Orders are only
+// decoded from client requests, so unknown fields are always rejected; a
+// custom unmarshaler does not inherit the caller's DisallowUnknownFields.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plainOrder Order
+	aux := struct {
+		*plainOrder
+		ItemIDs []string `json:"item_ids"`
+	}{plainOrder: (*plainOrder)(o)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aux); err != nil {
+		return err
+	}
+	if len(aux.ItemIDs) > 0 {
+		if len(o.Items) > 0 {
+			return errors.New("use either items or item_ids, not both")
+		}
+		for _, id := range aux.ItemIDs {
+			o.Items = append(o.Items, LineItem{ItemID: id, Quantity: 1})
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes a nil Items as [] so clients never see null for a
+// required field.
+func (o Order) MarshalJSON() ([]byte, error) {
+	type plainOrder Order
+	if o.Items == nil {
+		o.Items = []LineItem{}
+	}
+	return json.Marshal(plainOrder(o))
+}
+
+// mergeLineItems collapses lines for the same item into one, summing their
+// quantities and keeping the position of the first occurrence.
+func mergeLineItems(lines []LineItem) []LineItem {
+	merged := make([]LineItem, 0, len(lines))
+	index := make(map[string]int, len(lines))
+	for _, li := range lines {
+		if i, ok := index[li.ItemID]; ok {
+			merged[i].Quantity += li.Quantity
+			continue
+		}
+		index[li.ItemID] = len(merged)
+		merged = append(merged, li)
+	}
+	return merged
+}
+
+// ItemIDs returns the distinct item ids in the order, in first-seen order.
+func (o Order) ItemIDs() []string {
+	ids := make([]string, 0, len(o.Items))
+	seen := make(map[string]bool, len(o.Items))
+	for _, li := range o.Items {
+		if !seen[li.ItemID] {
+			seen[li.ItemID] = true
+			ids = append(ids, li.ItemID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOrderUnmarshalLegacyItemIDs(t *testing.T) {
+	var o Order
+	if err := json.Unmarshal([]byte(`{"customer_id":"c1","item_ids":["1","2"]}`), &o); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := []LineItem{{ItemID: "1", Quantity: 1}, {ItemID: "2", Quantity: 1}}
+	if !slices.Equal(o.Items, want) {
+		t.Errorf("items = %+v, want %+v", o.Items, want)
+	}
+
+	for name, body := range map[string]string{
+		"both shapes":   `{"items":[{"item_id":"1","quantity":2}],"item_ids":["2"]}`,
+		"unknown field": `{"items":[],"size":"large"}`,
+	} {
+		if err := json.Unmarshal([]byte(body), new(Order)); err == nil {
+			t.Errorf("%s: accepted %s", name, body)
+		}
+	}
+}
+
+func TestOrderItemIDsAreDistinct(t *testing.T) {
+	o := Order{Items: []LineItem{{ItemID: "2"}, {ItemID: "1"}, {ItemID: "2"}}}
+	if ids := o.ItemIDs(); !slices.Equal(ids, []string{"2", "1"}) {
+		t.Errorf("ItemIDs = %v, want [2 1]", ids)
+	}
+}
+
+func TestCreateOrderKeepsQuantities(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":3}]}`)
+	if len(order.Items) != 1 || order.Items[0].Quantity != 3 {
+		t.Fatalf("items = %+v, want 3 of item 1", order.Items)
+	}
+
+	legacy := createTestOrder(t, routes, `{"customer_id":"c1","item_ids":["1","2"]}`)
+	if len(legacy.Items) != 2 || legacy.Items[1].Quantity != 1 {
+		t.Fatalf("legacy items = %+v, want one each of 1 and 2", legacy.Items)
+	}
+}
+
+func TestMergeLineItems(t *testing.T) {
+	got := mergeLineItems([]LineItem{{ItemID: "2", Quantity: 1}, {ItemID: "1", Quantity: 2}, {ItemID: "2", Quantity: 3}})
+	want := []LineItem{{ItemID: "2", Quantity: 4}, {ItemID: "1", Quantity: 2}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("mergeLineItems = %+v, want %+v", got, want)
+	}
+	if got := mergeLineItems(nil); got == nil || len(got) != 0 {
+		t.Errorf("mergeLineItems(nil) = %#v, want an empty slice", got)
+	}
+}
+
+func TestCreateOrderMergesRepeatedItems(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1},{"item_id":"1","quantity":2}]}`)
+	if len(order.Items) != 1 || order.Items[0].Quantity != 3 || order.TotalCents != 750 {
+		t.Fatalf("order = %+v, want one line of 3 totalling 750", order)
+	}
+
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID, `{"items":[{"item_id":"2","quantity":1},{"item_id":"2","quantity":1}]}`)
+	wantStatus(t, rec, http.StatusOK)
+	if got := decodeBody[Order](t, rec); len(got.Items) != 1 || got.Items[0].Quantity != 2 {
+		t.Errorf("PATCH items = %+v, want one line of 2", got.Items)
+	}
+}
+
+// The exact JSON clients see: required fields always present, items never
+// null, optional fields only once set.
+func TestOrderJSON(t *testing.T) {
+	minimal := Order{ID: "o1", CustomerID: "c1", Status: StatusReceived, Currency: "USD", CreatedAt: testTime, UpdatedAt: testTime}
+	got, err := json.Marshal(minimal)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `{"id":"o1","customer_id":"c1","items":[],"status":"received","total_cents":0,"currency":"USD",` +
+		`"created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}`
+	if string(got) != want {
+		t.Errorf("minimal order:\n got %s\nwant %s", got, want)
+	}
+
+	ready := testTime.Add(6 * time.Minute)
+	full := minimal
+	full.OrderNumber = 7
+	full.Items = []LineItem{{ItemID: "1", Quantity: 2}}
+	full.TotalCents = 500
+	full.EstimatedReadyAt = &ready
+	full.Priority = 2
+	full.AssignedTo = "grill"
+	full.Tags = []string{"promo"}
+	full.Notes = "no onions"
+	full.CallbackURL = "https://example.com/hook"
+	full.ValidationSkipped = true
+	full.StatusHistory = []statusChange{{Status: StatusReceived, At: testTime}}
+	if got, err = json.Marshal(full); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want = `{"id":"o1","order_number":7,"customer_id":"c1","items":[{"item_id":"1","quantity":2}],"status":"received",` +
+		`"total_cents":500,"currency":"USD","created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z",` +
+		`"estimated_ready_at":"2024-05-01T12:06:00Z","priority":2,"assigned_to":"grill","tags":["promo"],"notes":"no onions",` +
+		`"callback_url":"https://example.com/hook","validation_skipped":true,` +
+		`"status_history":[{"status":"received","at":"2024-05-01T12:00:00Z"}]}`
+	if string(got) != want {
+		t.Errorf("full order:\n got %s\nwant %s", got, want)
+	}
+}
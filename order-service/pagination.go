@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// parsePagination reads ?limit= and ?offset=, applying the default limit and
+// capping it at maxPageLimit.
+func parsePagination(q url.Values) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q: must be a non-negative integer", v)
+		}
+		limit = min(limit, maxPageLimit)
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q: must be a non-negative integer", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// paginate returns the window of orders selected by limit and offset.
+func paginate(orders []Order, limit, offset int) []Order {
+	if offset >= len(orders) {
+		return []Order{}
+	}
+	end := min(offset+limit, len(orders))
+	return orders[offset:end]
+}
+
+// listEnvelope wraps a page of orders with its pagination metadata, for
+// clients that would rather not read X-Total-Count.
+type listEnvelope struct {
+	Data   []Order `json:"data"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// wantsEnvelope reports whether a list request asked for a listEnvelope,
+// with ?envelope=true or an Accept entry carrying an envelope=true
+// parameter such as application/json; envelope=true. Lists are bare arrays
+// otherwise, as they always were.
+func wantsEnvelope(r *http.Request) bool {
+	if v := r.URL.Query().Get("envelope"); v != "" {
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if b, _ := strconv.ParseBool(params["envelope"]); b {
+			return true
+		}
+	}
+	return false
+}
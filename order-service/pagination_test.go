@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	for _, tc := range []struct {
+		query         string
+		limit, offset int
+		ok            bool
+	}{
+		{"", defaultPageLimit, 0, true},
+		{"limit=10&offset=20", 10, 20, true},
+		{"limit=0", 0, 0, true},
+		{"limit=5000", maxPageLimit, 0, true},
+		{"limit=-1", 0, 0, false},
+		{"limit=ten", 0, 0, false},
+		{"offset=-3", 0, 0, false},
+	} {
+		q, _ := url.ParseQuery(tc.query)
+		limit, offset, err := parsePagination(q)
+		if (err == nil) != tc.ok || limit != tc.limit || offset != tc.offset {
+			t.Errorf("parsePagination(%q) = %d, %d, %v", tc.query, limit, offset, err)
+		}
+	}
+}
+
+func TestListOrdersPaginates(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	var ids []string
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		ids = append(ids, createTestOrder(t, routes, `{"id":"`+id+`","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`).ID)
+	}
+
+	rec := serve(t, routes, http.MethodGet, "/orders?limit=2&offset=1", "")
+	wantStatus(t, rec, http.StatusOK)
+	if total := rec.Header().Get("X-Total-Count"); total != "5" {
+		t.Errorf("X-Total-Count = %q, want 5", total)
+	}
+	page := decodeBody[[]Order](t, rec)
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[2] {
+		t.Fatalf("page = %+v, want b and c", page)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders?offset=10", "")
+	if page := decodeBody[[]Order](t, rec); len(page) != 0 {
+		t.Errorf("offset past the end returned %d orders", len(page))
+	}
+}
+
+func TestListOrdersRejectsBadPagination(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodGet, "/orders?limit=abc", "")
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+}
+
+func TestListOrdersEnvelope(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	for _, id := range []string{"a", "b", "c"} {
+		createTestOrder(t, routes, `{"id":"`+id+`","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		headers []string
+	}{
+		{"query", "/orders?envelope=true&limit=2&offset=1", nil},
+		{"accept", "/orders?limit=2&offset=1", []string{"Accept", "text/html, application/json; envelope=true"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, routes, http.MethodGet, tc.path, "", tc.headers...)
+			wantStatus(t, rec, http.StatusOK)
+			env := decodeBody[listEnvelope](t, rec)
+			if env.Total != 3 || env.Limit != 2 || env.Offset != 1 || len(env.Data) != 2 || env.Data[0].ID != "b" {
+				t.Errorf("envelope = %+v, want b and c of 3", env)
+			}
+		})
+	}
+
+	// An explicit ?envelope=false beats the Accept header.
+	rec := serve(t, routes, http.MethodGet, "/orders?envelope=false", "", "Accept", "application/json; envelope=true")
+	if page := decodeBody[[]Order](t, rec); len(page) != 3 {
+		t.Errorf("envelope=false returned %d orders, want the bare array of 3", len(page))
+	}
+	rec = serve(t, routes, http.MethodGet, "/orders?envelope=true&customer=nobody", "")
+	if body := rec.Body.String(); !strings.Contains(body, `"data":[]`) {
+		t.Errorf("body = %s, want an empty data array", body)
+	}
+}
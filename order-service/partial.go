@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// resolveMaxUnknownItems reads CATALOG_MAX_UNKNOWN_ITEMS: how many items of
+// a multi-item order may go unchecked when the catalog is only partly
+// reachable. The default of 0 fails the whole order on any outage.
+func resolveMaxUnknownItems() (int, error) {
+	v := setting("CATALOG_MAX_UNKNOWN_ITEMS")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid CATALOG_MAX_UNKNOWN_ITEMS %q: must be a non-negative integer", v)
+	}
+	return n, nil
+}
+
+// checkEachItem validates lines one item at a time after a whole-order
+// validation hit a catalog outage, so the items the catalog can still
+// answer for are not lost with the ones it cannot. Any invalid,
+// unavailable, short or out-of-hours item rejects the order; otherwise the
+// order is accepted when no more than maxUnknownItems went unchecked, or in
+// lenient mode regardless, with skipped set. Either way the per-item
+// outcomes are returned, in line order.
+func (h *handler) checkEachItem(ctx context.Context, lines []LineItem) (items map[string]catalogItem, skipped bool, checks []itemCheck, reqErr *requestError) {
+	items, checks = h.lookupEach(ctx, lines)
+	if ctx.Err() != nil {
+		_, reqErr := h.catalogFailure(ctx, ctx.Err())
+		return nil, false, nil, reqErr
+	}
+
+	unknown := 0
+	var rejected *requestError
+	for _, c := range checks {
+		switch c.Outcome {
+		case itemValid:
+		case itemUnknown:
+			unknown++
+		default:
+			if rejected == nil {
+				rejected = itemRejection(c)
+			}
+		}
+	}
+	switch {
+	case rejected != nil:
+		rejected.items = checks
+		return nil, false, nil, rejected
+	case unknown > h.maxUnknownItems && h.validationMode == validationLenient:
+		requestLogger(ctx).Warn("catalog unavailable, accepting order with unchecked items", "unknown", unknown)
+		return items, true, checks, nil
+	case unknown > h.maxUnknownItems:
+		requestLogger(ctx).Error("validating items", "unknown", unknown, "max_unknown", h.maxUnknownItems)
+		return nil, false, nil, &requestError{
+			status: http.StatusServiceUnavailable,
+			code:   CodeCatalogUnavailable,
+			msg:    fmt.Sprintf("food catalog could not check %d of %d items", unknown, len(lines)),
+			items:  checks,
+		}
+	}
+	requestLogger(ctx).Warn("catalog partly unavailable, accepting order with unchecked items", "unknown", unknown)
+	return items, false, checks, nil
+}
+
+// lookupEach checks every line on its own, at most h.catalogWorkers at a
+// time, returning the catalog entries of the valid items and an outcome
+// per line.
+func (h *handler) lookupEach(ctx context.Context, lines []LineItem) (map[string]catalogItem, []itemCheck) {
+	var mu sync.Mutex
+	items := make(map[string]catalogItem, len(lines))
+	checks := make([]itemCheck, len(lines))
+	now := h.now()
+	policy := h.itemPolicy.Load()
+	// Lookups must not cancel each other: one item failing is exactly what
+	// the others should survive.
+	var g errgroup.Group
+	g.SetLimit(h.catalogWorkers)
+	for i, li := range lines {
+		g.Go(func() error {
+			err := policy.check([]LineItem{li})
+			var found map[string]catalogItem
+			if err == nil {
+				found, err = h.catalog.ValidateItems(ctx, []string{li.ItemID})
+			}
+			if err == nil {
+				err = checkStock([]LineItem{li}, found)
+			}
+			if err == nil {
+				err = checkWindows([]LineItem{li}, found, now)
+			}
+			checks[i] = itemCheck{ItemID: li.ItemID, Outcome: itemValid}
+			if err != nil {
+				checks[i].Outcome, checks[i].Error = itemCheckOutcome(err)
+				return nil
+			}
+			mu.Lock()
+			items[li.ItemID] = found[li.ItemID]
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Wait()
+	return items, checks
+}
+
+// itemCheckOutcome classifies the error from checking a single item.
+// Anything that is not an answer about the item itself is unknown.
+func itemCheckOutcome(err error) (outcome, msg string) {
+	var invalid *invalidItemError
+	var unavailable *unavailableItemError
+	var short *insufficientStockError
+	var outside *outsideWindowError
+	var blocked *blockedItemError
+	switch {
+	case errors.As(err, &invalid):
+		return itemInvalid, invalid.Error()
+	case errors.As(err, &unavailable):
+		return itemUnavailable, unavailable.Error()
+	case errors.As(err, &short):
+		return itemInsufficientStock, short.Error()
+	case errors.As(err, &outside):
+		return itemOutsideWindow, outside.Error()
+	case errors.As(err, &blocked) && blocked.NotAllowed:
+		return itemNotAllowed, blocked.Error()
+	case errors.As(err, &blocked):
+		return itemBlocked, blocked.Error()
+	case errors.Is(err, errCatalogBadResponse):
+		return itemUnknown, "food catalog returned an invalid response"
+	default:
+		return itemUnknown, "food catalog service not available"
+	}
+}
+
+// itemRejection is the error checkItems would have returned for c alone.
+func itemRejection(c itemCheck) *requestError {
+	switch c.Outcome {
+	case itemInvalid:
+		return &requestError{status: http.StatusBadRequest, code: CodeInvalidItem, msg: c.Error}
+	case itemUnavailable:
+		return &requestError{status: http.StatusConflict, code: CodeItemUnavailable, msg: c.Error}
+	case itemOutsideWindow:
+		return &requestError{status: http.StatusConflict, code: CodeItemOutsideWindow, msg: c.Error}
+	case itemBlocked:
+		return &requestError{status: http.StatusConflict, code: CodeItemBlocked, msg: c.Error}
+	case itemNotAllowed:
+		return &requestError{status: http.StatusConflict, code: CodeItemNotAllowed, msg: c.Error}
+	default:
+		return &requestError{status: http.StatusConflict, code: CodeInsufficientStock, msg: c.Error}
+	}
+}
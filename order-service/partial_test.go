@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// partlyDownCatalog fails every multi-item lookup as an outage and answers
+// single items as follows: "1" is valid, "3" is unknown to the catalog and
+// anything else hits the outage.
+func partlyDownCatalog() *stubCatalog {
+	return &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		if len(ids) > 1 {
+			return nil, fmt.Errorf("%w: connection reset", errCatalogUnavailable)
+		}
+		switch ids[0] {
+		case "1":
+			return map[string]catalogItem{"1": {ID: "1", Price: 2.5, Available: true}}, nil
+		case "3":
+			return nil, &invalidItemError{ID: "3"}
+		default:
+			return nil, fmt.Errorf("%w: connection reset", errCatalogUnavailable)
+		}
+	}}
+}
+
+// orderOf returns a body ordering one of each item.
+func orderOf(ids ...string) string {
+	body := `{"customer_id":"c1","items":[`
+	for i, id := range ids {
+		if i > 0 {
+			body += ","
+		}
+		body += `{"item_id":"` + id + `","quantity":1}`
+	}
+	return body + `]}`
+}
+
+// outcomes maps each checked item id to its outcome.
+func outcomes(checks []itemCheck) map[string]string {
+	m := make(map[string]string, len(checks))
+	for _, c := range checks {
+		m[c.ItemID] = c.Outcome
+	}
+	return m
+}
+
+func TestCreateOrderPartialOutage(t *testing.T) {
+	h := newTestHandler(t, WithCatalog(partlyDownCatalog()), WithMaxUnknownItems(1))
+	routes := h.routes()
+
+	o := createTestOrder(t, routes, orderOf("1", "2"))
+	if got := outcomes(o.ItemChecks); got["1"] != itemValid || got["2"] != itemUnknown || len(got) != 2 {
+		t.Errorf("item_checks = %+v, want 1 valid and 2 unknown", o.ItemChecks)
+	}
+	if o.ValidationSkipped {
+		t.Error("order within the unknown-item allowance is flagged validation_skipped")
+	}
+
+	rec := serve(t, routes, http.MethodPost, "/orders", orderOf("1", "2", "4"))
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCatalogUnavailable)
+	if got := outcomes(decodeBody[errorResponse](t, rec).Items); got["2"] != itemUnknown || got["4"] != itemUnknown || got["1"] != itemValid {
+		t.Errorf("items = %v, want the per-item breakdown", got)
+	}
+
+	rec = serve(t, routes, http.MethodPost, "/orders", orderOf("1", "2", "3"))
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidItem)
+	if got := outcomes(decodeBody[errorResponse](t, rec).Items); got["3"] != itemInvalid {
+		t.Errorf("items = %v, want 3 reported invalid", got)
+	}
+
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want only the accepted one", n)
+	}
+}
+
+func TestCreateOrderPartialOutageLenient(t *testing.T) {
+	routes := newTestHandler(t, WithCatalog(partlyDownCatalog()), WithMaxUnknownItems(1), WithValidationMode(validationLenient)).routes()
+	o := createTestOrder(t, routes, orderOf("1", "2", "4"))
+	if !o.ValidationSkipped || len(o.ItemChecks) != 3 {
+		t.Errorf("order = %+v, want it accepted with validation_skipped and the breakdown", o)
+	}
+}
+
+// Without an allowance an outage fails the order as before, with no
+// per-item lookups.
+func TestCreateOrderOutageWithoutAllowance(t *testing.T) {
+	catalog := partlyDownCatalog()
+	routes := newTestHandler(t, WithCatalog(catalog)).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders", orderOf("1", "2"))
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCatalogUnavailable)
+	if items := decodeBody[errorResponse](t, rec).Items; len(items) != 0 {
+		t.Errorf("items = %+v, want none", items)
+	}
+	if n := catalog.callCount(); n != 1 {
+		t.Errorf("catalog called %d times, want 1", n)
+	}
+}
+
+func TestResolveMaxUnknownItems(t *testing.T) {
+	t.Setenv("CATALOG_MAX_UNKNOWN_ITEMS", "")
+	if n, err := resolveMaxUnknownItems(); n != 0 || err != nil {
+		t.Errorf("default = %d, %v; want 0", n, err)
+	}
+	t.Setenv("CATALOG_MAX_UNKNOWN_ITEMS", "2")
+	if n, err := resolveMaxUnknownItems(); n != 2 || err != nil {
+		t.Errorf("2 = %d, %v", n, err)
+	}
+	for _, v := range []string{"-1", "some"} {
+		t.Setenv("CATALOG_MAX_UNKNOWN_ITEMS", v)
+		if _, err := resolveMaxUnknownItems(); err == nil {
+			t.Errorf("CATALOG_MAX_UNKNOWN_ITEMS=%q accepted", v)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// resolvePrettyJSON reads PRETTY_JSON, which makes indented output the
+// default for requests that do not pass ?pretty themselves.
+func resolvePrettyJSON() (bool, error) {
+	v := setting("PRETTY_JSON")
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid PRETTY_JSON %q: must be true or false", v)
+	}
+	return b, nil
+}
+
+// prettyJSON indents JSON responses for requests with ?pretty=true, or for
+// every request without ?pretty when byDefault is set. Other responses pass
+// through untouched.
+func prettyJSON(byDefault bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pretty := byDefault
+			if v := r.URL.Query().Get("pretty"); v != "" {
+				pretty, _ = strconv.ParseBool(v)
+			}
+			if !pretty {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pw := &prettyWriter{ResponseWriter: w}
+			next.ServeHTTP(pw, r)
+			pw.finish()
+		})
+	}
+}
+
+// prettyWriter buffers a JSON response so it can be re-indented once the
+// handler is done.
+type prettyWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	isJSON      bool
+	buf         bytes.Buffer
+}
+
+func (pw *prettyWriter) WriteHeader(status int) {
+	if pw.wroteHeader {
+		return
+	}
+	pw.wroteHeader = true
+	pw.status = status
+	mediaType, _, _ := mime.ParseMediaType(pw.Header().Get("Content-Type"))
+	pw.isJSON = mediaType == "application/json" || mediaType == problemContentType
+	if !pw.isJSON {
+		pw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (pw *prettyWriter) Write(p []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.isJSON {
+		return pw.buf.Write(p)
+	}
+	return pw.ResponseWriter.Write(p)
+}
+
+func (pw *prettyWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+func (pw *prettyWriter) finish() {
+	if !pw.isJSON {
+		return
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, pw.buf.Bytes(), "", "  "); err != nil {
+		out = pw.buf
+	}
+	pw.Header().Del("Content-Length")
+	pw.ResponseWriter.WriteHeader(pw.status)
+	pw.ResponseWriter.Write(out.Bytes())
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodGet, "/version?pretty=true", "")
+	wantStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "{\n  \"") {
+		t.Errorf("?pretty=true body is not indented:\n%s", rec.Body)
+	}
+	decodeBody[map[string]string](t, rec)
+
+	rec = serve(t, routes, http.MethodGet, "/version", "")
+	if strings.Contains(rec.Body.String(), "\n  ") {
+		t.Errorf("body is indented without ?pretty:\n%s", rec.Body)
+	}
+}
+
+func TestPrettyJSONByDefault(t *testing.T) {
+	routes := newTestHandler(t, WithPrettyJSON(true)).routes()
+	rec := serve(t, routes, http.MethodGet, "/orders/missing", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+	if !strings.Contains(rec.Body.String(), "\n  ") {
+		t.Errorf("error body is not indented:\n%s", rec.Body)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/version?pretty=false", "")
+	if strings.Contains(rec.Body.String(), "\n  ") {
+		t.Errorf("?pretty=false body is indented:\n%s", rec.Body)
+	}
+}
+
+func TestPrettyJSONLeavesOtherContentAlone(t *testing.T) {
+	h := prettyJSON(true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, `{"a":1}`)
+	}))
+	rec := serve(t, h, http.MethodGet, "/", "")
+	wantStatus(t, rec, http.StatusAccepted)
+	if rec.Body.String() != `{"a":1}` {
+		t.Errorf("body = %q, want it untouched", rec.Body)
+	}
+}
+
+func TestResolvePrettyJSON(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "true": true, "0": false} {
+		t.Setenv("PRETTY_JSON", v)
+		if got, err := resolvePrettyJSON(); got != want || err != nil {
+			t.Errorf("PRETTY_JSON=%q = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	t.Setenv("PRETTY_JSON", "yes")
+	if _, err := resolvePrettyJSON(); err == nil {
+		t.Error("PRETTY_JSON=yes accepted")
+	}
+}
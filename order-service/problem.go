@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error response formats selectable with ERROR_FORMAT.
+const (
+	// errorFormatSimple is the {"error","status","code"} envelope.
+	errorFormatSimple = "simple"
+	// errorFormatProblem is RFC 7807 application/problem+json.
+	errorFormatProblem = "problem"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix starts every problem type URI; the error code follows
+// in lower case, e.g. urn:order-service:error:order-not-found.
+const problemTypePrefix = "urn:order-service:error:"
+
+// resolveErrorFormat reads ERROR_FORMAT: "simple" by default, or "problem".
+func resolveErrorFormat() (string, error) {
+	switch v := setting("ERROR_FORMAT"); v {
+	case "", errorFormatSimple:
+		return errorFormatSimple, nil
+	case errorFormatProblem:
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid ERROR_FORMAT %q: must be simple or problem", v)
+	}
+}
+
+// problemType maps an error code onto its stable type URI.
+func problemType(code string) string {
+	return problemTypePrefix + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}
+
+// problemJSON rewrites every error envelope written by the handlers into an
+// RFC 7807 problem document. The code, details and any other members are
+// kept as extension members. Other responses pass through untouched.
+func problemJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pw := &problemWriter{ResponseWriter: w, instance: r.URL.Path}
+		next.ServeHTTP(pw, r)
+		pw.finish()
+	})
+}
+
+// problemWriter holds back error responses so they can be converted once
+// the handler is done.
+type problemWriter struct {
+	http.ResponseWriter
+	instance    string
+	status      int
+	wroteHeader bool
+	isError     bool
+	buf         bytes.Buffer
+}
+
+func (pw *problemWriter) WriteHeader(status int) {
+	if pw.wroteHeader {
+		return
+	}
+	pw.wroteHeader = true
+	pw.status = status
+	mediaType, _, _ := mime.ParseMediaType(pw.Header().Get("Content-Type"))
+	pw.isError = status >= 400 && mediaType == "application/json"
+	if !pw.isError {
+		pw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (pw *problemWriter) Write(p []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.isError {
+		return pw.buf.Write(p)
+	}
+	return pw.ResponseWriter.Write(p)
+}
+
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+func (pw *problemWriter) finish() {
+	if !pw.isError {
+		return
+	}
+	body := pw.buf.Bytes()
+	if problem, ok := toProblem(body, pw.status, pw.instance); ok {
+		pw.Header().Set("Content-Type", problemContentType)
+		body = problem
+	}
+	pw.Header().Del("Content-Length")
+	pw.ResponseWriter.WriteHeader(pw.status)
+	pw.ResponseWriter.Write(body)
+}
+
+// toProblem converts an errorResponse-shaped body, reporting false for
+// anything else.
+func toProblem(body []byte, status int, instance string) ([]byte, bool) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, false
+	}
+	var msg, code string
+	if json.Unmarshal(members["error"], &msg) != nil || json.Unmarshal(members["code"], &code) != nil {
+		return nil, false
+	}
+	delete(members, "error")
+	delete(members, "status")
+
+	set := func(name string, v any) {
+		b, _ := json.Marshal(v)
+		members[name] = b
+	}
+	title := http.StatusText(status)
+	if title == "" {
+		// Non-standard codes such as 499 have no text.
+		title = "Error " + strconv.Itoa(status)
+	}
+	set("type", problemType(code))
+	set("title", title)
+	set("status", status)
+	set("detail", msg)
+	set("instance", instance)
+	out, err := json.Marshal(members)
+	if err != nil {
+		return nil, false
+	}
+	return append(out, '\n'), true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// problemDoc is an RFC 7807 body with the extension members this service
+// adds.
+type problemDoc struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     ErrorCode    `json:"code"`
+	Details  []fieldError `json:"details"`
+	Error    *string      `json:"error"`
+}
+
+func TestProblemJSONErrors(t *testing.T) {
+	routes := newTestHandler(t, WithErrorFormat(errorFormatProblem)).routes()
+
+	rec := serve(t, routes, http.MethodGet, "/orders/missing", "")
+	wantStatus(t, rec, http.StatusNotFound)
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q, want %s", ct, problemContentType)
+	}
+	p := decodeBody[problemDoc](t, rec)
+	if p.Type != "urn:order-service:error:order-not-found" || p.Title != "Not Found" || p.Status != http.StatusNotFound ||
+		p.Detail == "" || p.Instance != "/orders/missing" || p.Code != CodeOrderNotFound {
+		t.Errorf("404 problem = %+v", p)
+	}
+	if p.Error != nil {
+		t.Errorf("problem still carries the simple error member %q", *p.Error)
+	}
+
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"","items":[{"item_id":"1","quantity":1}]}`)
+	wantStatus(t, rec, http.StatusBadRequest)
+	if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q, want %s", ct, problemContentType)
+	}
+	p = decodeBody[problemDoc](t, rec)
+	if p.Type != "urn:order-service:error:validation-failed" || p.Status != http.StatusBadRequest || len(p.Details) != 1 || p.Details[0].Field != "customer_id" {
+		t.Errorf("400 problem = %+v", p)
+	}
+
+	// Successful responses are left alone.
+	rec = serve(t, routes, http.MethodPost, "/orders", simpleOrder)
+	wantStatus(t, rec, http.StatusCreated)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("success Content-Type = %q", ct)
+	}
+}
+
+func TestSimpleErrorsByDefault(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodGet, "/orders/missing", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+}
+
+func TestToProblemNonStandardStatus(t *testing.T) {
+	out, ok := toProblem([]byte(`{"error":"request cancelled","status":499,"code":"REQUEST_CANCELLED"}`), statusClientClosedRequest, "/orders")
+	if !ok {
+		t.Fatal("toProblem rejected an error envelope")
+	}
+	if got := string(out); !strings.Contains(got, `"title":"Error 499"`) {
+		t.Errorf("problem = %s, want a title for 499", got)
+	}
+	if _, ok := toProblem([]byte(`{"status":"ok"}`), http.StatusBadRequest, "/"); ok {
+		t.Error("toProblem converted a body that is not an error envelope")
+	}
+}
+
+func TestResolveErrorFormat(t *testing.T) {
+	for v, want := range map[string]string{"": errorFormatSimple, "simple": errorFormatSimple, "problem": errorFormatProblem} {
+		t.Setenv("ERROR_FORMAT", v)
+		if got, err := resolveErrorFormat(); got != want || err != nil {
+			t.Errorf("ERROR_FORMAT=%q = %q, %v; want %q", v, got, err, want)
+		}
+	}
+	t.Setenv("ERROR_FORMAT", "jsonapi")
+	if _, err := resolveErrorFormat(); err == nil {
+		t.Error("ERROR_FORMAT=jsonapi accepted")
+	}
+}
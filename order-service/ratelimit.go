@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultRateLimitRPS = 10
+	// idleLimiterTTL is how long a client's limiter is kept after its last
+	// request; older limiters are swept so memory stays bounded.
+	idleLimiterTTL = 10 * time.Minute
+)
+
+// rateLimiter hands out one token bucket per client.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rps       rate.Limit
+	burst     int
+	now       func() time.Time
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rps:       rate.Limit(rps),
+		burst:     burst,
+		now:       time.Now,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// rateLimitStatus is a client's token bucket after a request, as reported
+// in the X-RateLimit-* headers.
+type rateLimitStatus struct {
+	// retryAfter is how long the client must wait before retrying, or zero
+	// if the request may proceed.
+	retryAfter time.Duration
+	limit      int
+	remaining  int
+	// reset is how long until the bucket is full again.
+	reset time.Duration
+}
+
+// reserve takes a token for key and reports the client's bucket afterwards.
+func (l *rateLimiter) reserve(key string) rateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > idleLimiterTTL {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleLimiterTTL {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	status := rateLimitStatus{limit: l.burst}
+	res := c.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		status.retryAfter = delay
+	}
+	tokens := max(0, c.limiter.TokensAt(now))
+	status.remaining = int(tokens)
+	if missing := float64(l.burst) - tokens; missing > 0 && l.rps > 0 {
+		status.reset = time.Duration(missing / float64(l.rps) * float64(time.Second))
+	}
+	return status
+}
+
+// middleware rejects clients that exceed their rate with 429. Clients are
+// identified by API key when byKey is set (keys have already been checked
+// by apiKeyMiddleware), otherwise by remote IP. Every response carries
+// X-RateLimit-Limit (the burst), X-RateLimit-Remaining (requests that can
+// be made right now) and X-RateLimit-Reset (seconds until the allowance is
+// full again), so clients can slow down before they are turned away.
+func (l *rateLimiter) middleware(byKey bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := l.reserve(clientKey(r, byKey))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(status.limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(status.reset.Seconds()))))
+			if delay := status.retryAfter; delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				writeJSONError(w, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func clientKey(r *http.Request, byKey bool) string {
+	if byKey {
+		if key, ok := bearerToken(r); ok {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// resolveRateLimit reads RATE_LIMIT_RPS (default 10, 0 disables) and
+// RATE_LIMIT_BURST (default twice the rate).
+func resolveRateLimit() (rps float64, burst int, err error) {
+	rps = defaultRateLimitRPS
+	if v := setting("RATE_LIMIT_RPS"); v != "" {
+		rps, err = strconv.ParseFloat(v, 64)
+		if err != nil || rps < 0 {
+			return 0, 0, fmt.Errorf("invalid RATE_LIMIT_RPS %q: must be a non-negative number", v)
+		}
+	}
+	burst = max(1, int(math.Ceil(rps*2)))
+	if v := setting("RATE_LIMIT_BURST"); v != "" {
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst < 1 {
+			return 0, 0, fmt.Errorf("invalid RATE_LIMIT_BURST %q: must be a positive integer", v)
+		}
+	}
+	return rps, burst, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// limited wraps an OK handler in l's middleware.
+func limited(l *rateLimiter, byKey bool) http.Handler {
+	return l.middleware(byKey)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+// sendFrom sends a GET from remoteAddr with the given bearer token, if any.
+func sendFrom(h http.Handler, remoteAddr, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.RemoteAddr = remoteAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	now := testTime
+	l := newRateLimiter(1, 2)
+	l.now = func() time.Time { return now }
+	h := limited(l, false)
+
+	for range 2 {
+		wantStatus(t, sendFrom(h, "10.0.0.1:5000", ""), http.StatusOK)
+	}
+	rec := sendFrom(h, "10.0.0.1:5001", "")
+	wantError(t, rec, http.StatusTooManyRequests, CodeRateLimited)
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+
+	// Another client has its own bucket, and the first refills with time.
+	wantStatus(t, sendFrom(h, "10.0.0.2:5000", ""), http.StatusOK)
+	now = now.Add(time.Second)
+	wantStatus(t, sendFrom(h, "10.0.0.1:5000", ""), http.StatusOK)
+}
+
+func TestRateLimitByAPIKey(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	l.now = func() time.Time { return testTime }
+	h := limited(l, true)
+
+	wantStatus(t, sendFrom(h, "10.0.0.1:5000", "k1"), http.StatusOK)
+	// Same key from another address shares the bucket...
+	wantStatus(t, sendFrom(h, "10.0.0.2:5000", "k1"), http.StatusTooManyRequests)
+	// ...and another key behind the same address does not.
+	wantStatus(t, sendFrom(h, "10.0.0.1:5000", "k2"), http.StatusOK)
+}
+
+func TestRateLimiterForgetsIdleClients(t *testing.T) {
+	now := testTime
+	l := newRateLimiter(1, 1)
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+	l.reserve("ip:10.0.0.1")
+	now = now.Add(idleLimiterTTL + time.Second)
+	l.reserve("ip:10.0.0.2")
+	if _, ok := l.clients["ip:10.0.0.1"]; ok || len(l.clients) != 1 {
+		t.Errorf("clients = %v, want only the recent one", l.clients)
+	}
+}
+
+func TestResolveRateLimit(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "")
+	t.Setenv("RATE_LIMIT_BURST", "")
+	if rps, burst, err := resolveRateLimit(); rps != defaultRateLimitRPS || burst != 20 || err != nil {
+		t.Errorf("default = %v, %d, %v", rps, burst, err)
+	}
+	t.Setenv("RATE_LIMIT_RPS", "0.5")
+	if rps, burst, err := resolveRateLimit(); rps != 0.5 || burst != 1 || err != nil {
+		t.Errorf("0.5 = %v, %d, %v; want the burst to be at least 1", rps, burst, err)
+	}
+	t.Setenv("RATE_LIMIT_BURST", "7")
+	if _, burst, _ := resolveRateLimit(); burst != 7 {
+		t.Errorf("RATE_LIMIT_BURST=7 gave %d", burst)
+	}
+	for env, v := range map[string]string{"RATE_LIMIT_RPS": "-1", "RATE_LIMIT_BURST": "0"} {
+		t.Setenv(env, v)
+		if _, _, err := resolveRateLimit(); err == nil {
+			t.Errorf("%s=%s accepted", env, v)
+		}
+	}
+}
+
+func TestRateLimitMountedOnOrderRoutes(t *testing.T) {
+	routes := newTestHandler(t, WithRateLimit(1, 1)).routes()
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusOK)
+	wantError(t, serve(t, routes, http.MethodGet, "/orders", ""), http.StatusTooManyRequests, CodeRateLimited)
+	wantStatus(t, serve(t, routes, http.MethodGet, "/health", ""), http.StatusOK)
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	now := testTime
+	l := newRateLimiter(1, 3)
+	l.now = func() time.Time { return now }
+	h := limited(l, false)
+
+	for _, want := range []struct {
+		status                  int
+		limit, remaining, reset string
+	}{
+		{http.StatusOK, "3", "2", "1"},
+		{http.StatusOK, "3", "1", "2"},
+		{http.StatusOK, "3", "0", "3"},
+		{http.StatusTooManyRequests, "3", "0", "3"},
+	} {
+		rec := sendFrom(h, "10.0.0.1:5000", "")
+		wantStatus(t, rec, want.status)
+		got := rec.Header()
+		if got.Get("X-RateLimit-Limit") != want.limit || got.Get("X-RateLimit-Remaining") != want.remaining || got.Get("X-RateLimit-Reset") != want.reset {
+			t.Errorf("headers = limit %s, remaining %s, reset %s; want %s, %s, %s",
+				got.Get("X-RateLimit-Limit"), got.Get("X-RateLimit-Remaining"), got.Get("X-RateLimit-Reset"),
+				want.limit, want.remaining, want.reset)
+		}
+	}
+
+	// The bucket refills at RATE_LIMIT_RPS.
+	now = now.Add(2 * time.Second)
+	rec := sendFrom(h, "10.0.0.1:5000", "")
+	wantStatus(t, rec, http.StatusOK)
+	if got := rec.Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Errorf("X-RateLimit-Remaining after refilling = %q, want 1", got)
+	}
+	if got := sendFrom(h, "10.0.0.2:5000", "").Header().Get("X-RateLimit-Remaining"); got != "2" {
+		t.Errorf("another client's X-RateLimit-Remaining = %q, want 2", got)
+	}
+}
+
+func TestRateLimitHeadersOnlyOnLimitedRoutes(t *testing.T) {
+	routes := newTestHandler(t, WithRateLimit(1, 5)).routes()
+	if got := serve(t, routes, http.MethodGet, "/orders", "").Header().Get("X-RateLimit-Remaining"); got != "4" {
+		t.Errorf("/orders X-RateLimit-Remaining = %q, want 4", got)
+	}
+	if got := serve(t, routes, http.MethodGet, "/health", "").Header().Get("X-RateLimit-Limit"); got != "" {
+		t.Errorf("/health X-RateLimit-Limit = %q, want none", got)
+	}
+}
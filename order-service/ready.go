@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// pinger is implemented by stores that can verify their backing connection.
+type pinger interface {
+	Ping() error
+}
+
+// readinessFailures runs each readiness check and returns the names of the
+// ones that failed.
+func (h *handler) readinessFailures(ctx context.Context) []string {
+	failed := []string{}
+	if h.maintenance.Load() {
+		failed = append(failed, "maintenance")
+	}
+	if err := h.checkStore(); err != nil {
+		requestLogger(ctx).Warn("readiness check failed", "check", "store", "error", err)
+		failed = append(failed, "store")
+	}
+	if err := h.catalog.Ping(ctx); err != nil {
+		requestLogger(ctx).Warn("readiness check failed", "check", "catalog", "error", err)
+		failed = append(failed, "catalog")
+	}
+	return failed
+}
+
+func (h *handler) checkStore() error {
+	if h.store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	if p, ok := h.store.(pinger); ok {
+		return p.Ping()
+	}
+	return nil
+}
+
+// healthDetail summarises dependency health for /health/detail.
+type healthDetail struct {
+	Status         string        `json:"status"`
+	Store          storeHealth   `json:"store"`
+	Catalog        catalogHealth `json:"catalog"`
+	CatalogBreaker string        `json:"catalog_breaker"`
+}
+
+type storeHealth struct {
+	Reachable bool `json:"reachable"`
+}
+
+type catalogHealth struct {
+	Reachable bool  `json:"reachable"`
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+func (h *handler) healthDetail(ctx context.Context) healthDetail {
+	d := healthDetail{Status: "ok", CatalogBreaker: catalogBreaker.State().String()}
+	d.Store.Reachable = h.checkStore() == nil
+
+	start := time.Now()
+	err := h.catalog.Ping(ctx)
+	d.Catalog.LatencyMS = time.Since(start).Milliseconds()
+	d.Catalog.Reachable = err == nil
+
+	if !d.Store.Reachable || !d.Catalog.Reachable {
+		d.Status = "degraded"
+	}
+	return d
+}
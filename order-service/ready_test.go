@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestReadyWhenDependenciesAnswer(t *testing.T) {
+	routes := newTestHandler(t, WithCatalog(&stubCatalog{})).routes()
+	rec := serve(t, routes, http.MethodGet, "/ready", "")
+	wantStatus(t, rec, http.StatusOK)
+	if body := decodeBody[map[string]string](t, rec); body["status"] != "ready" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+// A catalog outage takes the pod out of rotation without failing liveness.
+func TestReadyReportsFailedChecks(t *testing.T) {
+	routes := newTestHandler(t, WithCatalog(&stubCatalog{pingErr: errors.New("refused")})).routes()
+
+	rec := serve(t, routes, http.MethodGet, "/ready", "")
+	wantStatus(t, rec, http.StatusServiceUnavailable)
+	body := decodeBody[struct {
+		Status string   `json:"status"`
+		Failed []string `json:"failed"`
+	}](t, rec)
+	if body.Status != "not ready" || !slices.Equal(body.Failed, []string{"catalog"}) {
+		t.Errorf("body = %+v, want not ready with the catalog failed", body)
+	}
+
+	wantStatus(t, serve(t, routes, http.MethodGet, "/health", ""), http.StatusOK)
+}
+
+// failingPingStore is a store whose connection check fails.
+type failingPingStore struct{ *MemoryStore }
+
+func (failingPingStore) Ping() error { return errors.New("database is locked") }
+
+func TestReadyChecksStore(t *testing.T) {
+	catalog, _ := newMockCatalog("1")
+	routes := newHandler(failingPingStore{NewMemoryStore()}, WithCatalog(catalog)).routes()
+	rec := serve(t, routes, http.MethodGet, "/ready", "")
+	wantStatus(t, rec, http.StatusServiceUnavailable)
+	if failed := decodeBody[struct{ Failed []string }](t, rec).Failed; !slices.Equal(failed, []string{"store"}) {
+		t.Errorf("failed = %v, want [store]", failed)
+	}
+}
+
+func TestHealthDetail(t *testing.T) {
+	withFreshBreaker(t)
+	routes := newTestHandler(t, WithCatalog(&stubCatalog{})).routes()
+	rec := serve(t, routes, http.MethodGet, "/health/detail", "")
+	wantStatus(t, rec, http.StatusOK)
+	d := decodeBody[healthDetail](t, rec)
+	if d.Status != "ok" || !d.Store.Reachable || !d.Catalog.Reachable || d.CatalogBreaker != "closed" {
+		t.Errorf("detail = %+v, want everything healthy", d)
+	}
+}
+
+// The detail endpoint reports trouble in its body but stays 200, since it
+// is for dashboards rather than probes.
+func TestHealthDetailDegraded(t *testing.T) {
+	routes := newHandler(failingPingStore{NewMemoryStore()}, WithCatalog(&stubCatalog{pingErr: errors.New("refused")})).routes()
+	rec := serve(t, routes, http.MethodGet, "/health/detail", "")
+	wantStatus(t, rec, http.StatusOK)
+	d := decodeBody[healthDetail](t, rec)
+	if d.Status != "degraded" || d.Store.Reachable || d.Catalog.Reachable {
+		t.Errorf("detail = %+v, want degraded with both dependencies down", d)
+	}
+}
+
+func TestKubernetesProbes(t *testing.T) {
+	routes := newTestHandler(t, WithCatalog(&stubCatalog{}), WithAPIKeys([]string{"k1"})).routes()
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rec := serve(t, routes, http.MethodGet, path, "")
+		wantStatus(t, rec, http.StatusOK)
+		if body := decodeBody[map[string]string](t, rec); body["status"] != "ok" {
+			t.Errorf("%s body = %v", path, body)
+		}
+	}
+}
+
+// /healthz stays up through a catalog outage so the pod is not restarted,
+// while /readyz takes it out of rotation.
+func TestReadyzReportsFailedChecks(t *testing.T) {
+	routes := newTestHandler(t, WithCatalog(&stubCatalog{pingErr: errors.New("refused")})).routes()
+	wantStatus(t, serve(t, routes, http.MethodGet, "/healthz", ""), http.StatusOK)
+
+	rec := serve(t, routes, http.MethodGet, "/readyz", "")
+	wantStatus(t, rec, http.StatusServiceUnavailable)
+	body := decodeBody[struct {
+		Status string   `json:"status"`
+		Failed []string `json:"failed"`
+	}](t, rec)
+	if body.Status != "unavailable" || !slices.Equal(body.Failed, []string{"catalog"}) {
+		t.Errorf("body = %+v", body)
+	}
+}
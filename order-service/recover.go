@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverMiddleware turns handler panics into a plain 500 JSON error. The
+// stack trace is logged with the request ID but never sent to the client.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Let net/http abort the connection as the handler intended.
+				panic(rec)
+			}
+			requestLogger(r.Context()).Error("panic serving request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", rec,
+				"stack", string(debug.Stack()),
+			)
+			writeJSONError(w, http.StatusInternalServerError, CodeInternal, "internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecoverMiddleware(t *testing.T) {
+	logs := captureLogs(t)
+	h := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map write")
+	}))
+	rec := serve(t, h, http.MethodGet, "/orders", "")
+	wantError(t, rec, http.StatusInternalServerError, CodeInternal)
+	if strings.Contains(rec.Body.String(), "nil map") {
+		t.Errorf("panic value leaked to the client: %s", rec.Body)
+	}
+	if !strings.Contains(logs.String(), "panic serving request") || !strings.Contains(logs.String(), "nil map write") {
+		t.Errorf("panic was not logged:\n%s", logs)
+	}
+}
+
+func TestRecoverMiddlewareRepanicsOnAbort(t *testing.T) {
+	h := recoverMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	serve(t, h, http.MethodGet, "/orders", "")
+	t.Fatal("ErrAbortHandler was swallowed")
+}
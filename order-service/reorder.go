@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// reorderOrder answers POST /orders/{id}/reorder by placing a new order
+// with the items, customer, notes and tags of an earlier one. The items are
+// validated and priced against the current catalog; if any can no longer
+// be ordered the answer is a 409 listing them.
+func (h *handler) reorderOrder(w http.ResponseWriter, r *http.Request) {
+	original, err := h.activeOrder(chi.URLParam(r, "id"))
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+
+	created, reqErr := h.placeOrder(r.Context(), Order{
+		CustomerID: original.CustomerID,
+		Items:      slices.Clone(original.Items),
+		Notes:      original.Notes,
+		Tags:       slices.Clone(original.Tags),
+	})
+	if reqErr != nil {
+		if _, itemLevel := itemOutcomes[reqErr.code]; itemLevel {
+			reqErr = h.reorderConflict(r, original, reqErr)
+		}
+		writeRequestError(w, reqErr)
+		return
+	}
+	w.Header().Set("Location", "/orders/"+url.PathEscape(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// reorderConflict turns an item-level rejection of a reorder into a 409
+// that lists every item of original that can no longer be ordered, not
+// just the first one the catalog turned down.
+func (h *handler) reorderConflict(r *http.Request, original Order, reqErr *requestError) *requestError {
+	checks := reqErr.items
+	if checks == nil {
+		_, checks = h.lookupEach(r.Context(), original.Items)
+	}
+	var bad []itemCheck
+	for _, c := range checks {
+		if c.Outcome != itemValid {
+			bad = append(bad, c)
+		}
+	}
+	if len(bad) == 0 {
+		// The catalog changed between the two lookups; report what the
+		// reorder was turned down for.
+		return &requestError{status: http.StatusConflict, code: reqErr.code, msg: reqErr.msg}
+	}
+	return &requestError{
+		status: http.StatusConflict,
+		code:   CodeItemUnavailable,
+		msg:    fmt.Sprintf("%d of %d items of order %s can no longer be ordered", len(bad), len(original.Items), original.ID),
+		items:  bad,
+	}
+}
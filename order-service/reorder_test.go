@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestReorderOrder(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	original := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":2},{"item_id":"2","quantity":1}],"notes":"no onions","tags":["regular"]}`)
+	wantStatus(t, serve(t, routes, http.MethodPatch, "/orders/"+original.ID+"/status", `{"status":"preparing"}`), http.StatusOK)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+original.ID+"/reorder", "")
+	wantStatus(t, rec, http.StatusCreated)
+	o := decodeBody[Order](t, rec)
+	if o.ID == original.ID || o.OrderNumber == original.OrderNumber {
+		t.Errorf("reorder reused id %s / number %d", o.ID, o.OrderNumber)
+	}
+	if o.Status != StatusReceived || o.CustomerID != "c1" || o.Notes != "no onions" || !slices.Equal(o.Tags, []string{"regular"}) {
+		t.Errorf("reorder = %+v, want a received copy of the original", o)
+	}
+	if len(o.Items) != 2 || o.Items[0].Quantity != 2 || o.TotalCents != original.TotalCents {
+		t.Errorf("items = %+v, total %d; want the original's", o.Items, o.TotalCents)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/orders/"+o.ID {
+		t.Errorf("Location = %q", loc)
+	}
+	if n := len(h.store.All()); n != 2 {
+		t.Errorf("store holds %d orders, want 2", n)
+	}
+}
+
+func TestReorderDiscontinuedItem(t *testing.T) {
+	var reject string
+	h := newTestHandler(t, WithCatalog(switchableCatalog(&reject)))
+	routes := h.routes()
+	original := createTestOrder(t, routes, orderOf("1", "2", "3"))
+
+	reject = "2"
+	rec := serve(t, routes, http.MethodPost, "/orders/"+original.ID+"/reorder", "")
+	wantError(t, rec, http.StatusConflict, CodeItemUnavailable)
+	if got := outcomes(decodeBody[errorResponse](t, rec).Items); len(got) != 1 || got["2"] != itemInvalid {
+		t.Errorf("items = %v, want only 2 listed", got)
+	}
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want only the original", n)
+	}
+}
+
+func TestReorderMissingOrder(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	wantError(t, serve(t, routes, http.MethodPost, "/orders/missing/reorder", ""), http.StatusNotFound, CodeOrderNotFound)
+
+	deleted := createTestOrder(t, routes, simpleOrder)
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/"+deleted.ID, ""), http.StatusNoContent)
+	wantError(t, serve(t, routes, http.MethodPost, "/orders/"+deleted.ID+"/reorder", ""), http.StatusNotFound, CodeOrderNotFound)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// statusClientClosedRequest is the non-standard status (from nginx) logged
+// when the client disconnects before the response is ready.
+const statusClientClosedRequest = 499
+
+// errorResponse is the JSON envelope returned for every failed request.
+type errorResponse struct {
+	Error  string    `json:"error"`
+	Status int       `json:"status"`
+	Code   ErrorCode `json:"code"`
+	// Details lists every field-level problem for VALIDATION_FAILED.
+	Details []fieldError `json:"details,omitempty"`
+	// Items is the per-item breakdown when items were checked one by one.
+	Items []itemCheck `json:"items,omitempty"`
+}
+
+// requestError is a failure that maps directly onto an HTTP error response.
+type requestError struct {
+	status int
+	code   ErrorCode
+	msg    string
+	// details carries per-field problems for validation failures.
+	details []fieldError
+	// items carries the per-item outcomes of a partial catalog outage.
+	items []itemCheck
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func writeRequestError(w http.ResponseWriter, err *requestError) {
+	writeJSON(w, err.status, errorResponse{Error: err.msg, Status: err.status, Code: err.code, Details: err.details, Items: err.items})
+}
+
+// statusFromError maps the errors returned by the order store onto HTTP
+// statuses; anything it does not recognise is a 500.
+func statusFromError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlreadyExists), errors.Is(err, ErrInvalidTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// storeError turns an error from the order store into the response for it.
+// A *requestError returned by an Update change is passed through as is.
+// Unrecognised errors are logged and reported as a failure to action, e.g.
+// "save order", without leaking their detail to the client.
+func storeError(ctx context.Context, err error, action string) *requestError {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr
+	}
+	status := statusFromError(err)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return &requestError{status: status, code: CodeOrderNotFound, msg: "order not found"}
+	case errors.Is(err, ErrAlreadyExists):
+		return &requestError{status: status, code: CodeOrderExists, msg: err.Error()}
+	case errors.Is(err, ErrInvalidTransition):
+		return &requestError{status: status, code: CodeInvalidTransition, msg: err.Error()}
+	}
+	requestLogger(ctx).Error("order store failed", "action", action, "error", err)
+	return &requestError{status: status, code: CodeInternal, msg: "failed to " + action}
+}
+
+// validationFailed turns an error from the validate functions into a 400,
+// keeping the individual field errors when there are any.
+func validationFailed(err error) *requestError {
+	reqErr := &requestError{status: http.StatusBadRequest, code: CodeValidationFailed, msg: err.Error()}
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		reqErr.details = errs
+	}
+	return reqErr
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, code ErrorCode, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg, Status: status, Code: code})
+}
+
+// decodeJSONBody decodes a JSON request body into v, rejecting other media
+// types and unknown fields. On failure it writes the error response itself
+// and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeJSONError(w, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "Content-Type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			writeJSONError(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body too large")
+		case errors.Is(err, io.EOF):
+			writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "request body is empty")
+		default:
+			writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		}
+		return false
+	}
+	// dec.More() misses a stray closing bracket, so decode once more and
+	// require a clean EOF instead.
+	if err := dec.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, CodeInvalidRequest, "request body must contain a single JSON value")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSONError(rec, http.StatusConflict, CodeOrderExists, "order already exists")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	wantError(t, rec, http.StatusConflict, CodeOrderExists)
+	if body := decodeBody[errorResponse](t, rec); body.Error != "order already exists" {
+		t.Errorf("error = %q", body.Error)
+	}
+}
+
+func TestMalformedBodyGetsJSONError(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	for name, body := range map[string]string{
+		"syntax":        `{"customer_id":`,
+		"unknown field": `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"colour":"red"}`,
+		"trailing data": simpleOrder + `}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(t, routes, http.MethodPost, "/orders", body)
+			wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+		})
+	}
+}
+
+func TestOversizedBodyRejected(t *testing.T) {
+	h := newTestHandler(t, WithMaxBodyBytes(int64(len(simpleOrder))))
+	routes := h.routes()
+	wantStatus(t, serve(t, routes, http.MethodPost, "/orders", simpleOrder), http.StatusCreated)
+
+	big := `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"notes":"` + strings.Repeat("x", 64) + `"}`
+	rec := serve(t, routes, http.MethodPost, "/orders", big)
+	wantError(t, rec, http.StatusRequestEntityTooLarge, CodeBodyTooLarge)
+}
+
+func TestNonJSONBodyRejected(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	for _, ct := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder, "Content-Type", ct)
+		wantError(t, rec, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType)
+	}
+	rec := serve(t, routes, http.MethodPost, "/orders", simpleOrder, "Content-Type", "application/json; charset=utf-8")
+	wantStatus(t, rec, http.StatusCreated)
+}
+
+func TestEmptyBodyRejected(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodPost, "/orders", "", "Content-Type", "application/json")
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	if body := decodeBody[errorResponse](t, rec); body.Error != "request body is empty" {
+		t.Errorf("error = %q", body.Error)
+	}
+}
+
+func TestTrailingDataRejected(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodPost, "/orders", simpleOrder+simpleOrder)
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	if body := decodeBody[errorResponse](t, rec); body.Error != "request body must contain a single JSON value" {
+		t.Errorf("error = %q", body.Error)
+	}
+}
+
+func TestErrorResponsesCarryCodes(t *testing.T) {
+	h := newTestHandler(t, WithAPIKeys([]string{"k1"}))
+	routes := h.routes()
+	authed := []string{"Authorization", "Bearer k1"}
+	if err := h.store.Save(storedOrder("o1")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		headers                  []string
+		status                   int
+		code                     ErrorCode
+	}{
+		{"no key", http.MethodGet, "/orders", "", nil, http.StatusUnauthorized, CodeUnauthorized},
+		{"unknown route", http.MethodGet, "/nowhere", "", authed, http.StatusNotFound, CodeRouteNotFound},
+		{"missing order", http.MethodGet, "/orders/missing", "", authed, http.StatusNotFound, CodeOrderNotFound},
+		{"bad transition", http.MethodPatch, "/orders/o1/status", `{"status":"delivered"}`, authed, http.StatusConflict, CodeInvalidTransition},
+		{"unknown item", http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"99","quantity":1}]}`, authed, http.StatusBadRequest, CodeInvalidItem},
+		{"invalid body", http.MethodPost, "/orders", `[]`, authed, http.StatusBadRequest, CodeInvalidRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(t, routes, tc.method, tc.path, tc.body, tc.headers...)
+			wantError(t, rec, tc.status, tc.code)
+			if body := decodeBody[errorResponse](t, rec); body.Error == "" {
+				t.Error("error message is empty")
+			}
+		})
+	}
+}
+
+// brokenStore is a store whose lookups fail with an error it does not
+// classify.
+type brokenStore struct{ *MemoryStore }
+
+func (brokenStore) Get(string) (Order, error) { return Order{}, errors.New("disk I/O error") }
+
+func TestStatusFromError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: o1", ErrAlreadyExists), http.StatusConflict},
+		{checkTransition(StatusDelivered, StatusReceived), http.StatusConflict},
+		{errors.New("disk I/O error"), http.StatusInternalServerError},
+	} {
+		if got := statusFromError(tc.err); got != tc.want {
+			t.Errorf("statusFromError(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestStoreErrorsThroughHandlers(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	wantError(t, serve(t, routes, http.MethodGet, "/orders/missing", ""), http.StatusNotFound, CodeOrderNotFound)
+
+	body := `{"id":"mine","customer_id":"c1","items":[{"item_id":"1","quantity":1}]}`
+	createTestOrder(t, routes, body)
+	rec := serve(t, routes, http.MethodPost, "/orders", body)
+	wantError(t, rec, http.StatusConflict, CodeOrderExists)
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "mine") {
+		t.Errorf("error = %q, want it to name the order", msg)
+	}
+
+	rec = serve(t, routes, http.MethodPatch, "/orders/mine/status", `{"status":"delivered"}`)
+	wantError(t, rec, http.StatusConflict, CodeInvalidTransition)
+}
+
+func TestUnclassifiedStoreErrorIsHidden(t *testing.T) {
+	logs := captureLogs(t)
+	catalog, _ := newMockCatalog("1")
+	routes := newHandler(brokenStore{NewMemoryStore()}, WithCatalog(catalog)).routes()
+
+	rec := serve(t, routes, http.MethodGet, "/orders/o1", "")
+	wantError(t, rec, http.StatusInternalServerError, CodeInternal)
+	if strings.Contains(rec.Body.String(), "disk") {
+		t.Errorf("body %s leaks the store error", rec.Body)
+	}
+	if !strings.Contains(logs.String(), "order store failed") || !strings.Contains(logs.String(), "disk I/O error") {
+		t.Errorf("store error was not logged:\n%s", logs)
+	}
+}
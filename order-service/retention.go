@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultRetentionInterval = time.Hour
+
+// resolveRetention reads ORDER_RETENTION, how long delivered and cancelled
+// orders are kept after their last update (unset or 0 keeps them forever),
+// and ORDER_RETENTION_INTERVAL, how often they are swept (default 1h).
+func resolveRetention() (retention, interval time.Duration, err error) {
+	if v := setting("ORDER_RETENTION"); v != "" {
+		if retention, err = time.ParseDuration(v); err != nil || retention < 0 {
+			return 0, 0, fmt.Errorf("invalid ORDER_RETENTION %q: must be a non-negative duration", v)
+		}
+	}
+	interval = defaultRetentionInterval
+	if v := setting("ORDER_RETENTION_INTERVAL"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return 0, 0, fmt.Errorf("invalid ORDER_RETENTION_INTERVAL %q: must be a positive duration", v)
+		}
+	}
+	return retention, interval, nil
+}
+
+// expired reports whether o is in a terminal status and was last updated
+// more than retention before now.
+func expired(o Order, retention time.Duration, now time.Time) bool {
+	if !isTerminal(o.Status) {
+		return false
+	}
+	return o.UpdatedAt.Before(now.Add(-retention))
+}
+
+// sweepExpired deletes every expired order, soft-deleted ones included,
+// and returns how many it removed.
+func (h *handler) sweepExpired(retention time.Duration) int {
+	now := h.now()
+	removed := 0
+	for _, o := range h.store.All() {
+		if !expired(o, retention, now) {
+			continue
+		}
+		switch err := h.store.Delete(o.ID); {
+		case err == nil:
+			removed++
+			h.events.Forget(o.ID)
+		case errors.Is(err, ErrNotFound):
+			h.events.Forget(o.ID)
+		default:
+			log.Printf("Error deleting expired order %s: %v", o.ID, err)
+		}
+	}
+	return removed
+}
+
+// runRetentionSweeper calls sweepExpired every interval until ctx is done.
+// A non-positive retention turns it off.
+func (h *handler) runRetentionSweeper(ctx context.Context, retention, interval time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := h.sweepExpired(retention); n > 0 {
+				log.Printf("Retention sweep removed %d orders older than %s", n, retention)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// agedOrder is a stored order in status, last updated age before testTime.
+func agedOrder(id, status string, age time.Duration) Order {
+	o := storedOrder(id)
+	o.Status = status
+	o.UpdatedAt = testTime.Add(-age)
+	return o
+}
+
+func TestSweepExpired(t *testing.T) {
+	h := newTestHandler(t)
+	for _, o := range []Order{
+		agedOrder("old-delivered", StatusDelivered, 48*time.Hour),
+		agedOrder("old-cancelled", StatusCancelled, 48*time.Hour),
+		agedOrder("new-delivered", StatusDelivered, time.Hour),
+		agedOrder("old-ready", StatusReady, 48*time.Hour),
+	} {
+		if err := h.store.Save(o); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		h.events.Append(OrderEvent{Type: EventStatusChanged, OrderID: o.ID, At: o.UpdatedAt})
+	}
+	deleted := agedOrder("old-deleted", StatusDelivered, 48*time.Hour)
+	deleted.Deleted = true
+	h.store.Save(deleted)
+
+	if n := h.sweepExpired(24 * time.Hour); n != 3 {
+		t.Fatalf("sweepExpired removed %d orders, want 3", n)
+	}
+	for _, id := range []string{"old-delivered", "old-cancelled", "old-deleted"} {
+		if _, err := h.store.Get(id); err == nil {
+			t.Errorf("%s survived the sweep", id)
+		}
+		if events := h.events.Events(id); len(events) != 0 {
+			t.Errorf("%s still has %d events", id, len(events))
+		}
+	}
+	for _, id := range []string{"new-delivered", "old-ready"} {
+		if _, err := h.store.Get(id); err != nil {
+			t.Errorf("%s was swept: %v", id, err)
+		}
+	}
+}
+
+func TestRunRetentionSweeper(t *testing.T) {
+	h := newTestHandler(t)
+	h.store.Save(agedOrder("o1", StatusDelivered, 48*time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		h.runRetentionSweeper(ctx, 24*time.Hour, 10*time.Millisecond)
+		close(done)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(h.store.All()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := len(h.store.All()); n != 0 {
+		t.Errorf("store holds %d orders after the sweeper ran, want 0", n)
+	}
+
+	// Retention 0 keeps orders forever; the sweeper returns straight away.
+	h.store.Save(agedOrder("o2", StatusDelivered, 48*time.Hour))
+	h.runRetentionSweeper(context.Background(), 0, time.Millisecond)
+	if _, err := h.store.Get("o2"); err != nil {
+		t.Errorf("o2 swept with retention off: %v", err)
+	}
+}
+
+func TestResolveRetention(t *testing.T) {
+	t.Setenv("ORDER_RETENTION", "")
+	t.Setenv("ORDER_RETENTION_INTERVAL", "")
+	if r, i, err := resolveRetention(); r != 0 || i != defaultRetentionInterval || err != nil {
+		t.Errorf("defaults = %v, %v, %v; want 0, %v", r, i, err, defaultRetentionInterval)
+	}
+	t.Setenv("ORDER_RETENTION", "72h")
+	t.Setenv("ORDER_RETENTION_INTERVAL", "10m")
+	if r, i, err := resolveRetention(); r != 72*time.Hour || i != 10*time.Minute || err != nil {
+		t.Errorf("72h, 10m = %v, %v, %v", r, i, err)
+	}
+	for _, tc := range []struct{ retention, interval string }{
+		{"-1h", ""}, {"forever", ""}, {"", "0s"}, {"", "soon"},
+	} {
+		t.Setenv("ORDER_RETENTION", tc.retention)
+		t.Setenv("ORDER_RETENTION_INTERVAL", tc.interval)
+		if _, _, err := resolveRetention(); err == nil {
+			t.Errorf("ORDER_RETENTION=%q ORDER_RETENTION_INTERVAL=%q accepted", tc.retention, tc.interval)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// retryPolicy controls how doWithRetry retries a failed call.
+type retryPolicy struct {
+	// MaxAttempts bounds how often a call is tried, including the first.
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt; it doubles after
+	// every further failure.
+	BaseDelay time.Duration
+}
+
+// defaultRetryPolicy is used for catalog lookups.
+var defaultRetryPolicy = retryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond}
+
+// doWithRetry sends the request built by newReq through client, retrying
+// connection errors and 5xx responses with exponential backoff and jitter
+// until policy.MaxAttempts is reached. newReq is called afresh for every
+// attempt (numbered from 1), so it can rebuild bodies or switch to another
+// address. The response of the final attempt is returned as-is, even if it
+// is a 5xx.
+func doWithRetry(client *http.Client, policy retryPolicy, newReq func(attempt int) (*http.Request, error)) (*http.Response, error) {
+	maxAttempts := max(policy.MaxAttempts, 1)
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		req, err := newReq(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if attempt > 1 {
+			select {
+			case <-time.After(backoff(policy.BaseDelay, attempt-1)):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			if req.Context().Err() != nil || errors.Is(err, errRedirectNotAllowed) {
+				// The caller gave up, or the redirect will be refused
+				// again; retrying cannot succeed.
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= 500 && attempt < maxAttempts {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("server returned %s", resp.Status)
+			continue
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// backoff returns the delay before retry n (1-based) with up to 50% jitter.
+func backoff(base time.Duration, n int) time.Duration {
+	d := base << (n - 1)
+	return d + time.Duration(rand.Int64N(int64(d)/2+1))
+}
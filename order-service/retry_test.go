@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests with 503, then answers 200.
+func flakyServer(t *testing.T, failures int32) (*atomic.Int32, *httptest.Server) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return &calls, srv
+}
+
+func getFrom(url string) func(int) (*http.Request, error) {
+	return func(int) (*http.Request, error) { return http.NewRequest(http.MethodGet, url, nil) }
+}
+
+func TestDoWithRetryRecovers(t *testing.T) {
+	calls, srv := flakyServer(t, 2)
+	resp, err := doWithRetry(srv.Client(), retryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, getFrom(srv.URL))
+	if err != nil {
+		t.Fatalf("doWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("status %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+}
+
+// The last attempt's 5xx is handed back rather than turned into an error.
+func TestDoWithRetryReturnsFinalResponse(t *testing.T) {
+	calls, srv := flakyServer(t, 10)
+	resp, err := doWithRetry(srv.Client(), retryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}, getFrom(srv.URL))
+	if err != nil {
+		t.Fatalf("doWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 2 {
+		t.Errorf("status %d after %d calls, want 503 after 2", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestDoWithRetryDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	resp, err := doWithRetry(srv.Client(), retryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, getFrom(srv.URL))
+	if err != nil {
+		t.Fatalf("doWithRetry: %v", err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 {
+		t.Errorf("a 404 was tried %d times, want 1", calls.Load())
+	}
+}
+
+func TestDoWithRetryConnectionErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	attempts := 0
+	_, err := doWithRetry(http.DefaultClient, retryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, func(int) (*http.Request, error) {
+		attempts++
+		return http.NewRequest(http.MethodGet, url, nil)
+	})
+	if err == nil || attempts != 3 {
+		t.Fatalf("err = %v after %d attempts, want an error after 3", err, attempts)
+	}
+}
+
+func TestDoWithRetryStopsWhenCallerGivesUp(t *testing.T) {
+	_, srv := flakyServer(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	_, err := doWithRetry(srv.Client(), retryPolicy{MaxAttempts: 5, BaseDelay: time.Hour}, func(int) (*http.Request, error) {
+		attempts++
+		if attempts == 2 {
+			cancel()
+		}
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 2 {
+		t.Fatalf("err = %v after %d attempts, want context.Canceled after 2", err, attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for n, want := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base} {
+		for range 20 {
+			if d := backoff(base, n); d < want || d > want+want/2 {
+				t.Fatalf("backoff(%s, %d) = %s, want between %s and %s", base, n, d, want, want+want/2)
+			}
+		}
+	}
+}
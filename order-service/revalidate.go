@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Per-item outcomes reported by POST /orders/{id}/revalidate and by
+// orders checked item by item during a partial catalog outage.
+const (
+	itemValid             = "valid"
+	itemInvalid           = "invalid"
+	itemUnavailable       = "unavailable"
+	itemInsufficientStock = "insufficient_stock"
+	itemOutsideWindow     = "outside_window"
+	itemBlocked           = "blocked"
+	itemNotAllowed        = "not_allowed"
+	itemUnchecked         = "unchecked"
+	// itemUnknown is an item the catalog could not answer for.
+	itemUnknown = "unknown"
+)
+
+// itemOutcomes maps the item-level catalog rejections onto report outcomes.
+var itemOutcomes = map[ErrorCode]string{
+	CodeInvalidItem:       itemInvalid,
+	CodeItemUnavailable:   itemUnavailable,
+	CodeInsufficientStock: itemInsufficientStock,
+	CodeItemOutsideWindow: itemOutsideWindow,
+	CodeItemBlocked:       itemBlocked,
+	CodeItemNotAllowed:    itemNotAllowed,
+}
+
+// itemCheck is the outcome of checking one item against the catalog.
+type itemCheck struct {
+	ItemID  string `json:"item_id"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+type revalidationReport struct {
+	OrderID   string      `json:"order_id"`
+	Valid     bool        `json:"valid"`
+	Items     []itemCheck `json:"items"`
+	Cancelled bool        `json:"cancelled"`
+	Order     Order       `json:"order"`
+}
+
+// resolveRevalidateAutoCancel reads REVALIDATE_AUTO_CANCEL; when true, a
+// revalidation that finds a bad item cancels the order if it still can be.
+func resolveRevalidateAutoCancel() (bool, error) {
+	v := setting("REVALIDATE_AUTO_CANCEL")
+	if v == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid REVALIDATE_AUTO_CANCEL %q: must be true or false", v)
+	}
+	return enabled, nil
+}
+
+// revalidateOrder re-checks every item of a stored order against the current
+// catalog, bypassing the item cache, and reports which are still valid.
+// Catalog outages fail the whole request rather than being reported as bad
+// items.
+func (h *handler) revalidateOrder(w http.ResponseWriter, r *http.Request) {
+	order, err := h.activeOrder(chi.URLParam(r, "id"))
+	if err != nil {
+		writeRequestError(w, storeError(r.Context(), err, "load order"))
+		return
+	}
+
+	report := revalidationReport{OrderID: order.ID, Valid: true, Items: []itemCheck{}}
+	for _, li := range order.Items {
+		outcome := itemCheck{ItemID: li.ItemID, Outcome: itemValid}
+		_, skipped, _, reqErr := h.checkItems(withFreshCatalog(r.Context()), []LineItem{li})
+		switch {
+		case reqErr != nil:
+			bad, itemLevel := itemOutcomes[reqErr.code]
+			if !itemLevel {
+				writeRequestError(w, reqErr)
+				return
+			}
+			outcome.Outcome, outcome.Error = bad, reqErr.msg
+			report.Valid = false
+		case skipped:
+			outcome.Outcome = itemUnchecked
+		}
+		report.Items = append(report.Items, outcome)
+	}
+
+	if !report.Valid && h.revalidateAutoCancel && canTransition(order.Status, StatusCancelled) {
+		cancelled, reqErr := h.transition(r.Context(), order.ID, StatusCancelled)
+		if reqErr != nil {
+			writeRequestError(w, reqErr)
+			return
+		}
+		order, report.Cancelled = cancelled, true
+	}
+	report.Order = order
+	writeJSON(w, http.StatusOK, report)
+}
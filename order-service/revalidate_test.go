@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// switchableCatalog accepts every item until the one named in reject is
+// set, then reports that one as invalid.
+func switchableCatalog(reject *string) *stubCatalog {
+	return &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		items := make(map[string]catalogItem, len(ids))
+		for _, id := range ids {
+			if id == *reject {
+				return nil, &invalidItemError{ID: id}
+			}
+			items[id] = catalogItem{ID: id, Price: 1, Available: true}
+		}
+		return items, nil
+	}}
+}
+
+func TestRevalidateOrder(t *testing.T) {
+	var reject string
+	routes := newTestHandler(t, WithCatalog(switchableCatalog(&reject))).routes()
+	order := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1},{"item_id":"2","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/revalidate", "")
+	wantStatus(t, rec, http.StatusOK)
+	report := decodeBody[revalidationReport](t, rec)
+	if !report.Valid || len(report.Items) != 2 || report.Items[0].Outcome != itemValid || report.Items[1].Outcome != itemValid {
+		t.Fatalf("report = %+v, want both items valid", report)
+	}
+
+	reject = "2"
+	rec = serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/revalidate", "")
+	wantStatus(t, rec, http.StatusOK)
+	report = decodeBody[revalidationReport](t, rec)
+	if report.Valid || report.Items[0].Outcome != itemValid || report.Items[1].Outcome != itemInvalid || report.Items[1].Error == "" {
+		t.Errorf("report = %+v, want item 2 reported invalid", report)
+	}
+	if report.Cancelled || report.Order.Status != StatusReceived {
+		t.Errorf("order cancelled without REVALIDATE_AUTO_CANCEL: %+v", report)
+	}
+}
+
+func TestRevalidateOrderAutoCancel(t *testing.T) {
+	var reject string
+	h := newTestHandler(t, WithCatalog(switchableCatalog(&reject)), WithRevalidateAutoCancel(true))
+	routes := h.routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	reject = order.Items[0].ItemID
+	rec := serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/revalidate", "")
+	wantStatus(t, rec, http.StatusOK)
+	report := decodeBody[revalidationReport](t, rec)
+	if !report.Cancelled || report.Order.Status != StatusCancelled {
+		t.Errorf("report = %+v, want the order cancelled", report)
+	}
+	if stored, _ := h.store.Get(order.ID); stored.Status != StatusCancelled {
+		t.Errorf("stored order is %s, want cancelled", stored.Status)
+	}
+}
+
+func TestRevalidateOrderErrors(t *testing.T) {
+	catalog := &stubCatalog{validate: func(context.Context, []string) (map[string]catalogItem, error) {
+		return map[string]catalogItem{"1": {ID: "1", Price: 1, Available: true}}, nil
+	}}
+	h := newTestHandler(t, WithCatalog(catalog))
+	routes := h.routes()
+
+	rec := serve(t, routes, http.MethodPost, "/orders/missing/revalidate", "")
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+
+	order := createTestOrder(t, routes, simpleOrder)
+	catalog.validate = func(context.Context, []string) (map[string]catalogItem, error) {
+		return nil, errCatalogUnavailable
+	}
+	rec = serve(t, routes, http.MethodPost, "/orders/"+order.ID+"/revalidate", "")
+	wantError(t, rec, http.StatusServiceUnavailable, CodeCatalogUnavailable)
+}
+
+func TestResolveRevalidateAutoCancel(t *testing.T) {
+	t.Setenv("REVALIDATE_AUTO_CANCEL", "")
+	if on, err := resolveRevalidateAutoCancel(); on || err != nil {
+		t.Errorf("default = %v, %v; want off", on, err)
+	}
+	t.Setenv("REVALIDATE_AUTO_CANCEL", "true")
+	if on, err := resolveRevalidateAutoCancel(); !on || err != nil {
+		t.Errorf("true = %v, %v", on, err)
+	}
+	t.Setenv("REVALIDATE_AUTO_CANCEL", "sometimes")
+	if _, err := resolveRevalidateAutoCancel(); err == nil {
+		t.Error("REVALIDATE_AUTO_CANCEL=sometimes accepted")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTrailingSlashIsServed(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders/", simpleOrder)
+	wantStatus(t, rec, http.StatusCreated)
+	id := decodeBody[Order](t, rec).ID
+
+	rec = serve(t, routes, http.MethodGet, "/orders/", "")
+	wantStatus(t, rec, http.StatusOK)
+	if orders := decodeBody[[]Order](t, rec); len(orders) != 1 {
+		t.Errorf("GET /orders/ returned %d orders, want 1", len(orders))
+	}
+	wantStatus(t, serve(t, routes, http.MethodGet, "/orders/"+id+"/", ""), http.StatusOK)
+}
+
+func TestUnknownRoute(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodGet, "/nowhere", "")
+	wantError(t, rec, http.StatusNotFound, CodeRouteNotFound)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "/nowhere") {
+		t.Errorf("error = %q, want it to name the path", msg)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		h          *handler
+		method     string
+		path, want string
+	}{
+		{"collection", newTestHandler(t), http.MethodDelete, "/orders", "GET, POST"},
+		{"order", newTestHandler(t), http.MethodPut, "/orders/o1", "GET, PATCH, DELETE"},
+		{"action", newTestHandler(t), http.MethodGet, "/orders/o1/reorder", "POST"},
+		{"base path", newTestHandler(t, WithBasePath("/api")), http.MethodDelete, "/api/orders", "GET, POST"},
+	} {
+		rec := serve(t, tc.h.routes(), tc.method, tc.path, "")
+		wantError(t, rec, http.StatusMethodNotAllowed, CodeMethodNotAllowed)
+		if allow := rec.Header().Get("Allow"); allow != tc.want {
+			t.Errorf("%s: Allow = %q, want %q", tc.name, allow, tc.want)
+		}
+	}
+}
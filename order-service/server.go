@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultReadHeaderTimeout stops a client holding a connection open by
+	// trickling in request headers (Slowloris).
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxHeaderBytes    = http.DefaultMaxHeaderBytes
+)
+
+// serverLimits are the connection settings applied to the HTTP server.
+// There is deliberately no write timeout: it would cut off /orders/stream.
+type serverLimits struct {
+	ReadHeaderTimeout time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+}
+
+// resolveServerLimits reads READ_HEADER_TIMEOUT (default 10s), IDLE_TIMEOUT
+// (default 120s) and MAX_HEADER_BYTES (default 1MB).
+func resolveServerLimits() (serverLimits, error) {
+	l := serverLimits{
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+		MaxHeaderBytes:    defaultMaxHeaderBytes,
+	}
+	if v := setting("READ_HEADER_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return serverLimits{}, fmt.Errorf("invalid READ_HEADER_TIMEOUT %q: must be a positive duration", v)
+		}
+		l.ReadHeaderTimeout = d
+	}
+	if v := setting("IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return serverLimits{}, fmt.Errorf("invalid IDLE_TIMEOUT %q: must be a positive duration", v)
+		}
+		l.IdleTimeout = d
+	}
+	if v := setting("MAX_HEADER_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return serverLimits{}, fmt.Errorf("invalid MAX_HEADER_BYTES %q: must be a positive integer", v)
+		}
+		l.MaxHeaderBytes = n
+	}
+	return l, nil
+}
+
+// newHTTPServer builds the server for handler on port with limits applied.
+// HTTP/2 is negotiated automatically when the server runs with TLS.
+func newHTTPServer(port string, handler http.Handler, limits serverLimits, conns *connTracker) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: limits.ReadHeaderTimeout,
+		IdleTimeout:       limits.IdleTimeout,
+		MaxHeaderBytes:    limits.MaxHeaderBytes,
+		ConnState:         conns.track,
+	}
+}
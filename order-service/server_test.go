@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveServerLimits(t *testing.T) {
+	t.Setenv("READ_HEADER_TIMEOUT", "")
+	t.Setenv("IDLE_TIMEOUT", "")
+	t.Setenv("MAX_HEADER_BYTES", "")
+	l, err := resolveServerLimits()
+	if err != nil || l.ReadHeaderTimeout != defaultReadHeaderTimeout || l.IdleTimeout != defaultIdleTimeout || l.MaxHeaderBytes != defaultMaxHeaderBytes {
+		t.Errorf("defaults = %+v, %v", l, err)
+	}
+
+	t.Setenv("READ_HEADER_TIMEOUT", "2s")
+	t.Setenv("IDLE_TIMEOUT", "30s")
+	t.Setenv("MAX_HEADER_BYTES", "4096")
+	l, err = resolveServerLimits()
+	if want := (serverLimits{ReadHeaderTimeout: 2 * time.Second, IdleTimeout: 30 * time.Second, MaxHeaderBytes: 4096}); err != nil || l != want {
+		t.Errorf("overrides = %+v, %v; want %+v", l, err, want)
+	}
+
+	for name, v := range map[string]string{
+		"READ_HEADER_TIMEOUT": "0s",
+		"IDLE_TIMEOUT":        "a while",
+		"MAX_HEADER_BYTES":    "-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, v)
+			if _, err := resolveServerLimits(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("%s=%q = %v, want an error naming it", name, v, err)
+			}
+		})
+	}
+}
+
+// serveWithLimits starts the service's routes behind a server with limits,
+// returning its address.
+func serveWithLimits(t *testing.T, limits serverLimits) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server := newHTTPServer("0", newTestHandler(t).routes(), limits, newConnTracker())
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return ln.Addr().String()
+}
+
+// A client trickling in its headers is cut off after ReadHeaderTimeout.
+func TestServerReadHeaderTimeout(t *testing.T) {
+	addr := serveWithLimits(t, serverLimits{ReadHeaderTimeout: 100 * time.Millisecond, MaxHeaderBytes: defaultMaxHeaderBytes})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /health HTTP/1.1\r\nHost: test\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := io.ReadAll(conn); err != nil {
+		t.Fatalf("connection was not closed by the server: %v", err)
+	}
+}
+
+func TestServerMaxHeaderBytes(t *testing.T) {
+	addr := serveWithLimits(t, serverLimits{ReadHeaderTimeout: time.Second, MaxHeaderBytes: 1024})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /health HTTP/1.1\r\nHost: test\r\nX-Padding: "+strings.Repeat("a", 8192)+"\r\n\r\n")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("status = %d, want 431", resp.StatusCode)
+	}
+}
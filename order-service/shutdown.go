@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// resolveShutdownTimeout reads SHUTDOWN_TIMEOUT (a Go duration such as
+// "20s"), defaulting to 10s.
+func resolveShutdownTimeout() (time.Duration, error) {
+	v := setting("SHUTDOWN_TIMEOUT")
+	if v == "" {
+		return defaultShutdownTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_TIMEOUT %q: must be a positive duration", v)
+	}
+	return d, nil
+}
+
+// connTracker counts open connections through http.Server.ConnState so a
+// forced shutdown can report how many it dropped.
+type connTracker struct {
+	mu    sync.Mutex
+	conns map[net.Conn]http.ConnState
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]http.ConnState)}
+}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+	default:
+		t.conns[c] = state
+	}
+}
+
+// active returns how many connections are still serving a request.
+func (t *connTracker) active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, state := range t.conns {
+		if state == http.StateActive {
+			n++
+		}
+	}
+	return n
+}
+
+// shutdownServer drains server for up to timeout, then force-closes any
+// connections still open so shutdown never outlives the pod's grace period.
+func shutdownServer(server *http.Server, conns *connTracker, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	log.Printf("Graceful shutdown timed out after %s, dropping %d active connection(s)", timeout, conns.active())
+	return server.Close()
+}
+
+// stopGRPCServer lets in-flight RPCs finish for up to timeout, then cancels
+// whatever is left.
+func stopGRPCServer(s *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("gRPC graceful stop timed out after %s, cancelling remaining RPCs", timeout)
+		s.Stop()
+	}
+}
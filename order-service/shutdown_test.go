@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startTestServer serves handler on a loopback port through newHTTPServer
+// and returns the server and its base URL.
+func startTestServer(t *testing.T, handler http.Handler, conns *connTracker) (*http.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	server := newHTTPServer("0", handler, serverLimits{ReadHeaderTimeout: time.Second}, conns)
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return server, "http://" + ln.Addr().String()
+}
+
+// A request in flight when shutdown starts is allowed to finish.
+func TestShutdownServerWaitsForRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	conns := newConnTracker()
+	server, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	}), conns)
+
+	type result struct {
+		body string
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			got <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		got <- result{string(b), err}
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- shutdownServer(server, conns, 5*time.Second) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("shutdown returned %v before the request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	if r := <-got; r.err != nil || r.body != "done" {
+		t.Fatalf("in-flight request = %q, %v; want it to complete", r.body, r.err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("shutdownServer: %v", err)
+	}
+}
+
+// A request still running when the timeout passes is cut off, so shutdown
+// never outlives its budget.
+func TestShutdownServerForceCloses(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	conns := newConnTracker()
+	server, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), conns)
+
+	failed := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		failed <- err
+	}()
+	<-started
+	if n := conns.active(); n != 1 {
+		t.Errorf("active connections = %d, want 1", n)
+	}
+
+	start := time.Now()
+	if err := shutdownServer(server, conns, 50*time.Millisecond); err != nil {
+		t.Fatalf("shutdownServer: %v", err)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("shutdown took %s", took)
+	}
+	if err := <-failed; err == nil {
+		t.Error("the stuck request completed instead of being dropped")
+	}
+}
+
+func TestResolveShutdownTimeout(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+	if d, err := resolveShutdownTimeout(); d != defaultShutdownTimeout || err != nil {
+		t.Errorf("default = %s, %v; want %s", d, err, defaultShutdownTimeout)
+	}
+	t.Setenv("SHUTDOWN_TIMEOUT", "25s")
+	if d, err := resolveShutdownTimeout(); d != 25*time.Second || err != nil {
+		t.Errorf("SHUTDOWN_TIMEOUT=25s = %s, %v", d, err)
+	}
+	for _, v := range []string{"0", "-3s", "soon"} {
+		t.Setenv("SHUTDOWN_TIMEOUT", v)
+		if _, err := resolveShutdownTimeout(); err == nil {
+			t.Errorf("SHUTDOWN_TIMEOUT=%q accepted", v)
+		}
+	}
+}
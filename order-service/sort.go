@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Sort keys accepted by ?sort=. A leading "-" reverses created_at.
+const (
+	sortPriority      = "priority"
+	sortCreatedAt     = "created_at"
+	sortCreatedAtDesc = "-created_at"
+	sortID            = "id"
+)
+
+// defaultSort puts the most urgent orders first, oldest first within a
+// priority, which is the order the kitchen works through them.
+const defaultSort = sortPriority
+
+var sortKeys = []string{sortPriority, sortCreatedAt, sortCreatedAtDesc, sortID}
+
+// parseSort reads ?sort=, defaulting to priority.
+func parseSort(q url.Values) (string, error) {
+	v := q.Get("sort")
+	if v == "" {
+		return defaultSort, nil
+	}
+	if !slices.Contains(sortKeys, v) {
+		return "", fmt.Errorf("invalid sort %q: must be one of %s", v, strings.Join(sortKeys, ", "))
+	}
+	return v, nil
+}
+
+// sortOrders sorts orders in place by key, breaking ties by ID so listings
+// stay stable across pages.
+func sortOrders(orders []Order, key string) {
+	slices.SortStableFunc(orders, func(a, b Order) int {
+		var c int
+		switch key {
+		case sortPriority:
+			if c = b.Priority - a.Priority; c == 0 {
+				c = a.CreatedAt.Compare(b.CreatedAt)
+			}
+		case sortCreatedAt:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case sortCreatedAtDesc:
+			c = b.CreatedAt.Compare(a.CreatedAt)
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		return c
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSortOrders(t *testing.T) {
+	at := func(id string, priority int, minutes int) Order {
+		return Order{ID: id, Priority: priority, CreatedAt: testTime.Add(time.Duration(minutes) * time.Minute)}
+	}
+	orders := []Order{at("d", 0, 1), at("a", 5, 3), at("c", 5, 2), at("b", 0, 1)}
+	ids := func() []string {
+		var ids []string
+		for _, o := range orders {
+			ids = append(ids, o.ID)
+		}
+		return ids
+	}
+
+	for key, want := range map[string][]string{
+		sortPriority:      {"c", "a", "b", "d"},
+		sortCreatedAt:     {"b", "d", "c", "a"},
+		sortCreatedAtDesc: {"a", "c", "b", "d"},
+		sortID:            {"a", "b", "c", "d"},
+	} {
+		sortOrders(orders, key)
+		if got := ids(); !slices.Equal(got, want) {
+			t.Errorf("sort %s = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	if key, err := parseSort(url.Values{}); key != sortPriority || err != nil {
+		t.Errorf("default = %q, %v; want priority", key, err)
+	}
+	if key, err := parseSort(url.Values{"sort": {"-created_at"}}); key != sortCreatedAtDesc || err != nil {
+		t.Errorf("-created_at = %q, %v", key, err)
+	}
+	if _, err := parseSort(url.Values{"sort": {"total"}}); err == nil {
+		t.Error("sort=total accepted")
+	}
+}
+
+func TestListOrdersByPriority(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	normal := createTestOrder(t, routes, simpleOrder)
+	urgent := createTestOrder(t, routes, `{"customer_id":"c1","priority":9,"items":[{"item_id":"1","quantity":1}]}`)
+
+	rec := serve(t, routes, http.MethodGet, "/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[[]Order](t, rec)
+	if len(got) != 2 || got[0].ID != urgent.ID || got[1].ID != normal.ID {
+		t.Fatalf("listing = %+v, want the priority 9 order first", got)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders?sort=bogus", "")
+	wantError(t, rec, http.StatusBadRequest, CodeInvalidRequest)
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","priority":11,"items":[{"item_id":"1","quantity":1}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
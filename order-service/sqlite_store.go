@@ -0,0 +1,416 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const createOrdersTable = `CREATE TABLE IF NOT EXISTS orders (
+	id       TEXT PRIMARY KEY,
+	item_ids JSON NOT NULL,
+	status   TEXT NOT NULL
+)`
+
+// createSequenceTable holds the last assigned order number in a single row,
+// so numbers are not reused when the newest orders are deleted.
+const createSequenceTable = `CREATE TABLE IF NOT EXISTS order_sequence (
+	id    INTEGER PRIMARY KEY CHECK (id = 1),
+	value INTEGER NOT NULL
+)`
+
+// nextOrderNumber bumps the sequence, seeding it from existing orders the
+// first time, and returns the new value.
+const nextOrderNumber = `INSERT INTO order_sequence (id, value)
+	VALUES (1, (SELECT COALESCE(MAX(order_number), 0) + 1 FROM orders))
+	ON CONFLICT(id) DO UPDATE SET value = value + 1
+	RETURNING value`
+
+// orderColumns are added to databases created before the column existed.
+var orderColumns = []struct{ name, definition string }{
+	{"total_cents", "INTEGER NOT NULL DEFAULT 0"},
+	{"items", "JSON"},
+	{"created_at", "TEXT NOT NULL DEFAULT ''"},
+	{"updated_at", "TEXT NOT NULL DEFAULT ''"},
+	{"validation_skipped", "INTEGER NOT NULL DEFAULT 0"},
+	{"deleted", "INTEGER NOT NULL DEFAULT 0"},
+	{"deleted_at", "TEXT NOT NULL DEFAULT ''"},
+	{"customer_id", "TEXT NOT NULL DEFAULT ''"},
+	{"priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"callback_url", "TEXT NOT NULL DEFAULT ''"},
+	{"currency", "TEXT NOT NULL DEFAULT ''"},
+	{"order_number", "INTEGER NOT NULL DEFAULT 0"},
+	{"assigned_to", "TEXT NOT NULL DEFAULT ''"},
+	{"notes", "TEXT NOT NULL DEFAULT ''"},
+	{"tags", "JSON NOT NULL DEFAULT '[]'"},
+	{"estimated_ready_at", "TEXT NOT NULL DEFAULT ''"},
+	{"item_checks", "JSON NOT NULL DEFAULT '[]'"},
+	{"status_history", "JSON NOT NULL DEFAULT '[]'"},
+}
+
+const orderSelect = `SELECT id, item_ids, items, status, total_cents, created_at, updated_at, validation_skipped, deleted, deleted_at, customer_id, priority, callback_url, currency, order_number, assigned_to, notes, tags, estimated_ready_at, item_checks, status_history FROM orders`
+
+// upsertOrder makes insertOrder overwrite an existing row with the same id.
+const upsertOrder = `ON CONFLICT(id) DO UPDATE SET
+	item_ids = excluded.item_ids,
+	items = excluded.items,
+	status = excluded.status,
+	total_cents = excluded.total_cents,
+	created_at = excluded.created_at,
+	updated_at = excluded.updated_at,
+	validation_skipped = excluded.validation_skipped,
+	deleted = excluded.deleted,
+	deleted_at = excluded.deleted_at,
+	customer_id = excluded.customer_id,
+	priority = excluded.priority,
+	callback_url = excluded.callback_url,
+	currency = excluded.currency,
+	order_number = excluded.order_number,
+	assigned_to = excluded.assigned_to,
+	notes = excluded.notes,
+	tags = excluded.tags,
+	estimated_ready_at = excluded.estimated_ready_at,
+	item_checks = excluded.item_checks,
+	status_history = excluded.status_history`
+
+// SQLiteStore persists orders in a SQLite database so they survive restarts.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and ensures the
+// orders table exists.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	// SQLite only supports a single writer; serialise access through one
+	// connection instead of surfacing SQLITE_BUSY to callers.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(createOrdersTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating orders table: %w", err)
+	}
+	if err := migrateOrderColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.Exec(createSequenceTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating order_sequence table: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func migrateOrderColumns(db *sql.DB) error {
+	existing := map[string]bool{}
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('orders')`)
+	if err != nil {
+		return fmt.Errorf("inspecting orders table: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("inspecting orders table: %w", err)
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspecting orders table: %w", err)
+	}
+	rows.Close()
+
+	for _, col := range orderColumns {
+		if existing[col.name] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE orders ADD COLUMN %s %s", col.name, col.definition)); err != nil {
+			return fmt.Errorf("adding column %s: %w", col.name, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Ping() error {
+	return s.db.Ping()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Save(o Order) error {
+	_, err := s.insert(o, upsertOrder)
+	return err
+}
+
+// Create numbers and inserts o in one transaction, so a clashing id does
+// not use up a number.
+func (s *SQLiteStore) Create(o Order) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+	if err := tx.QueryRow(nextOrderNumber).Scan(&o.OrderNumber); err != nil {
+		return Order{}, fmt.Errorf("assigning order number: %w", err)
+	}
+	res, err := insertOrder(tx, o, `ON CONFLICT(id) DO NOTHING`)
+	if err != nil {
+		return Order{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Order{}, fmt.Errorf("%w: %s", ErrAlreadyExists, o.ID)
+	}
+	return o, tx.Commit()
+}
+
+func (s *SQLiteStore) insert(o Order, onConflict string) (sql.Result, error) {
+	return insertOrder(s.db, o, onConflict)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertOrder writes o as a new row, resolving an id clash with onConflict.
+func insertOrder(db execer, o Order, onConflict string) (sql.Result, error) {
+	itemIDs, err := json.Marshal(o.ItemIDs())
+	if err != nil {
+		return nil, err
+	}
+	items, err := json.Marshal(o.Items)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := json.Marshal(append([]string{}, o.Tags...))
+	if err != nil {
+		return nil, err
+	}
+	checks, err := json.Marshal(append([]itemCheck{}, o.ItemChecks...))
+	if err != nil {
+		return nil, err
+	}
+	history, err := json.Marshal(append([]statusChange{}, o.StatusHistory...))
+	if err != nil {
+		return nil, err
+	}
+	var deletedAt, eta time.Time
+	if o.DeletedAt != nil {
+		deletedAt = *o.DeletedAt
+	}
+	if o.EstimatedReadyAt != nil {
+		eta = *o.EstimatedReadyAt
+	}
+	return db.Exec(
+		`INSERT INTO orders (id, item_ids, items, status, total_cents, created_at, updated_at, validation_skipped, deleted, deleted_at, customer_id, priority, callback_url, currency, order_number, assigned_to, notes, tags, estimated_ready_at, item_checks, status_history)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 `+onConflict,
+		o.ID, string(itemIDs), string(items), o.Status, o.TotalCents,
+		formatTime(o.CreatedAt), formatTime(o.UpdatedAt), o.ValidationSkipped,
+		o.Deleted, formatTime(deletedAt), o.CustomerID, o.Priority, o.CallbackURL, o.Currency, o.OrderNumber, o.AssignedTo, o.Notes, string(tags), formatTime(eta), string(checks), string(history),
+	)
+}
+
+func (s *SQLiteStore) Get(id string) (Order, error) {
+	o, err := scanOrder(s.db.QueryRow(orderSelect+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	if err != nil {
+		return Order{}, fmt.Errorf("loading order %s: %w", id, err)
+	}
+	return o, nil
+}
+
+func (s *SQLiteStore) GetByNumber(n int64) (Order, error) {
+	o, err := scanOrder(s.db.QueryRow(orderSelect+` WHERE order_number = ?`, n))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	if err != nil {
+		return Order{}, fmt.Errorf("loading order number %d: %w", n, err)
+	}
+	return o, nil
+}
+
+// All returns every stored order sorted by ID so listings are stable.
+func (s *SQLiteStore) All() []Order {
+	all := []Order{}
+	rows, err := s.db.Query(orderSelect + ` ORDER BY id`)
+	if err != nil {
+		log.Printf("Error listing orders: %v", err)
+		return all
+	}
+	defer rows.Close()
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			log.Printf("Error reading order row: %v", err)
+			continue
+		}
+		all = append(all, o)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error listing orders: %v", err)
+	}
+	return all
+}
+
+// Delete removes the order with the given id for good.
+func (s *SQLiteStore) Delete(id string) error {
+	res, err := s.db.Exec(`DELETE FROM orders WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting order %s: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Stats lets SQLite do the counting.
+func (s *SQLiteStore) Stats() (OrderStats, error) {
+	stats := newOrderStats()
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM orders WHERE deleted = 0 GROUP BY status`)
+	if err != nil {
+		return OrderStats{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return OrderStats{}, err
+		}
+		stats.add(status, n)
+	}
+	return stats, rows.Err()
+}
+
+func (s *SQLiteStore) Clear() (int, error) {
+	res, err := s.db.Exec(`DELETE FROM orders`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLiteStore) UpdateStatus(id, status string, at time.Time) (Order, error) {
+	return s.Update(id, moveTo(status, at))
+}
+
+// Update reads the order and writes it back in a single transaction, so no
+// other update can slip in between the read and the write.
+func (s *SQLiteStore) Update(id string, change func(*Order) error) (Order, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Order{}, err
+	}
+	defer tx.Rollback()
+	o, err := scanOrder(tx.QueryRow(orderSelect+` WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Order{}, ErrNotFound
+	}
+	if err != nil {
+		return Order{}, fmt.Errorf("loading order %s: %w", id, err)
+	}
+	if err := change(&o); err != nil {
+		return Order{}, err
+	}
+	if _, err := insertOrder(tx, o, upsertOrder); err != nil {
+		return Order{}, fmt.Errorf("updating order %s: %w", id, err)
+	}
+	return o, tx.Commit()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (Order, error) {
+	var o Order
+	var itemIDs string
+	var items sql.NullString
+	var tags, checks, history string
+	var createdAt, updatedAt, deletedAt, estimatedReadyAt string
+	if err := row.Scan(&o.ID, &itemIDs, &items, &o.Status, &o.TotalCents, &createdAt, &updatedAt, &o.ValidationSkipped, &o.Deleted, &deletedAt, &o.CustomerID, &o.Priority, &o.CallbackURL, &o.Currency, &o.OrderNumber, &o.AssignedTo, &o.Notes, &tags, &estimatedReadyAt, &checks, &history); err != nil {
+		return Order{}, err
+	}
+	var err error
+	if o.CreatedAt, err = parseTime(createdAt); err != nil {
+		return Order{}, fmt.Errorf("decoding created_at for %s: %w", o.ID, err)
+	}
+	if o.UpdatedAt, err = parseTime(updatedAt); err != nil {
+		return Order{}, fmt.Errorf("decoding updated_at for %s: %w", o.ID, err)
+	}
+	if deletedAt != "" {
+		t, err := parseTime(deletedAt)
+		if err != nil {
+			return Order{}, fmt.Errorf("decoding deleted_at for %s: %w", o.ID, err)
+		}
+		o.DeletedAt = &t
+	}
+	if estimatedReadyAt != "" {
+		t, err := parseTime(estimatedReadyAt)
+		if err != nil {
+			return Order{}, fmt.Errorf("decoding estimated_ready_at for %s: %w", o.ID, err)
+		}
+		o.EstimatedReadyAt = &t
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &o.Tags); err != nil {
+			return Order{}, fmt.Errorf("decoding tags for %s: %w", o.ID, err)
+		}
+	}
+	if checks != "" {
+		if err := json.Unmarshal([]byte(checks), &o.ItemChecks); err != nil {
+			return Order{}, fmt.Errorf("decoding item_checks for %s: %w", o.ID, err)
+		}
+	}
+	if history != "" {
+		if err := json.Unmarshal([]byte(history), &o.StatusHistory); err != nil {
+			return Order{}, fmt.Errorf("decoding status_history for %s: %w", o.ID, err)
+		}
+	}
+	if items.Valid {
+		if err := json.Unmarshal([]byte(items.String), &o.Items); err != nil {
+			return Order{}, fmt.Errorf("decoding items for %s: %w", o.ID, err)
+		}
+		return o, nil
+	}
+	// Rows written before line items existed only carry item_ids.
+	var ids []string
+	if err := json.Unmarshal([]byte(itemIDs), &ids); err != nil {
+		return Order{}, fmt.Errorf("decoding item_ids for %s: %w", o.ID, err)
+	}
+	for _, id := range ids {
+		o.Items = append(o.Items, LineItem{ItemID: id, Quantity: 1})
+	}
+	return o, nil
+}
+
+// formatTime stores timestamps as RFC 3339 text; the zero time is stored as
+// an empty string, matching rows written before timestamps existed.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
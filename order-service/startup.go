@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	defaultWaitForDepsTimeout = 60 * time.Second
+	depPollInterval           = time.Second
+)
+
+// resolveWaitForDeps reads WAIT_FOR_DEPS (off by default) and
+// WAIT_FOR_DEPS_TIMEOUT, defaulting to 60s.
+func resolveWaitForDeps() (bool, time.Duration, error) {
+	enabled := false
+	if v := setting("WAIT_FOR_DEPS"); v != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(v); err != nil {
+			return false, 0, fmt.Errorf("invalid WAIT_FOR_DEPS %q: must be true or false", v)
+		}
+	}
+	timeout := defaultWaitForDepsTimeout
+	if v := setting("WAIT_FOR_DEPS_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return false, 0, fmt.Errorf("invalid WAIT_FOR_DEPS_TIMEOUT %q: must be a positive duration", v)
+		}
+		timeout = d
+	}
+	return enabled, timeout, nil
+}
+
+// dependency is something the service needs before it can usefully serve.
+type dependency struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// startupDependencies returns the dependencies to wait for: Consul when
+// CONSUL_HTTP_ADDR is set, and always the catalog.
+func startupDependencies(catalog CatalogClient) []dependency {
+	var deps []dependency
+	if os.Getenv("CONSUL_HTTP_ADDR") != "" {
+		deps = append(deps, dependency{name: "consul", check: pingConsul})
+	}
+	return append(deps, dependency{name: "catalog", check: catalog.Ping})
+}
+
+// pingConsul succeeds once the Consul agent reports a cluster leader.
+func pingConsul(ctx context.Context) error {
+	client, err := consulClient()
+	if err != nil {
+		return err
+	}
+	leader, err := client.Status().LeaderWithQueryOptions((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if leader == "" {
+		return fmt.Errorf("no cluster leader")
+	}
+	return nil
+}
+
+// waitForDeps polls each dependency every interval until all of them are
+// healthy, giving up once timeout has elapsed.
+func waitForDeps(ctx context.Context, deps []dependency, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for _, dep := range deps {
+		for attempt := 1; ; attempt++ {
+			checkCtx, checkCancel := context.WithTimeout(ctx, readinessTimeout)
+			err := dep.check(checkCtx)
+			checkCancel()
+			if err == nil {
+				log.Printf("Dependency %s is ready", dep.name)
+				break
+			}
+			log.Printf("Waiting for %s (attempt %d): %v", dep.name, attempt, err)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%s not ready after %s: %w", dep.name, timeout, err)
+			case <-time.After(interval):
+			}
+		}
+	}
+	return nil
+}
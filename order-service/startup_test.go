@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+func TestWaitForDepsRetriesUntilReady(t *testing.T) {
+	calls := 0
+	deps := []dependency{{name: "catalog", check: func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}}}
+	if err := waitForDeps(context.Background(), deps, 5*time.Second, time.Millisecond); err != nil {
+		t.Fatalf("waitForDeps: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("check ran %d times, want 3", calls)
+	}
+}
+
+func TestWaitForDepsGivesUp(t *testing.T) {
+	deps := []dependency{
+		{name: "consul", check: func(context.Context) error { return nil }},
+		{name: "catalog", check: func(context.Context) error { return errors.New("connection refused") }},
+	}
+	err := waitForDeps(context.Background(), deps, 30*time.Millisecond, 5*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "catalog not ready") || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("waitForDeps = %v, want the catalog's last error", err)
+	}
+}
+
+func TestStartupDependencies(t *testing.T) {
+	catalog := &stubCatalog{}
+	t.Setenv("CONSUL_HTTP_ADDR", "")
+	if deps := startupDependencies(catalog); len(deps) != 1 || deps[0].name != "catalog" {
+		t.Errorf("without Consul = %+v, want only the catalog", deps)
+	}
+	t.Setenv("CONSUL_HTTP_ADDR", "127.0.0.1:8500")
+	if deps := startupDependencies(catalog); len(deps) != 2 || deps[0].name != "consul" {
+		t.Errorf("with Consul = %+v, want Consul first", deps)
+	}
+}
+
+func TestResolveWaitForDeps(t *testing.T) {
+	t.Setenv("WAIT_FOR_DEPS", "")
+	t.Setenv("WAIT_FOR_DEPS_TIMEOUT", "")
+	if on, d, err := resolveWaitForDeps(); on || d != defaultWaitForDepsTimeout || err != nil {
+		t.Errorf("default = %v, %s, %v", on, d, err)
+	}
+	t.Setenv("WAIT_FOR_DEPS", "true")
+	t.Setenv("WAIT_FOR_DEPS_TIMEOUT", "2m")
+	if on, d, err := resolveWaitForDeps(); !on || d != 2*time.Minute || err != nil {
+		t.Errorf("WAIT_FOR_DEPS=true WAIT_FOR_DEPS_TIMEOUT=2m = %v, %s, %v", on, d, err)
+	}
+	for name, v := range map[string]string{"WAIT_FOR_DEPS": "maybe", "WAIT_FOR_DEPS_TIMEOUT": "0s"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, v)
+			if _, _, err := resolveWaitForDeps(); err == nil {
+				t.Errorf("%s=%s accepted", name, v)
+			}
+		})
+	}
+}
+
+func TestPingConsul(t *testing.T) {
+	leader := `"10.0.0.1:8300"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/status/leader" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, leader)
+	}))
+	defer srv.Close()
+	prev := consulClient
+	consulClient = func() (*api.Client, error) { return api.NewClient(&api.Config{Address: srv.URL}) }
+	t.Cleanup(func() { consulClient = prev })
+
+	if err := pingConsul(context.Background()); err != nil {
+		t.Fatalf("pingConsul with a leader: %v", err)
+	}
+	leader = `""`
+	if err := pingConsul(context.Background()); err == nil {
+		t.Fatal("pingConsul succeeded without a cluster leader")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "net/http"
+
+// OrderStats is the aggregate served by GET /orders/stats. Soft-deleted
+// orders are left out, as they are from listings.
+type OrderStats struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// newOrderStats returns empty stats with every known status at zero, so
+// dashboards see a stable set of keys.
+func newOrderStats() OrderStats {
+	stats := OrderStats{ByStatus: make(map[string]int, len(transitions))}
+	for status := range transitions {
+		stats.ByStatus[status] = 0
+	}
+	return stats
+}
+
+// add counts n orders in status.
+func (s *OrderStats) add(status string, n int) {
+	s.Total += n
+	s.ByStatus[status] += n
+}
+
+// getOrderStats answers GET /orders/stats.
+func (h *handler) getOrderStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.store.Stats()
+	if err != nil {
+		requestLogger(r.Context()).Error("computing order stats", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, CodeInternal, "failed to compute order stats")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
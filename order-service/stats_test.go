@@ -0,0 +1,74 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"testing"
+)
+
+func TestGetOrderStats(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+
+	rec := serve(t, routes, http.MethodGet, "/orders/stats", "")
+	wantStatus(t, rec, http.StatusOK)
+	empty := decodeBody[OrderStats](t, rec)
+	if empty.Total != 0 || len(empty.ByStatus) != len(transitions) {
+		t.Errorf("empty stats = %+v, want every status at zero", empty)
+	}
+
+	var ids []string
+	for range 4 {
+		ids = append(ids, createTestOrder(t, routes, simpleOrder).ID)
+	}
+	for _, step := range []struct{ id, status string }{
+		{ids[1], StatusPreparing},
+		{ids[2], StatusPreparing},
+		{ids[2], StatusReady},
+		{ids[3], StatusCancelled},
+	} {
+		rec := serve(t, routes, http.MethodPatch, "/orders/"+step.id+"/status", `{"status":"`+step.status+`"}`)
+		wantStatus(t, rec, http.StatusOK)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/stats", "")
+	stats := decodeBody[OrderStats](t, rec)
+	want := map[string]int{StatusReceived: 1, StatusPreparing: 1, StatusReady: 1, StatusDelivered: 0, StatusCancelled: 1}
+	if stats.Total != 4 || !maps.Equal(stats.ByStatus, want) {
+		t.Errorf("stats = %+v, want 4 orders by %v", stats, want)
+	}
+
+	wantStatus(t, serve(t, routes, http.MethodDelete, "/orders/"+ids[0], ""), http.StatusNoContent)
+	rec = serve(t, routes, http.MethodGet, "/orders/stats", "")
+	stats = decodeBody[OrderStats](t, rec)
+	if stats.Total != 3 || stats.ByStatus[StatusReceived] != 0 {
+		t.Errorf("stats after delete = %+v, want the deleted order left out", stats)
+	}
+}
+
+func TestStoreStats(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		for _, id := range []string{"a", "b", "c"} {
+			if err := s.Save(storedOrder(id)); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+		}
+		if _, err := s.UpdateStatus("b", StatusPreparing, testTime); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		deleted := storedOrder("d")
+		deleted.Deleted = true
+		s.Save(deleted)
+		if err := s.Delete("c"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+
+		stats, err := s.Stats()
+		if err != nil {
+			t.Fatalf("Stats: %v", err)
+		}
+		if stats.Total != 2 || stats.ByStatus[StatusReceived] != 1 || stats.ByStatus[StatusPreparing] != 1 || stats.ByStatus[StatusReady] != 0 {
+			t.Errorf("Stats = %+v, want one received and one preparing", stats)
+		}
+	})
+}
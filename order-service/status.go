@@ -0,0 +1,39 @@
+package main
+
+import "slices"
+
+const (
+	StatusReceived  = "received"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+)
+
+// transitions lists the statuses each status may move to next.
+var transitions = map[string][]string{
+	StatusReceived:  {StatusPreparing, StatusCancelled},
+	StatusPreparing: {StatusReady},
+	StatusReady:     {StatusDelivered},
+	StatusDelivered: {},
+	StatusCancelled: {},
+}
+
+// allowedTransitions returns the statuses reachable from the given status.
+func allowedTransitions(from string) []string {
+	next, ok := transitions[from]
+	if !ok {
+		return []string{}
+	}
+	return next
+}
+
+// isTerminal reports whether an order in status has finished its workflow.
+func isTerminal(status string) bool {
+	next, ok := transitions[status]
+	return ok && len(next) == 0
+}
+
+func canTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
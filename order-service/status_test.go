@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusReceived, StatusPreparing, true},
+		{StatusReceived, StatusCancelled, true},
+		{StatusPreparing, StatusReady, true},
+		{StatusReady, StatusDelivered, true},
+		{StatusReceived, StatusDelivered, false},
+		{StatusPreparing, StatusCancelled, false},
+		{StatusDelivered, StatusReceived, false},
+		{StatusCancelled, StatusPreparing, false},
+		{StatusReceived, "teleported", false},
+	} {
+		if got := canTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("canTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+	if !isTerminal(StatusDelivered) || !isTerminal(StatusCancelled) || isTerminal(StatusReady) || isTerminal("unknown") {
+		t.Error("isTerminal disagrees with the workflow")
+	}
+}
+
+func TestUpdateStatusWalksWorkflow(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	for _, status := range []string{StatusPreparing, StatusReady, StatusDelivered} {
+		rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"`+status+`"}`)
+		wantStatus(t, rec, http.StatusOK)
+		if got := decodeBody[Order](t, rec); got.Status != status {
+			t.Fatalf("status = %q, want %q", got.Status, status)
+		}
+	}
+}
+
+func TestUpdateStatusRejectsInvalidTransition(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	order := createTestOrder(t, routes, simpleOrder)
+
+	rec := serve(t, routes, http.MethodPatch, "/orders/"+order.ID+"/status", `{"status":"delivered"}`)
+	wantError(t, rec, http.StatusConflict, CodeInvalidTransition)
+	body := decodeBody[struct {
+		Allowed []string `json:"allowed"`
+	}](t, rec)
+	if !slices.Equal(body.Allowed, []string{StatusPreparing, StatusCancelled}) {
+		t.Errorf("allowed = %v", body.Allowed)
+	}
+
+	rec = serve(t, routes, http.MethodGet, "/orders/"+order.ID, "")
+	if got := decodeBody[Order](t, rec); got.Status != StatusReceived {
+		t.Errorf("status after a refused transition = %q", got.Status)
+	}
+}
+
+func TestUpdateStatusNotFound(t *testing.T) {
+	rec := serve(t, newTestHandler(t).routes(), http.MethodPatch, "/orders/missing/status", `{"status":"preparing"}`)
+	wantError(t, rec, http.StatusNotFound, CodeOrderNotFound)
+}
+
+func TestStoreUpdateStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if _, err := s.Create(storedOrder("a")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		at := testTime.Add(5 * time.Minute)
+		o, err := s.UpdateStatus("a", StatusPreparing, at)
+		if err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		if o.Status != StatusPreparing || !o.UpdatedAt.Equal(at) || len(o.StatusHistory) != 2 {
+			t.Errorf("updated order = %+v", o)
+		}
+		if _, err := s.UpdateStatus("a", StatusDelivered, at); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("preparing -> delivered = %v, want ErrInvalidTransition", err)
+		}
+		if _, err := s.UpdateStatus("missing", StatusPreparing, at); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing order = %v, want ErrNotFound", err)
+		}
+	})
+}
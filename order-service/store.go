@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OrderStore is the persistence layer used by the order handlers.
+// Methods that look an order up return ErrNotFound when there is none.
+type OrderStore interface {
+	Save(o Order) error
+	// Create saves a new order under the next OrderNumber and returns it
+	// as stored, or ErrAlreadyExists when its id is already taken.
+	Create(o Order) (Order, error)
+	Get(id string) (Order, error)
+	// GetByNumber finds an order by its OrderNumber.
+	GetByNumber(n int64) (Order, error)
+	All() []Order
+	Delete(id string) error
+	// UpdateStatus moves an order to status, stamping UpdatedAt with at,
+	// and returns the result. The workflow is checked against the stored
+	// status, so it fails with ErrInvalidTransition when the order has
+	// moved on since the caller last read it.
+	UpdateStatus(id, status string, at time.Time) (Order, error)
+	// Update loads an order, applies change to it and saves the result as
+	// one step, so nothing written in between is lost. When change returns
+	// an error the order is left as it was and the error is returned.
+	// change must not call back into the store.
+	Update(id string, change func(*Order) error) (Order, error)
+	// Clear removes every order, soft-deleted ones included, and reports
+	// how many there were.
+	Clear() (int, error)
+	// Stats counts the orders that are not soft-deleted, by status.
+	Stats() (OrderStats, error)
+}
+
+// Errors returned by OrderStore implementations, possibly wrapped with the
+// order they concern. statusFromError maps them onto HTTP statuses.
+var (
+	ErrNotFound          = errors.New("order not found")
+	ErrAlreadyExists     = errors.New("order already exists")
+	ErrInvalidTransition = errors.New("cannot transition order")
+)
+
+// checkTransition returns an error wrapping ErrInvalidTransition unless an
+// order may move from one status to the other.
+func checkTransition(from, to string) error {
+	if !canTransition(from, to) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// moveTo is the change UpdateStatus applies: it checks the workflow against
+// the order's current status and records the transition.
+func moveTo(status string, at time.Time) func(*Order) error {
+	return func(o *Order) error {
+		if err := checkTransition(o.Status, status); err != nil {
+			return err
+		}
+		o.recordTransition(status, at)
+		return nil
+	}
+}
+
+const defaultMaxOrders = 10000
+
+// MemoryStore keeps orders in memory and is safe for concurrent use. Once it
+// holds maxOrders orders, saving a new one evicts the oldest by CreatedAt so
+// a long-running pod cannot grow without bound. The zero value is an empty
+// store bounded at defaultMaxOrders.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	orders    map[string]Order
+	maxOrders int
+	// lastNumber is the most recently assigned OrderNumber. It is never
+	// reset, so numbers stay unique even after eviction or Clear.
+	lastNumber int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewBoundedMemoryStore(defaultMaxOrders)
+}
+
+// NewBoundedMemoryStore returns a MemoryStore holding at most maxOrders;
+// a non-positive maxOrders means defaultMaxOrders.
+func NewBoundedMemoryStore(maxOrders int) *MemoryStore {
+	return &MemoryStore{orders: make(map[string]Order), maxOrders: maxOrders}
+}
+
+func (s *MemoryStore) Save(o Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(o)
+	return nil
+}
+
+func (s *MemoryStore) Create(o Order) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.orders[o.ID]; exists {
+		return Order{}, fmt.Errorf("%w: %s", ErrAlreadyExists, o.ID)
+	}
+	s.lastNumber++
+	o.OrderNumber = s.lastNumber
+	s.put(o)
+	return o, nil
+}
+
+// put stores o, evicting the oldest order first if o is new and the store
+// is full. It initialises the map so a zero-value store is usable. The
+// caller holds s.mu.
+func (s *MemoryStore) put(o Order) {
+	if s.orders == nil {
+		s.orders = make(map[string]Order)
+	}
+	limit := s.maxOrders
+	if limit < 1 {
+		limit = defaultMaxOrders
+	}
+	if _, exists := s.orders[o.ID]; !exists && len(s.orders) >= limit {
+		s.evictOldest()
+	}
+	s.orders[o.ID] = o
+}
+
+// evictOldest drops the order created first. The caller holds s.mu.
+func (s *MemoryStore) evictOldest() {
+	var oldest Order
+	found := false
+	for _, o := range s.orders {
+		if !found || o.CreatedAt.Before(oldest.CreatedAt) {
+			oldest, found = o, true
+		}
+	}
+	if found {
+		delete(s.orders, oldest.ID)
+		log.Printf("Order store full (%d orders), evicted oldest order %s", len(s.orders), oldest.ID)
+	}
+}
+
+func (s *MemoryStore) Get(id string) (Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.orders[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	return o, nil
+}
+
+func (s *MemoryStore) GetByNumber(n int64) (Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, o := range s.orders {
+		if o.OrderNumber == n {
+			return o, nil
+		}
+	}
+	return Order{}, ErrNotFound
+}
+
+// All returns every stored order sorted by ID so listings are stable.
+func (s *MemoryStore) All() []Order {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	all := make([]Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		all = append(all, o)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// Delete removes the order with the given id for good.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.orders, id)
+	return nil
+}
+
+// Stats counts orders under the read lock rather than copying them out.
+func (s *MemoryStore) Stats() (OrderStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := newOrderStats()
+	for _, o := range s.orders {
+		if !o.Deleted {
+			stats.add(o.Status, 1)
+		}
+	}
+	return stats, nil
+}
+
+func (s *MemoryStore) Clear() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.orders)
+	s.orders = make(map[string]Order)
+	return n, nil
+}
+
+func (s *MemoryStore) UpdateStatus(id, status string, at time.Time) (Order, error) {
+	return s.Update(id, moveTo(status, at))
+}
+
+func (s *MemoryStore) Update(id string, change func(*Order) error) (Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.orders[id]
+	if !ok {
+		return Order{}, ErrNotFound
+	}
+	if err := change(&o); err != nil {
+		return Order{}, err
+	}
+	s.orders[id] = o
+	return o, nil
+}
+
+// resolveMaxOrders reads MAX_ORDERS, defaulting to 10000. It bounds the
+// in-memory store and how many orders' history the event log keeps;
+// persistent backends keep every order.
+func resolveMaxOrders() (int, error) {
+	v := setting("MAX_ORDERS")
+	if v == "" {
+		return defaultMaxOrders, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid MAX_ORDERS %q: must be a positive integer", v)
+	}
+	return n, nil
+}
+
+// resolveOrderStore reads ORDER_STORE ("sqlite" by default, or "memory")
+// and DB_PATH, which defaults to orders.db.
+func resolveOrderStore() (backend, dbPath string, err error) {
+	dbPath = setting("DB_PATH")
+	if dbPath == "" {
+		dbPath = "orders.db"
+	}
+	switch backend = setting("ORDER_STORE"); backend {
+	case "", "sqlite":
+		return "sqlite", dbPath, nil
+	case "memory":
+		return backend, dbPath, nil
+	default:
+		return "", "", fmt.Errorf("invalid ORDER_STORE %q: must be sqlite or memory", backend)
+	}
+}
+
+// openOrderStore builds the store selected by cfg: SQLite at DBPath, or a
+// memory store bounded by MaxOrders. The returned func releases the store.
+func openOrderStore(cfg Config) (OrderStore, func() error, error) {
+	if cfg.OrderStore == "memory" {
+		return NewBoundedMemoryStore(cfg.MaxOrders), func() error { return nil }, nil
+	}
+	s, err := NewSQLiteStore(cfg.DBPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, s.Close, nil
+}
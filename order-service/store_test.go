@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// forEachStore runs test against a fresh MemoryStore and SQLiteStore.
+func forEachStore(t *testing.T, test func(t *testing.T, s OrderStore)) {
+	t.Run("memory", func(t *testing.T) { test(t, NewMemoryStore()) })
+	t.Run("sqlite", func(t *testing.T) { test(t, newTestSQLiteStore(t)) })
+}
+
+// storedOrder returns a received order created at testTime.
+func storedOrder(id string) Order {
+	return Order{
+		ID:            id,
+		CustomerID:    "c1",
+		Items:         []LineItem{{ItemID: "1", Quantity: 1}},
+		Status:        StatusReceived,
+		CreatedAt:     testTime,
+		UpdatedAt:     testTime,
+		StatusHistory: []statusChange{{Status: StatusReceived, At: testTime}},
+	}
+}
+
+func TestStoreSaveGetDelete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if err := s.Save(storedOrder("b")); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if err := s.Save(storedOrder("a")); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		got, err := s.Get("a")
+		if err != nil || got.ID != "a" || got.CustomerID != "c1" {
+			t.Fatalf("Get = %+v, %v", got, err)
+		}
+		if all := s.All(); len(all) != 2 || all[0].ID != "a" || all[1].ID != "b" {
+			t.Fatalf("All = %+v, want a then b", all)
+		}
+		if err := s.Delete("a"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+		}
+		if err := s.Delete("a"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second Delete = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// Run with -race: concurrent handlers share one MemoryStore.
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	s := NewMemoryStore()
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("o%d", i)
+			s.Save(storedOrder(id))
+			s.Get(id)
+			s.All()
+			s.UpdateStatus(id, StatusPreparing, testTime)
+		}()
+	}
+	wg.Wait()
+	if n := len(s.All()); n != 20 {
+		t.Fatalf("stored %d orders, want 20", n)
+	}
+}
+
+func TestStoreUpdate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if _, err := s.Create(storedOrder("a")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		updated, err := s.Update("a", func(o *Order) error {
+			o.AssignedTo = "grill"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if updated.AssignedTo != "grill" {
+			t.Errorf("returned AssignedTo = %q, want grill", updated.AssignedTo)
+		}
+		got, err := s.Get("a")
+		if err != nil || got.AssignedTo != "grill" {
+			t.Errorf("Get = %+v, %v; want AssignedTo grill", got, err)
+		}
+	})
+}
+
+func TestStoreUpdateChangeErrorLeavesOrder(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if _, err := s.Create(storedOrder("a")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		refused := errors.New("refused")
+		_, err := s.Update("a", func(o *Order) error {
+			o.AssignedTo = "grill"
+			return refused
+		})
+		if !errors.Is(err, refused) {
+			t.Fatalf("Update error = %v, want %v", err, refused)
+		}
+		if got, _ := s.Get("a"); got.AssignedTo != "" {
+			t.Errorf("AssignedTo = %q after a refused change, want it unchanged", got.AssignedTo)
+		}
+	})
+}
+
+func TestStoreUpdateMissing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		called := false
+		_, err := s.Update("missing", func(*Order) error {
+			called = true
+			return nil
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update error = %v, want ErrNotFound", err)
+		}
+		if called {
+			t.Error("change was called for a missing order")
+		}
+	})
+}
+
+// The change sees whatever was written last, so a status change landing
+// between a caller's read and its update is not overwritten.
+func TestStoreUpdateSeesLatestStatus(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if _, err := s.Create(storedOrder("a")); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := s.UpdateStatus("a", StatusPreparing, testTime.Add(time.Minute)); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		var seen string
+		if _, err := s.Update("a", func(o *Order) error {
+			seen = o.Status
+			return nil
+		}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if seen != StatusPreparing {
+			t.Errorf("change saw status %q, want %q", seen, StatusPreparing)
+		}
+	})
+}
+
+func TestResolveOrderStore(t *testing.T) {
+	t.Setenv("ORDER_STORE", "")
+	t.Setenv("DB_PATH", "")
+	if backend, path, err := resolveOrderStore(); backend != "sqlite" || path != "orders.db" || err != nil {
+		t.Errorf("default = %q, %q, %v", backend, path, err)
+	}
+	t.Setenv("ORDER_STORE", "memory")
+	t.Setenv("DB_PATH", "/data/o.db")
+	if backend, path, err := resolveOrderStore(); backend != "memory" || path != "/data/o.db" || err != nil {
+		t.Errorf("memory = %q, %q, %v", backend, path, err)
+	}
+	t.Setenv("ORDER_STORE", "postgres")
+	if _, _, err := resolveOrderStore(); err == nil {
+		t.Error("ORDER_STORE=postgres accepted")
+	}
+}
+
+var (
+	_ OrderStore = (*MemoryStore)(nil)
+	_ OrderStore = (*SQLiteStore)(nil)
+)
+
+func TestStoreCreate(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		created, err := s.Create(storedOrder("a"))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if got, err := s.Get("a"); err != nil || got.ID != created.ID {
+			t.Errorf("Get = %+v, %v; want the created order", got, err)
+		}
+		if _, err := s.Create(storedOrder("a")); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("duplicate Create = %v, want ErrAlreadyExists", err)
+		}
+	})
+}
+
+func TestStoreOrderNumbers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		first, err := s.Create(storedOrder("a"))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		second, err := s.Create(storedOrder("b"))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if first.OrderNumber != 1 || second.OrderNumber != 2 {
+			t.Errorf("order numbers = %d, %d; want 1, 2", first.OrderNumber, second.OrderNumber)
+		}
+		if got, err := s.GetByNumber(2); err != nil || got.ID != "b" {
+			t.Errorf("GetByNumber(2) = %+v, %v; want b", got, err)
+		}
+		if _, err := s.GetByNumber(9); !errors.Is(err, ErrNotFound) {
+			t.Errorf("GetByNumber(9) = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// Numbers are not reused after a clear.
+func TestStoreClearKeepsOrderNumbers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		s.Create(storedOrder("a"))
+		s.Create(storedOrder("b"))
+		if _, err := s.Clear(); err != nil {
+			t.Fatalf("Clear: %v", err)
+		}
+		if o, _ := s.Create(storedOrder("c")); o.OrderNumber != 3 {
+			t.Errorf("order number after Clear = %d, want 3", o.OrderNumber)
+		}
+	})
+}
+
+func TestStoreKeepsTimestamps(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		if err := s.Save(storedOrder("a")); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		later := testTime.Add(time.Hour)
+		if _, err := s.UpdateStatus("a", StatusPreparing, later); err != nil {
+			t.Fatalf("UpdateStatus: %v", err)
+		}
+		got, err := s.Get("a")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !got.CreatedAt.Equal(testTime) || !got.UpdatedAt.Equal(later) {
+			t.Fatalf("created_at = %s, updated_at = %s; want %s and %s", got.CreatedAt, got.UpdatedAt, testTime, later)
+		}
+	})
+}
+
+func TestBoundedMemoryStoreEvictsOldest(t *testing.T) {
+	s := NewBoundedMemoryStore(2)
+	for i, id := range []string{"b", "a", "c"} {
+		o := storedOrder(id)
+		o.CreatedAt = testTime.Add(time.Duration(i) * time.Minute)
+		if err := s.Save(o); err != nil {
+			t.Fatalf("Save(%s): %v", id, err)
+		}
+	}
+	if _, err := s.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(b) = %v, want the oldest order evicted", err)
+	}
+	if len(s.All()) != 2 {
+		t.Errorf("store holds %d orders, want 2", len(s.All()))
+	}
+
+	// Updating an order already stored makes no room.
+	a, _ := s.Get("a")
+	a.Notes = "extra napkins"
+	if err := s.Save(a); err != nil {
+		t.Fatalf("Save(a): %v", err)
+	}
+	if _, err := s.Get("c"); err != nil {
+		t.Errorf("re-saving a evicted c: %v", err)
+	}
+}
+
+func TestResolveMaxOrders(t *testing.T) {
+	t.Setenv("MAX_ORDERS", "")
+	if n, err := resolveMaxOrders(); n != defaultMaxOrders || err != nil {
+		t.Errorf("default = %d, %v; want %d", n, err, defaultMaxOrders)
+	}
+	t.Setenv("MAX_ORDERS", "500")
+	if n, err := resolveMaxOrders(); n != 500 || err != nil {
+		t.Errorf("MAX_ORDERS=500 = %d, %v", n, err)
+	}
+	for _, v := range []string{"0", "-1", "lots"} {
+		t.Setenv("MAX_ORDERS", v)
+		if _, err := resolveMaxOrders(); err == nil {
+			t.Errorf("MAX_ORDERS=%q accepted", v)
+		}
+	}
+}
+
+func TestStoreClear(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		s.Save(storedOrder("a"))
+		deleted := storedOrder("b")
+		deleted.Deleted = true
+		s.Save(deleted)
+		if n, err := s.Clear(); n != 2 || err != nil {
+			t.Fatalf("Clear = %d, %v; want 2", n, err)
+		}
+		if all := s.All(); len(all) != 0 {
+			t.Errorf("All after Clear = %d orders", len(all))
+		}
+		if n, _ := s.Clear(); n != 0 {
+			t.Errorf("second Clear = %d, want 0", n)
+		}
+	})
+}
+
+func TestZeroValueMemoryStore(t *testing.T) {
+	var s MemoryStore
+	if _, err := s.Get("o1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get on an empty store = %v, want ErrNotFound", err)
+	}
+	if err := s.Save(storedOrder("o1")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := s.Get("o1"); err != nil {
+		t.Fatalf("Get after Save: %v", err)
+	}
+
+	var c MemoryStore
+	if o, err := c.Create(storedOrder("o2")); err != nil || o.OrderNumber != 1 {
+		t.Fatalf("Create = %+v, %v; want order number 1", o, err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	streamBuffer            = 16
+	streamHeartbeatInterval = 15 * time.Second
+)
+
+// streamFrame is one server-sent event: the history entry plus the order as
+// it looked right after the change.
+type streamFrame struct {
+	OrderEvent
+	Order Order `json:"order"`
+}
+
+// orderBroker fans order events out to every connected stream subscriber.
+type orderBroker struct {
+	mu   sync.Mutex
+	subs map[chan streamFrame]struct{}
+	// closed ends every stream, so they do not hold up shutdown.
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newOrderBroker() *orderBroker {
+	return &orderBroker{subs: make(map[chan streamFrame]struct{}), closed: make(chan struct{})}
+}
+
+// close tells every stream, current and future, to end.
+func (b *orderBroker) close() {
+	b.closeOnce.Do(func() { close(b.closed) })
+}
+
+// subscribe registers a new listener; call the returned func to detach it.
+func (b *orderBroker) subscribe() (<-chan streamFrame, func()) {
+	ch := make(chan streamFrame, streamBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish delivers f to every subscriber without blocking; a subscriber
+// that has fallen streamBuffer frames behind misses the frame.
+func (b *orderBroker) publish(f streamFrame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- f:
+		default:
+		}
+	}
+}
+
+// streamOrders holds the connection open and sends an SSE frame for every
+// order change until the client goes away or the server shuts down.
+func (h *handler) streamOrders(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	frames, unsubscribe := h.broker.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		requestLogger(r.Context()).Error("streaming not supported", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.broker.closed:
+			return
+		case <-heartbeat.C:
+			// Comment lines keep idle proxies from closing the stream.
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case f := <-frames:
+			data, err := json.Marshal(f)
+			if err != nil {
+				requestLogger(r.Context()).Error("encoding stream frame", "order_id", f.OrderID, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", f.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
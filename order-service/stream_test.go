@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamOrders(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	srv := httptest.NewServer(routes)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/orders/stream")
+	if err != nil {
+		t.Fatalf("GET /orders/stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// The subscription is in place once the headers have been flushed.
+	order := createTestOrder(t, routes, simpleOrder)
+
+	lines := bufio.NewReader(resp.Body)
+	event, err := lines.ReadString('\n')
+	if err != nil || event != "event: "+EventOrderCreated+"\n" {
+		t.Fatalf("first line = %q, %v", event, err)
+	}
+	data, _ := lines.ReadString('\n')
+	var frame streamFrame
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &frame); err != nil {
+		t.Fatalf("decoding %q: %v", data, err)
+	}
+	if frame.Type != EventOrderCreated || frame.OrderID != order.ID || frame.Order.ID != order.ID {
+		t.Errorf("frame = %+v", frame)
+	}
+
+	// Shutdown ends the stream rather than waiting for the client.
+	h.broker.close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, lines)
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream stayed open after the broker closed")
+	}
+}
+
+func TestOrderBrokerSkipsSlowSubscribers(t *testing.T) {
+	b := newOrderBroker()
+	frames, unsubscribe := b.subscribe()
+	for range streamBuffer + 5 {
+		b.publish(streamFrame{OrderEvent: OrderEvent{Type: EventOrderCreated}})
+	}
+	if len(frames) != streamBuffer {
+		t.Errorf("buffered %d frames, want %d", len(frames), streamBuffer)
+	}
+
+	unsubscribe()
+	b.publish(streamFrame{})
+	if len(frames) != streamBuffer {
+		t.Error("unsubscribed channel still receives frames")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "strings"
+
+// Limits on the labels an order may carry.
+const (
+	maxTagsPerOrder = 10
+	maxTagLength    = 32
+)
+
+// normalizeTags trims and lower-cases tags and drops duplicates and empty
+// entries, keeping the first occurrence of each.
+func normalizeTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	return normalized
+}
+
+// validTag reports whether a normalized tag is 1-maxTagLength lower-case
+// letters, digits, '-' or '_'.
+func validTag(t string) bool {
+	if t == "" || len(t) > maxTagLength {
+		return false
+	}
+	for _, c := range t {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got := normalizeTags([]string{" Promo", "delivery", "PROMO", "", "  ", "dine-in"})
+	if want := []string{"promo", "delivery", "dine-in"}; !slices.Equal(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+	if got := normalizeTags(nil); got != nil {
+		t.Errorf("normalizeTags(nil) = %v, want nil", got)
+	}
+}
+
+func TestValidTag(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"promo":                             true,
+		"dine-in":                           true,
+		"table_12":                          true,
+		"":                                  false,
+		"two words":                         false,
+		"café":                              false,
+		strings.Repeat("a", maxTagLength+1): false,
+	} {
+		if got := validTag(tag); got != want {
+			t.Errorf("validTag(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
+
+func TestCreateOrderTags(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	o := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":["Promo"," delivery ","promo"]}`)
+	if want := []string{"promo", "delivery"}; !slices.Equal(o.Tags, want) {
+		t.Errorf("tags = %v, want %v", o.Tags, want)
+	}
+
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":["ok","not ok"]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+	if d := decodeBody[errorResponse](t, rec).Details; len(d) != 1 || d[0].Field != "tags[1]" {
+		t.Errorf("details = %+v, want tags[1]", d)
+	}
+
+	many := make([]string, maxTagsPerOrder+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("%q", fmt.Sprintf("t%d", i))
+	}
+	rec = serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":[`+strings.Join(many, ",")+`]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestListOrdersFiltersByTag(t *testing.T) {
+	h := newTestHandler(t)
+	routes := h.routes()
+	first := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":["promo","delivery"]}`)
+	second := createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":["promo"]}`)
+	createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"tags":["dine-in"]}`)
+
+	rec := serve(t, routes, http.MethodGet, "/orders?tag=PROMO", "")
+	wantStatus(t, rec, http.StatusOK)
+	var ids []string
+	for _, o := range decodeBody[[]Order](t, rec) {
+		ids = append(ids, o.ID)
+	}
+	slices.Sort(ids)
+	want := []string{first.ID, second.ID}
+	slices.Sort(want)
+	if !slices.Equal(ids, want) {
+		t.Errorf("?tag=PROMO = %v, want %v", ids, want)
+	}
+
+	if _, err := h.store.UpdateStatus(second.ID, StatusPreparing, testTime); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	rec = serve(t, routes, http.MethodGet, "/orders?tag=promo&status=preparing", "")
+	if got := decodeBody[[]Order](t, rec); len(got) != 1 || got[0].ID != second.ID {
+		t.Errorf("?tag=promo&status=preparing = %+v, want only %s", got, second.ID)
+	}
+}
+
+func TestStoreKeepsTags(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s OrderStore) {
+		o := storedOrder("o1")
+		o.Tags = []string{"promo", "delivery"}
+		if err := s.Save(o); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if got, _ := s.Get("o1"); !slices.Equal(got.Tags, o.Tags) {
+			t.Errorf("Tags = %v, want %v", got.Tags, o.Tags)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const defaultRequestTimeout = 15 * time.Second
+
+// resolveRequestTimeout reads REQUEST_TIMEOUT (a Go duration such as "10s"),
+// defaulting to 15s.
+func resolveRequestTimeout() (time.Duration, error) {
+	v := setting("REQUEST_TIMEOUT")
+	if v == "" {
+		return defaultRequestTimeout, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid REQUEST_TIMEOUT %q: must be a positive duration", v)
+	}
+	return d, nil
+}
+
+// deadlineHeader carries the caller's own deadline as unix milliseconds,
+// typically set by an upstream gateway.
+const deadlineHeader = "X-Request-Deadline"
+
+// callerDeadline parses deadlineHeader, reporting false when it is absent or
+// malformed.
+func callerDeadline(r *http.Request) (time.Time, bool) {
+	ms, err := strconv.ParseInt(r.Header.Get(deadlineHeader), 10, 64)
+	if err != nil || ms <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// timeoutError describes a request whose context deadline passed: a 504 if
+// the caller's X-Request-Deadline set it, otherwise a 503.
+func timeoutError(ctx context.Context) *requestError {
+	if byCaller, _ := ctx.Value(callerDeadlineKey).(bool); byCaller {
+		return &requestError{status: http.StatusGatewayTimeout, code: CodeDeadlineExceeded, msg: "request deadline exceeded"}
+	}
+	return &requestError{status: http.StatusServiceUnavailable, code: CodeRequestTimeout, msg: "request timed out"}
+}
+
+// timeoutMiddleware gives each request a deadline of d, or the caller's
+// X-Request-Deadline if that is sooner; a malformed header is ignored.
+// Handlers and the catalog client observe the cancelled context. If the
+// deadline passes before anything was written, the client gets a 503, or
+// a 504 when it was the caller's deadline. A deadline already in the past
+// is rejected without running the handler.
+func timeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			deadline := time.Now().Add(d)
+			if t, ok := callerDeadline(r); ok && t.Before(deadline) {
+				deadline = t
+				ctx = context.WithValue(ctx, callerDeadlineKey, true)
+			}
+			ctx, cancel := context.WithDeadline(ctx, deadline)
+			defer cancel()
+			if ctx.Err() != nil {
+				writeRequestError(w, timeoutError(ctx))
+				return
+			}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			if ww.Status() == 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				requestLogger(ctx).Warn("request timed out", "timeout", d.String())
+				writeRequestError(w, timeoutError(ctx))
+			}
+		})
+	}
+}
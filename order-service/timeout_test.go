@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	slow := timeoutMiddleware(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	wantError(t, serve(t, slow, http.MethodGet, "/orders", ""), http.StatusServiceUnavailable, CodeRequestTimeout)
+
+	fast := timeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("handler context has no deadline")
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}))
+	rec := serve(t, fast, http.MethodGet, "/orders", "")
+	wantStatus(t, rec, http.StatusOK)
+}
+
+func TestTimeoutMiddlewareCallerDeadline(t *testing.T) {
+	ran := false
+	wait := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ran = true
+		<-r.Context().Done()
+	})
+	blocking := timeoutMiddleware(time.Second)(wait)
+	deadline := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).UnixMilli(), 10)
+	}
+
+	// A caller deadline sooner than REQUEST_TIMEOUT is the one that applies.
+	rec := serve(t, blocking, http.MethodGet, "/orders", "", deadlineHeader, deadline(20*time.Millisecond))
+	wantError(t, rec, http.StatusGatewayTimeout, CodeDeadlineExceeded)
+
+	// One already past is refused without running the handler.
+	ran = false
+	rec = serve(t, blocking, http.MethodGet, "/orders", "", deadlineHeader, deadline(-time.Second))
+	wantError(t, rec, http.StatusGatewayTimeout, CodeDeadlineExceeded)
+	if ran {
+		t.Error("handler ran for a request whose deadline had passed")
+	}
+
+	// A later caller deadline or a malformed one leaves REQUEST_TIMEOUT in
+	// charge.
+	short := timeoutMiddleware(20 * time.Millisecond)(wait)
+	rec = serve(t, short, http.MethodGet, "/orders", "", deadlineHeader, deadline(time.Hour))
+	wantError(t, rec, http.StatusServiceUnavailable, CodeRequestTimeout)
+	rec = serve(t, short, http.MethodGet, "/orders", "", deadlineHeader, "tomorrow")
+	wantError(t, rec, http.StatusServiceUnavailable, CodeRequestTimeout)
+}
+
+func TestCallerDeadline(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":              false,
+		"0":             false,
+		"-5":            false,
+		"soon":          false,
+		"1714564800000": true,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			r.Header.Set(deadlineHeader, header)
+		}
+		got, ok := callerDeadline(r)
+		if ok != want {
+			t.Errorf("callerDeadline(%q) ok = %v, want %v", header, ok, want)
+		}
+		if ok && !got.Equal(time.UnixMilli(1714564800000)) {
+			t.Errorf("callerDeadline(%q) = %v", header, got)
+		}
+	}
+}
+
+// A catalog call still waiting when REQUEST_TIMEOUT passes is abandoned and
+// the order is not placed.
+func TestCreateOrderTimesOut(t *testing.T) {
+	catalog := &stubCatalog{validate: func(ctx context.Context, _ []string) (map[string]catalogItem, error) {
+		<-ctx.Done()
+		return nil, fmt.Errorf("%w: %w", errCatalogUnavailable, ctx.Err())
+	}}
+	h := newTestHandler(t, WithCatalog(catalog), WithRequestTimeout(20*time.Millisecond))
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", simpleOrder)
+	wantError(t, rec, http.StatusServiceUnavailable, CodeRequestTimeout)
+	if orders := h.store.All(); len(orders) != 0 {
+		t.Errorf("timed-out order was stored: %+v", orders)
+	}
+}
+
+func TestResolveRequestTimeout(t *testing.T) {
+	t.Setenv("REQUEST_TIMEOUT", "")
+	if d, err := resolveRequestTimeout(); d != defaultRequestTimeout || err != nil {
+		t.Errorf("default = %s, %v; want %s", d, err, defaultRequestTimeout)
+	}
+	t.Setenv("REQUEST_TIMEOUT", "750ms")
+	if d, err := resolveRequestTimeout(); d != 750*time.Millisecond || err != nil {
+		t.Errorf("REQUEST_TIMEOUT=750ms = %s, %v", d, err)
+	}
+	for _, v := range []string{"0s", "-1s", "10"} {
+		t.Setenv("REQUEST_TIMEOUT", v)
+		if _, err := resolveRequestTimeout(); err == nil {
+			t.Errorf("REQUEST_TIMEOUT=%q accepted", v)
+		}
+	}
+}
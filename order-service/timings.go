@@ -0,0 +1,41 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// statusChange is one entry of an order's status history: the status it
+// moved to and when.
+type statusChange struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// recordTransition moves o to status at at and adds the change to its
+// history. Orders stored before the history was kept get their current
+// status seeded from CreatedAt first, so the time spent in it still counts.
+func (o *Order) recordTransition(status string, at time.Time) {
+	history := slices.Clip(o.StatusHistory)
+	if len(history) == 0 {
+		history = append(history, statusChange{Status: o.Status, At: o.CreatedAt})
+	}
+	o.StatusHistory = append(history, statusChange{Status: status, At: at})
+	o.Status = status
+	o.UpdatedAt = at
+}
+
+// statusTimings adds up the seconds spent in each status the history has
+// moved on from. The current status is left out: its time is still
+// running, and excluding it keeps the result, and the order's ETag, stable
+// between transitions.
+func statusTimings(history []statusChange) map[string]float64 {
+	if len(history) < 2 {
+		return nil
+	}
+	timings := make(map[string]float64, len(history)-1)
+	for i, c := range history[:len(history)-1] {
+		timings[c.Status] += history[i+1].At.Sub(c.At).Seconds()
+	}
+	return timings
+}
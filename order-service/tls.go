@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// resolveTLS reads TLS_CERT_FILE and TLS_KEY_FILE. Both must be set to serve
+// HTTPS, or neither for plain HTTP. The pair is loaded once here so a bad
+// certificate stops the service at startup instead of on first connection.
+func resolveTLS() (certFile, keyFile string, err error) {
+	certFile, keyFile = setting("TLS_CERT_FILE"), setting("TLS_KEY_FILE")
+	if certFile == "" && keyFile == "" {
+		return "", "", nil
+	}
+	if certFile == "" || keyFile == "" {
+		return "", "", errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return "", "", fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	return certFile, keyFile, nil
+}
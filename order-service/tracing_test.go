@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps every finished span,
+// restoring the previous one when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func TestServerSpansNamedByRoute(t *testing.T) {
+	spans := recordSpans(t)
+	routes := newTestHandler(t).routes()
+	serve(t, routes, http.MethodGet, "/orders/abc", "")
+
+	ended := spans.Ended()
+	if len(ended) == 0 {
+		t.Fatal("no span recorded")
+	}
+	if name := ended[len(ended)-1].Name(); name != "GET /orders/{id}" {
+		t.Errorf("span name = %q, want GET /orders/{id}", name)
+	}
+}
+
+func TestOutboundCallsPropagateTrace(t *testing.T) {
+	recordSpans(t)
+	if _, err := setupTracing(context.Background()); err != nil {
+		t.Fatalf("setupTracing: %v", err)
+	}
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	defer span.End()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := newOutboundClient(defaultCatalogTimeout).Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+
+	traceparent := <-got
+	if want := span.SpanContext().TraceID().String(); len(traceparent) < 35 || traceparent[3:35] != want {
+		t.Fatalf("traceparent = %q, want trace id %s", traceparent, want)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxItemsPerOrder caps how many items a single order may contain.
+const maxItemsPerOrder = 100
+
+// maxItemQuantity caps how many of one item an order may ask for, summed
+// over repeated lines, so totals, stock checks and prep estimates stay far
+// from overflowing.
+const maxItemQuantity = 1000
+
+// maxPriority is the most urgent priority an order may carry.
+const maxPriority = 10
+
+// maxOrderIDLength caps client-supplied order ids.
+const maxOrderIDLength = 64
+
+// fieldError is one problem with one field of a request body.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationErrors collects every fieldError found in a request, so clients
+// can fix them all in one go.
+type validationErrors []fieldError
+
+func (v validationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, fe := range v {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (v *validationErrors) add(field, format string, args ...any) {
+	*v = append(*v, fieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns v as an error, or nil if nothing was added.
+func (v validationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// validateNewOrder checks a client-submitted order before it is accepted and
+// reports every violation as validationErrors. The server assigns Status,
+// so clients must leave it empty. ID is optional: clients may supply their
+// own, otherwise the server generates one. Notes may hold at most
+// maxNotes characters and item ids at most maxItemID.
+func validateNewOrder(o Order, maxNotes, maxItemID int) error {
+	var errs validationErrors
+	if strings.TrimSpace(o.CustomerID) == "" {
+		errs.add("customer_id", "customer_id is required")
+	}
+	errs = append(errs, checkLineItems(o.Items, maxItemID)...)
+	if o.ID != "" && !validOrderID(o.ID) {
+		errs.add("id", "id must be 1-%d letters, digits, '-' or '_'", maxOrderIDLength)
+	}
+	if o.Currency != "" && !validCurrency(o.Currency) {
+		errs.add("currency", "currency must be a 3-letter ISO 4217 code such as USD")
+	}
+	if o.Priority < 0 || o.Priority > maxPriority {
+		errs.add("priority", "priority must be between 0 and %d", maxPriority)
+	}
+	if len(o.Tags) > maxTagsPerOrder {
+		errs.add("tags", "an order may carry at most %d tags", maxTagsPerOrder)
+	}
+	for i, t := range o.Tags {
+		if !validTag(t) {
+			errs.add(fmt.Sprintf("tags[%d]", i), "tag %q must be 1-%d letters, digits, '-' or '_'", t, maxTagLength)
+		}
+	}
+	if utf8.RuneCountInString(o.Notes) > maxNotes {
+		errs.add("notes", "notes must be at most %d characters", maxNotes)
+	}
+	if o.CallbackURL != "" {
+		if err := validateCallbackURL(o.CallbackURL); err != nil {
+			errs.add("callback_url", "%s", err)
+		}
+	}
+	if o.AssignedTo != "" {
+		errs.add("assigned_to", "assigned_to is set with POST /orders/{id}/assign")
+	}
+	if len(o.ItemChecks) > 0 {
+		errs.add("item_checks", "item_checks is set by the server and must not be set")
+	}
+	if len(o.StatusHistory) > 0 || len(o.Timings) > 0 {
+		errs.add("status_history", "status_history and timings are kept by the server and must not be set")
+	}
+	if o.Status != "" {
+		errs.add("status", "status is assigned by the server and must not be set")
+	}
+	if o.Deleted || o.DeletedAt != nil {
+		errs.add("deleted", "deleted is managed by the server and must not be set")
+	}
+	return errs.err()
+}
+
+// validateLineItems checks the items of a new or edited order.
+func validateLineItems(items []LineItem, maxItemID int) error {
+	return checkLineItems(items, maxItemID).err()
+}
+
+func checkLineItems(items []LineItem, maxItemID int) validationErrors {
+	var errs validationErrors
+	if len(items) == 0 {
+		errs.add("items", "order must contain at least one item")
+	}
+	if len(items) > maxItemsPerOrder {
+		errs.add("items", "order may contain at most %d items", maxItemsPerOrder)
+	}
+	ordered := make(map[string]int, len(items))
+	for i, li := range items {
+		if msg := checkItemID(li.ItemID, maxItemID); msg != "" {
+			errs.add(fmt.Sprintf("items[%d].item_id", i), "%s", msg)
+			continue
+		}
+		switch {
+		case li.Quantity < 1:
+			errs.add(fmt.Sprintf("items[%d].quantity", i), "quantity for item %s must be at least 1", li.ItemID)
+			continue
+		case li.Quantity > maxItemQuantity:
+			errs.add(fmt.Sprintf("items[%d].quantity", i), "quantity for item %s must be at most %d", li.ItemID, maxItemQuantity)
+			continue
+		}
+		// Each line is bounded by now, so the sum cannot overflow. Only the
+		// line that takes the item over the cap is reported.
+		before := ordered[li.ItemID]
+		ordered[li.ItemID] += li.Quantity
+		if before <= maxItemQuantity && ordered[li.ItemID] > maxItemQuantity {
+			errs.add(fmt.Sprintf("items[%d].quantity", i), "item %s is ordered more than %d times across its lines", li.ItemID, maxItemQuantity)
+		}
+	}
+	return errs
+}
+
+// validCurrency reports whether code looks like an ISO 4217 currency code:
+// three ASCII letters, in either case.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validOrderID reports whether id is acceptable as a client-supplied order id.
+func validOrderID(id string) bool {
+	if len(id) > maxOrderIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// validOrder returns an order that passes validateNewOrder.
+func validOrder() Order {
+	return Order{CustomerID: "c1", Items: []LineItem{{ItemID: "1", Quantity: 1}}}
+}
+
+func TestValidateNewOrder(t *testing.T) {
+	manyItems := make([]LineItem, maxItemsPerOrder+1)
+	for i := range manyItems {
+		manyItems[i] = LineItem{ItemID: "1", Quantity: 1}
+	}
+	for _, tc := range []struct {
+		name   string
+		change func(*Order)
+		field  string
+	}{
+		{"missing customer", func(o *Order) { o.CustomerID = " " }, "customer_id"},
+		{"no items", func(o *Order) { o.Items = nil }, "items"},
+		{"too many items", func(o *Order) { o.Items = manyItems }, "items"},
+		{"empty item id", func(o *Order) { o.Items[0].ItemID = "" }, "items[0].item_id"},
+		{"zero quantity", func(o *Order) { o.Items[0].Quantity = 0 }, "items[0].quantity"},
+		{"quantity over the cap", func(o *Order) { o.Items[0].Quantity = maxItemQuantity + 1 }, "items[0].quantity"},
+		{"overflowing quantity", func(o *Order) { o.Items[0].Quantity = math.MaxInt }, "items[0].quantity"},
+		{"repeated lines over the cap", func(o *Order) {
+			o.Items = []LineItem{{ItemID: "1", Quantity: maxItemQuantity}, {ItemID: "2", Quantity: 1}, {ItemID: "1", Quantity: 1}}
+		}, "items[2].quantity"},
+		{"status set", func(o *Order) { o.Status = StatusReady }, "status"},
+		{"bad id", func(o *Order) { o.ID = "has spaces" }, "id"},
+		{"deleted set", func(o *Order) { o.Deleted = true }, "deleted"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := validOrder()
+			tc.change(&o)
+			err := validateNewOrder(o, defaultMaxNotesLength, defaultMaxItemIDLength)
+			var errs validationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("validateNewOrder = %v, want validationErrors", err)
+			}
+			if errs[0].Field != tc.field {
+				t.Errorf("field = %q, want %q (%v)", errs[0].Field, tc.field, err)
+			}
+		})
+	}
+	if err := validateNewOrder(validOrder(), defaultMaxNotesLength, defaultMaxItemIDLength); err != nil {
+		t.Errorf("valid order rejected: %v", err)
+	}
+}
+
+func TestItemQuantityBoundary(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		items []LineItem
+		ok    bool
+	}{
+		{"at the cap", []LineItem{{ItemID: "1", Quantity: maxItemQuantity}}, true},
+		{"over the cap", []LineItem{{ItemID: "1", Quantity: maxItemQuantity + 1}}, false},
+		{"lines summing to the cap", []LineItem{{ItemID: "1", Quantity: maxItemQuantity - 1}, {ItemID: "1", Quantity: 1}}, true},
+		{"lines summing past the cap", []LineItem{{ItemID: "1", Quantity: maxItemQuantity}, {ItemID: "1", Quantity: 1}}, false},
+		{"other items do not count", []LineItem{{ItemID: "1", Quantity: maxItemQuantity}, {ItemID: "2", Quantity: maxItemQuantity}}, true},
+	} {
+		errs := checkLineItems(tc.items, defaultMaxItemIDLength)
+		if ok := len(errs) == 0; ok != tc.ok {
+			t.Errorf("%s: errors %v, want ok=%v", tc.name, errs, tc.ok)
+		}
+		if len(errs) > 1 {
+			t.Errorf("%s: %d errors, want the cap reported once", tc.name, len(errs))
+		}
+	}
+}
+
+func TestCreateOrderRejectsHugeQuantity(t *testing.T) {
+	h := newTestHandler(t)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"1","quantity":9223372036854775807}]}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+	if details := decodeBody[errorResponse](t, rec).Details; len(details) != 1 || details[0].Field != "items[0].quantity" {
+		t.Errorf("details = %+v, want items[0].quantity", details)
+	}
+	if len(h.store.All()) != 0 {
+		t.Error("order with an overflowing quantity was stored")
+	}
+}
+
+func TestCreateOrderValidationFailed(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[],"status":"ready"}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "at least one item") {
+		t.Errorf("error = %q, want it to explain the problem", msg)
+	}
+}
+
+func TestCreateOrderReportsEveryViolation(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodPost, "/orders",
+		`{"customer_id":"","items":[{"item_id":"1","quantity":0}],"status":"ready","priority":-1}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+
+	var fields []string
+	for _, d := range decodeBody[errorResponse](t, rec).Details {
+		if d.Message == "" {
+			t.Errorf("detail %q has no message", d.Field)
+		}
+		fields = append(fields, d.Field)
+	}
+	want := []string{"customer_id", "items[0].quantity", "priority", "status"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("details fields = %v, want %v", fields, want)
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	var errs validationErrors
+	if errs.err() != nil {
+		t.Fatal("empty validationErrors is not nil")
+	}
+	errs.add("a", "a is %s", "bad")
+	errs.add("b", "b is missing")
+	if got := errs.err().Error(); got != "a is bad; b is missing" {
+		t.Errorf("Error() = %q", got)
+	}
+	if reqErr := validationFailed(errs); len(reqErr.details) != 2 || reqErr.code != CodeValidationFailed {
+		t.Errorf("validationFailed = %+v, want both details", reqErr)
+	}
+	if reqErr := validationFailed(errors.New("plain")); reqErr.details != nil {
+		t.Errorf("validationFailed(plain error) details = %v, want none", reqErr.details)
+	}
+}
+
+func TestValidOrderID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"order-42":              true,
+		"A_b-9":                 true,
+		strings.Repeat("x", 64): true,
+		strings.Repeat("x", 65): false,
+		"has spaces":            false,
+		"../etc":                false,
+		"café":                  false,
+	} {
+		if got := validOrderID(id); got != want {
+			t.Errorf("validOrderID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build metadata, injected at build time with
+//
+//	go build -ldflags "-X main.version=... -X main.commit=... -X main.buildTime=..."
+var version, commit, buildTime string
+
+// buildInfo reports the build metadata, substituting placeholders for any
+// value that was not injected.
+func buildInfo() map[string]string {
+	return map[string]string{
+		"version":    valueOr(version, "dev"),
+		"commit":     valueOr(commit, "unknown"),
+		"build_time": valueOr(buildTime, "unknown"),
+		"go_version": runtime.Version(),
+	}
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func (h *handler) version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildInfo())
+}
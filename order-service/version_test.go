@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestVersionEndpoint(t *testing.T) {
+	routes := newTestHandler(t).routes()
+	rec := serve(t, routes, http.MethodGet, "/version", "")
+	wantStatus(t, rec, http.StatusOK)
+	got := decodeBody[map[string]string](t, rec)
+	want := map[string]string{"version": "dev", "commit": "unknown", "build_time": "unknown", "go_version": runtime.Version()}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestVersionReportsInjectedValues(t *testing.T) {
+	was := version
+	version = "1.4.2"
+	t.Cleanup(func() { version = was })
+
+	if got := buildInfo()["version"]; got != "1.4.2" {
+		t.Fatalf("version = %q, want the injected 1.4.2", got)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+// webhookRetryPolicy gives slow receivers a few chances, about 15s apart at
+// most, before a callback is dropped.
+var webhookRetryPolicy = retryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+
+// webhookNotifier POSTs orders to their CallbackURL when their status
+// changes. Bodies are signed with HMAC-SHA256 over secret, sent in the
+// X-Signature-256 header as "sha256=<hex>", so receivers can verify them.
+// Deliveries go through queue, which also holds failed ones until their
+// retry is due.
+type webhookNotifier struct {
+	client *http.Client
+	secret []byte
+	retry  retryPolicy
+	queue  WebhookQueue
+	// workers runs the dispatcher and the deliveries, so shutdown waits
+	// for them.
+	workers         *workerGroup
+	pollInterval    time.Duration
+	wake            chan struct{}
+	startDispatcher sync.Once
+	// drainTimeout bounds the last attempt at queued deliveries on shutdown.
+	drainTimeout time.Duration
+}
+
+func newWebhookNotifier(secret string) *webhookNotifier {
+	return &webhookNotifier{
+		client:       newWebhookClient(defaultOutboundSettings()),
+		secret:       []byte(secret),
+		retry:        webhookRetryPolicy,
+		queue:        newMemoryWebhookQueue(defaultWebhookQueueSize),
+		pollInterval: webhookPollInterval,
+		drainTimeout: defaultWebhookDrainTimeout,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// errCallbackNotPublic is returned for a callback that resolves to an
+// address inside the service's own network.
+var errCallbackNotPublic = errors.New("callback address is not public")
+
+// sharedAddressSpace is 100.64.0.0/10, used for carrier-grade NAT and by
+// some clusters for pod networks.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether ip is routable on the internet, as opposed to
+// loopback, private, link-local (such as the 169.254.169.254 metadata
+// endpoint), shared, multicast or unspecified.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// dialPublicOnly is a net.Dialer Control func that refuses connections to
+// non-public addresses. It sees the address after DNS resolution, so a
+// public-looking name pointing inward is refused too.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", errCallbackNotPublic, host)
+	}
+	return nil
+}
+
+// newWebhookClient returns the client for callbacks. Callback URLs come from
+// clients, so unlike the other outbound clients it only connects to public
+// addresses and never through a proxy, which would hide where it connects.
+func newWebhookClient(s outboundSettings) *http.Client {
+	pool := newOutboundPool(s)
+	pool.Proxy = nil
+	pool.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublicOnly}).DialContext
+	return &http.Client{
+		Timeout:       defaultWebhookTimeout,
+		Transport:     otelhttp.NewTransport(correlatingTransport{next: pool, userAgent: s.UserAgent}),
+		CheckRedirect: checkRedirect,
+	}
+}
+
+// validateCallbackURL checks that raw is an absolute http(s) URL that does
+// not name localhost or a non-public IP. Names are checked again once
+// resolved, when the callback is sent.
+func validateCallbackURL(raw string) error {
+	if err := validateServiceURL("callback_url", raw); err != nil {
+		return err
+	}
+	u, _ := url.Parse(raw)
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("invalid callback_url %q: must not point at localhost", raw)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return fmt.Errorf("invalid callback_url %q: must point at a public address", raw)
+	}
+	return nil
+}
+
+// signWebhook returns the X-Signature-256 value for body.
+func signWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notify delivers o to its callback in the background, so a slow or broken
+// receiver never holds up the status change that triggered it. The
+// delivery outlives the request but not shutdown.
+func (n *webhookNotifier) notify(ctx context.Context, eventType string, o Order) {
+	if o.CallbackURL == "" {
+		return
+	}
+	n.schedule(ctx, webhookDelivery{Event: eventType, Order: o, RequestID: requestIDFrom(ctx), NextAttempt: time.Now()})
+}
+
+// deliver makes a single attempt; retries are scheduled through the queue.
+func (n *webhookNotifier) deliver(ctx context.Context, eventType string, o Order) error {
+	body, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Order-Event", eventType)
+	if len(n.secret) > 0 {
+		req.Header.Set("X-Signature-256", signWebhook(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return nil
+}
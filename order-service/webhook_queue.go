@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWebhookQueueSize bounds how many deliveries wait for a retry.
+	defaultWebhookQueueSize = 1000
+	// webhookPollInterval is how often the dispatcher looks for deliveries
+	// whose retry is due.
+	webhookPollInterval = 250 * time.Millisecond
+	// defaultWebhookDrainTimeout bounds the last attempt at queued
+	// deliveries on shutdown.
+	defaultWebhookDrainTimeout = 5 * time.Second
+)
+
+// farFuture is after any NextAttempt, so Due(farFuture) empties the queue.
+var farFuture = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+var (
+	errWebhookQueueFull   = errors.New("webhook queue is full")
+	errWebhookQueueClosed = errors.New("webhook queue is closed")
+)
+
+// webhookDelivery is one webhook waiting to be sent. It carries everything
+// needed to send it, so a durable queue can store it as it is.
+type webhookDelivery struct {
+	Event     string `json:"event"`
+	Order     Order  `json:"order"`
+	RequestID string `json:"request_id,omitempty"`
+	// Attempts is how many sends have failed so far.
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// WebhookQueue holds the deliveries waiting to be sent. The in-memory
+// queue loses them on restart; a durable implementation can keep them
+// without the notifier changing.
+type WebhookQueue interface {
+	// Enqueue adds d, returning errWebhookQueueFull when there is no room.
+	Enqueue(d webhookDelivery) error
+	// Due removes and returns the deliveries whose NextAttempt is not
+	// after now.
+	Due(now time.Time) []webhookDelivery
+	// Len reports how many deliveries are queued.
+	Len() int
+	// Close is called once on shutdown with whatever the drain could not
+	// send; a durable queue persists it for the next start.
+	Close() error
+}
+
+// memoryWebhookQueue is a bounded WebhookQueue kept in memory.
+type memoryWebhookQueue struct {
+	mu     sync.Mutex
+	items  []webhookDelivery
+	max    int
+	closed bool
+}
+
+func newMemoryWebhookQueue(max int) *memoryWebhookQueue {
+	return &memoryWebhookQueue{max: max}
+}
+
+func (q *memoryWebhookQueue) Enqueue(d webhookDelivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	switch {
+	case q.closed:
+		return errWebhookQueueClosed
+	case len(q.items) >= q.max:
+		return errWebhookQueueFull
+	}
+	q.items = append(q.items, d)
+	return nil
+}
+
+func (q *memoryWebhookQueue) Due(now time.Time) []webhookDelivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []webhookDelivery
+	waiting := q.items[:0]
+	for _, d := range q.items {
+		if d.NextAttempt.After(now) {
+			waiting = append(waiting, d)
+		} else {
+			due = append(due, d)
+		}
+	}
+	clear(q.items[len(waiting):])
+	q.items = waiting
+	return due
+}
+
+func (q *memoryWebhookQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Close drops and logs whatever is left, since there is nowhere to keep it.
+func (q *memoryWebhookQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	for _, d := range q.items {
+		logDropped(deliveryContext(context.Background(), d), d, errWebhookQueueClosed)
+	}
+	q.items = nil
+	return nil
+}
+
+// deliveryContext returns ctx carrying the request ID d was queued under.
+func deliveryContext(ctx context.Context, d webhookDelivery) context.Context {
+	if d.RequestID != "" {
+		return context.WithValue(ctx, requestIDKey, d.RequestID)
+	}
+	return ctx
+}
+
+// logDropped records a delivery that will never be sent.
+func logDropped(ctx context.Context, d webhookDelivery, err error) {
+	requestLogger(ctx).Error("dropping webhook", "order_id", d.Order.ID, "event", d.Event, "url", d.Order.CallbackURL, "attempts", d.Attempts, "error", err)
+}
+
+// schedule queues d and makes sure the dispatcher is running to send it.
+func (n *webhookNotifier) schedule(ctx context.Context, d webhookDelivery) {
+	if err := n.queue.Enqueue(d); err != nil {
+		logDropped(ctx, d, err)
+		return
+	}
+	n.startDispatcher.Do(func() { n.workers.Go("webhook dispatcher", n.dispatch) })
+	select {
+	case n.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch sends each delivery as it falls due, each in its own worker so
+// a slow receiver holds up nobody else. On shutdown the queue is drained
+// and then closed.
+func (n *webhookNotifier) dispatch(stop context.Context) {
+	ticker := time.NewTicker(n.pollInterval)
+	defer ticker.Stop()
+	for {
+		for _, d := range n.queue.Due(time.Now()) {
+			started := n.workers.Go("webhook delivery", func(stop context.Context) {
+				n.attempt(stop, d)
+			})
+			if !started {
+				// Shutdown has begun; leave it for the drain.
+				n.schedule(stop, d)
+			}
+		}
+		select {
+		case <-stop.Done():
+			n.drain(stop)
+			if err := n.queue.Close(); err != nil {
+				log.Printf("Closing webhook queue failed: %v", err)
+			}
+			return
+		case <-n.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain makes one last attempt at every queued delivery, including retries
+// not yet due and deliveries cut short by shutdown, for up to
+// drainTimeout. Whatever fails or is still waiting then is logged and
+// dropped.
+func (n *webhookNotifier) drain(stop context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(stop), n.drainTimeout)
+	defer cancel()
+	for {
+		pending := n.queue.Due(farFuture)
+		if len(pending) == 0 {
+			return
+		}
+		for _, d := range pending {
+			if err := ctx.Err(); err != nil {
+				logDropped(deliveryContext(ctx, d), d, err)
+				continue
+			}
+			if err := n.safeDeliver(deliveryContext(ctx, d), d); err != nil {
+				d.Attempts++
+				logDropped(deliveryContext(ctx, d), d, err)
+			}
+		}
+	}
+}
+
+// attempt sends d once, putting it back in the queue with exponential
+// backoff if that fails, until the retry policy runs out of attempts.
+func (n *webhookNotifier) attempt(stop context.Context, d webhookDelivery) {
+	ctx := deliveryContext(stop, d)
+	err := n.safeDeliver(ctx, d)
+	if err == nil {
+		return
+	}
+	d.Attempts++
+	d.LastError = err.Error()
+	logger := requestLogger(ctx).With("order_id", d.Order.ID, "url", d.Order.CallbackURL, "attempts", d.Attempts, "error", err)
+	if d.Attempts >= n.retry.MaxAttempts || errors.Is(err, errCallbackNotPublic) {
+		logger.Error("giving up on webhook")
+		return
+	}
+	d.NextAttempt = time.Now().Add(backoff(n.retry.BaseDelay, d.Attempts))
+	logger.Warn("delivering webhook failed, will retry", "next_attempt", d.NextAttempt)
+	n.schedule(ctx, d)
+}
+
+// safeDeliver turns a panic while sending into an ordinary failure, so one
+// bad delivery cannot take the process down.
+func (n *webhookNotifier) safeDeliver(ctx context.Context, d webhookDelivery) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return n.deliver(ctx, d.Event, d.Order)
+}
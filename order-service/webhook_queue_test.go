@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestNotifier returns a notifier sending through client, with its own
+// worker group.
+func newTestNotifier(client *http.Client) *webhookNotifier {
+	n := newWebhookNotifier("")
+	n.client = client
+	n.workers = newWorkerGroup()
+	return n
+}
+
+func TestMemoryWebhookQueue(t *testing.T) {
+	q := newMemoryWebhookQueue(2)
+	now := time.Now()
+	q.Enqueue(webhookDelivery{Event: "a", NextAttempt: now})
+	q.Enqueue(webhookDelivery{Event: "b", NextAttempt: now.Add(time.Minute)})
+	if err := q.Enqueue(webhookDelivery{Event: "c"}); !errors.Is(err, errWebhookQueueFull) {
+		t.Fatalf("Enqueue on a full queue = %v, want errWebhookQueueFull", err)
+	}
+	if due := q.Due(now); len(due) != 1 || due[0].Event != "a" {
+		t.Fatalf("Due = %+v, want only a", due)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("Len = %d, want 1", q.Len())
+	}
+}
+
+func TestMemoryWebhookQueueCloseLogsEachDrop(t *testing.T) {
+	logs := captureLogs(t)
+	q := newMemoryWebhookQueue(10)
+	q.Enqueue(webhookDelivery{Event: EventStatusChanged, Order: Order{ID: "o1", CallbackURL: "https://a.example/hook"}})
+	q.Enqueue(webhookDelivery{Event: EventStatusChanged, Order: Order{ID: "o2", CallbackURL: "https://b.example/hook"}})
+	q.Close()
+
+	for _, want := range []string{"order_id=o1", "https://a.example/hook", "order_id=o2", "https://b.example/hook"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs)
+		}
+	}
+	if err := q.Enqueue(webhookDelivery{}); !errors.Is(err, errWebhookQueueClosed) {
+		t.Fatalf("Enqueue after Close = %v, want errWebhookQueueClosed", err)
+	}
+}
+
+func TestShutdownDrainsPendingRetries(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get("X-Order-Event")
+	}))
+	defer srv.Close()
+	n := newTestNotifier(srv.Client())
+
+	// A retry that is not due for an hour is still sent on shutdown.
+	n.schedule(context.Background(), webhookDelivery{
+		Event:       EventStatusChanged,
+		Order:       Order{ID: "o1", CallbackURL: srv.URL},
+		NextAttempt: time.Now().Add(time.Hour),
+	})
+	if err := n.workers.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	select {
+	case event := <-got:
+		if event != EventStatusChanged {
+			t.Fatalf("X-Order-Event = %q", event)
+		}
+	default:
+		t.Fatal("queued webhook was not sent on shutdown")
+	}
+	if n.queue.Len() != 0 {
+		t.Fatalf("queue still holds %d deliveries", n.queue.Len())
+	}
+}
+
+func TestShutdownLogsWebhooksItCannotSend(t *testing.T) {
+	logs := captureLogs(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	n := newTestNotifier(srv.Client())
+
+	n.schedule(context.Background(), webhookDelivery{
+		Event:       EventStatusChanged,
+		Order:       Order{ID: "o1", CallbackURL: srv.URL},
+		NextAttempt: time.Now().Add(time.Hour),
+	})
+	if err := n.workers.Shutdown(5 * time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if !strings.Contains(logs.String(), "dropping webhook") || !strings.Contains(logs.String(), "order_id=o1") {
+		t.Fatalf("dropped delivery was not logged:\n%s", logs)
+	}
+}
+
+func TestWorkerGroupRefusesWorkAfterShutdown(t *testing.T) {
+	g := newWorkerGroup()
+	if err := g.Shutdown(time.Second); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if g.Go("late", func(context.Context) { t.Error("late worker ran") }) {
+		t.Fatal("Go reported starting work after shutdown")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := publicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestValidateCallbackURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://hooks.example.com/orders": true,
+		"http://93.184.216.34/hook":        true,
+		"http://127.0.0.1:8080/hook":       false,
+		"http://[::1]/hook":                false,
+		"http://169.254.169.254/latest":    false,
+		"http://10.0.0.5/hook":             false,
+		"http://localhost/hook":            false,
+		"http://api.LOCALHOST./hook":       false,
+		"ftp://hooks.example.com/":         false,
+	} {
+		if err := validateCallbackURL(raw); (err == nil) != ok {
+			t.Errorf("validateCallbackURL(%q) = %v, want ok %v", raw, err, ok)
+		}
+	}
+}
+
+func TestCreateOrderRejectsInternalCallback(t *testing.T) {
+	h := newTestHandler(t)
+	rec := serve(t, h.routes(), http.MethodPost, "/orders",
+		`{"customer_id":"c1","items":[{"item_id":"1","quantity":1}],"callback_url":"http://169.254.169.254/latest/meta-data"}`)
+	wantError(t, rec, http.StatusBadRequest, CodeValidationFailed)
+}
+
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+
+	n := newWebhookNotifier("")
+	err := n.deliver(context.Background(), EventStatusChanged, Order{ID: "o1", CallbackURL: srv.URL})
+	if !errors.Is(err, errCallbackNotPublic) {
+		t.Fatalf("deliver to %s = %v, want errCallbackNotPublic", srv.URL, err)
+	}
+	if hits != 0 {
+		t.Fatalf("receiver was called %d times", hits)
+	}
+}
+
+func TestDeliverSignsBody(t *testing.T) {
+	got := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got <- r }))
+	defer srv.Close()
+
+	n := newWebhookNotifier("s3cret")
+	n.client = srv.Client()
+	if err := n.deliver(context.Background(), EventStatusChanged, Order{ID: "o1", CallbackURL: srv.URL}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	r := <-got
+	if r.Header.Get("X-Order-Event") != string(EventStatusChanged) {
+		t.Errorf("X-Order-Event = %q", r.Header.Get("X-Order-Event"))
+	}
+	if r.Header.Get("X-Signature-256") == "" {
+		t.Error("X-Signature-256 not set")
+	}
+}
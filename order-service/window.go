@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// windowClock is the layout of the times in an availability window.
+const windowClock = "15:04"
+
+// availabilityWindow is the time of day an item can be ordered, e.g. a
+// breakfast menu served from 06:00 until 11:00. Until is exclusive and may
+// be earlier than From for windows that run past midnight. The times are
+// in TimeZone, or UTC when the catalog leaves it out.
+type availabilityWindow struct {
+	From     string `json:"from"`
+	Until    string `json:"until"`
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// Parsed by parseWindow.
+	from, until time.Duration
+	loc         *time.Location
+}
+
+// parseWindow decodes and checks the window of a catalog item.
+func parseWindow(raw json.RawMessage) (*availabilityWindow, error) {
+	var w availabilityWindow
+	if err := json.Unmarshal(raw, &w); err != nil {
+		return nil, err
+	}
+	var err error
+	if w.from, err = parseClock(w.From); err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	if w.until, err = parseClock(w.Until); err != nil {
+		return nil, fmt.Errorf("until: %w", err)
+	}
+	if w.from == w.until {
+		return nil, fmt.Errorf("from and until are both %s", w.From)
+	}
+	w.loc = time.UTC
+	if w.TimeZone != "" {
+		if w.loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+	return &w, nil
+}
+
+// parseClock turns "HH:MM" into the time since midnight.
+func parseClock(v string) (time.Duration, error) {
+	t, err := time.Parse(windowClock, v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", v)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains reports whether now falls inside the window.
+func (w *availabilityWindow) contains(now time.Time) bool {
+	local := now.In(w.loc)
+	hour, min, sec := local.Clock()
+	at := time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
+	if w.from < w.until {
+		return at >= w.from && at < w.until
+	}
+	return at >= w.from || at < w.until
+}
+
+// outsideWindowError reports an item ordered outside its availability
+// window.
+type outsideWindowError struct {
+	ID     string
+	Window *availabilityWindow
+}
+
+func (e *outsideWindowError) Error() string {
+	return fmt.Sprintf("item %s can only be ordered between %s and %s (%s)", e.ID, e.Window.From, e.Window.Until, e.Window.loc)
+}
+
+// checkWindows returns an *outsideWindowError for the first item that
+// cannot be ordered at now. Items without a window are always orderable.
+func checkWindows(lines []LineItem, items map[string]catalogItem, now time.Time) error {
+	for _, id := range (Order{Items: lines}).ItemIDs() {
+		if w := items[id].Window; w != nil && !w.contains(now) {
+			return &outsideWindowError{ID: id, Window: w}
+		}
+	}
+	return nil
+}
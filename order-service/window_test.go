@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustWindow parses a window from its catalog JSON.
+func mustWindow(t *testing.T, raw string) *availabilityWindow {
+	t.Helper()
+	w, err := parseWindow([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseWindow(%s): %v", raw, err)
+	}
+	return w
+}
+
+func TestAvailabilityWindowContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		c, _ := time.Parse(windowClock, clock)
+		return time.Date(2024, 5, 1, c.Hour(), c.Minute(), 0, 0, time.UTC)
+	}
+	breakfast := mustWindow(t, `{"from":"06:00","until":"11:00"}`)
+	late := mustWindow(t, `{"from":"22:00","until":"02:00"}`)
+	// 21:00 in Tokyo is 12:00 UTC.
+	tokyo := mustWindow(t, `{"from":"21:00","until":"23:00","time_zone":"Asia/Tokyo"}`)
+	for _, tc := range []struct {
+		name   string
+		window *availabilityWindow
+		clock  string
+		want   bool
+	}{
+		{"before breakfast", breakfast, "05:59", false},
+		{"breakfast opens", breakfast, "06:00", true},
+		{"breakfast closes", breakfast, "11:00", false},
+		{"late evening", late, "23:30", true},
+		{"past midnight", late, "01:59", true},
+		{"late closed", late, "12:00", false},
+		{"tokyo evening", tokyo, "12:00", true},
+		{"tokyo morning", tokyo, "03:00", false},
+	} {
+		if got := tc.window.contains(at(tc.clock)); got != tc.want {
+			t.Errorf("%s: contains(%s) = %v, want %v", tc.name, tc.clock, got, tc.want)
+		}
+	}
+}
+
+func TestParseWindowRejectsBadWindows(t *testing.T) {
+	for _, raw := range []string{
+		`{"from":"6am","until":"11:00"}`,
+		`{"from":"06:00","until":"25:00"}`,
+		`{"from":"06:00","until":"06:00"}`,
+		`{"from":"06:00","until":"11:00","time_zone":"Mars/Olympus"}`,
+		`[]`,
+	} {
+		if _, err := parseWindow([]byte(raw)); err == nil {
+			t.Errorf("parseWindow(%s) accepted", raw)
+		}
+	}
+}
+
+func TestDecodeCatalogItemWindow(t *testing.T) {
+	item, err := decodeCatalogItem(strings.NewReader(`{"id":"1","price":3,"available_hours":{"from":"06:00","until":"11:00"}}`), "1")
+	if err != nil {
+		t.Fatalf("decodeCatalogItem: %v", err)
+	}
+	if item.Window == nil || item.Window.From != "06:00" {
+		t.Errorf("Window = %+v, want 06:00-11:00", item.Window)
+	}
+	if item, _ := decodeCatalogItem(strings.NewReader(`{"id":"1","price":3,"available_hours":null}`), "1"); item.Window != nil {
+		t.Errorf("null available_hours gave window %+v", item.Window)
+	}
+	_, err = decodeCatalogItem(strings.NewReader(`{"id":"1","price":3,"available_hours":{"from":"06:00"}}`), "1")
+	if !errors.Is(err, errCatalogBadResponse) {
+		t.Errorf("window without until = %v, want errCatalogBadResponse", err)
+	}
+}
+
+func TestCreateOrderOutsideWindow(t *testing.T) {
+	breakfast := mustWindow(t, `{"from":"06:00","until":"11:00"}`)
+	lunch := mustWindow(t, `{"from":"11:00","until":"15:00"}`)
+	catalog := &stubCatalog{validate: func(_ context.Context, ids []string) (map[string]catalogItem, error) {
+		items := make(map[string]catalogItem, len(ids))
+		for _, id := range ids {
+			item := catalogItem{ID: id, Price: 1, Available: true}
+			switch id {
+			case "breakfast":
+				item.Window = breakfast
+			case "lunch":
+				item.Window = lunch
+			}
+			items[id] = item
+		}
+		return items, nil
+	}}
+	h := newTestHandler(t, WithCatalog(catalog))
+	routes := h.routes()
+
+	// testTime is 12:00 UTC: lunch is served, breakfast is over.
+	createTestOrder(t, routes, `{"customer_id":"c1","items":[{"item_id":"lunch","quantity":1},{"item_id":"anytime","quantity":1}]}`)
+	rec := serve(t, routes, http.MethodPost, "/orders", `{"customer_id":"c1","items":[{"item_id":"lunch","quantity":1},{"item_id":"breakfast","quantity":1}]}`)
+	wantError(t, rec, http.StatusConflict, CodeItemOutsideWindow)
+	if msg := decodeBody[errorResponse](t, rec).Error; !strings.Contains(msg, "breakfast") || !strings.Contains(msg, "06:00") {
+		t.Errorf("error = %q, want it to name the item and its hours", msg)
+	}
+	if n := len(h.store.All()); n != 1 {
+		t.Errorf("store holds %d orders, want 1", n)
+	}
+}